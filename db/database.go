@@ -3,7 +3,9 @@ package db
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"log"
+	"strings"
 
 	"github.com/jmoiron/sqlx"
 	_ "modernc.org/sqlite"
@@ -97,6 +99,62 @@ func InitDB(filepath string) *SQLDatabase {
 	DB = sqlDB
 	return sqlDB
 }
+
+// Rows in these tables belong to either a user or a team, so user_id is
+// nullable and team_id is set for team-owned rows.
+const userTagAlertsColumns = `(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER,
+	team_id INTEGER,
+	tag TEXT NOT NULL,
+	slack_channel_id TEXT NOT NULL,
+	alert_type TEXT NOT NULL,
+	pagerduty BOOLEAN NOT NULL DEFAULT 0,
+	digest_interval INTEGER NOT NULL DEFAULT 0,
+	rate_limit INTEGER NOT NULL DEFAULT 0,
+	oncall_schedule_id INTEGER,
+	organization TEXT NOT NULL DEFAULT '',
+	vip_only BOOLEAN NOT NULL DEFAULT 0,
+	group_id INTEGER NOT NULL DEFAULT 0,
+	threshold_minutes INTEGER NOT NULL DEFAULT 0,
+	keywords TEXT NOT NULL DEFAULT '',
+	keyword_regex BOOLEAN NOT NULL DEFAULT 0,
+	keyword_case_sensitive BOOLEAN NOT NULL DEFAULT 0,
+	keyword_whole_word BOOLEAN NOT NULL DEFAULT 0,
+	spike_factor REAL NOT NULL DEFAULT 0,
+	assign_team_id INTEGER,
+	assign_max_open INTEGER NOT NULL DEFAULT 0,
+	FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY(team_id) REFERENCES teams(id) ON DELETE CASCADE
+)`
+
+const alertLogsColumns = `(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER,
+	team_id INTEGER,
+	ticket_id INTEGER NOT NULL,
+	tag TEXT NOT NULL,
+	alert_type TEXT NOT NULL,
+	timestamp DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	acknowledged_at DATETIME,
+	acknowledged_by TEXT,
+	FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY(team_id) REFERENCES teams(id) ON DELETE CASCADE
+)`
+
+const slaAlertCacheColumns = `(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INT,
+	team_id INT,
+	ticket_id INT NOT NULL,
+	alert_type VARCHAR(255) NOT NULL,
+	breach_at TIMESTAMP NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(user_id, team_id, ticket_id, alert_type),
+	FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY(team_id) REFERENCES teams(id) ON DELETE CASCADE
+)`
+
 func (s *SQLDatabase) initTables() error {
 	tablesSQL := []string{
 		`CREATE TABLE IF NOT EXISTS users (
@@ -108,15 +166,23 @@ func (s *SQLDatabase) initTables() error {
             selected_tags TEXT,
             summary_time DATETIME,
             slack_user_id TEXT,
+            webhook_url TEXT,
             created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
             updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
         );`,
-		`CREATE TABLE IF NOT EXISTS user_tag_alerts (
+		`CREATE TABLE IF NOT EXISTS user_tag_alerts ` + userTagAlertsColumns + `;`,
+		`CREATE TABLE IF NOT EXISTS teams (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE TABLE IF NOT EXISTS team_members (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			team_id INTEGER NOT NULL,
 			user_id INTEGER NOT NULL,
-			tag TEXT NOT NULL,
-			slack_channel_id TEXT NOT NULL,
-			alert_type TEXT NOT NULL,
+			role TEXT NOT NULL DEFAULT 'member',
+			UNIQUE(team_id, user_id),
+			FOREIGN KEY(team_id) REFERENCES teams(id) ON DELETE CASCADE,
 			FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
 		);`,
 		`CREATE TABLE IF NOT EXISTS configuration (
@@ -124,25 +190,291 @@ func (s *SQLDatabase) initTables() error {
 			key TEXT NOT NULL UNIQUE,
 			value TEXT NOT NULL
 		);`,
-		`CREATE TABLE IF NOT EXISTS alert_logs (
-			id INTEGER PRIMARY KEY AUTOINCREMENT, 
-			user_id INTEGER NOT NULL,   
+		`CREATE TABLE IF NOT EXISTS alert_logs ` + alertLogsColumns + `;`,
+		`CREATE TABLE IF NOT EXISTS sla_alert_cache ` + slaAlertCacheColumns + `;`,
+		`CREATE TABLE IF NOT EXISTS pagerduty_incidents (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			ticket_id INTEGER NOT NULL,
-			tag TEXT NOT NULL,  
+			metric TEXT NOT NULL,
+			dedup_key TEXT NOT NULL UNIQUE,
+			shadow BOOLEAN NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE TABLE IF NOT EXISTS notification_preferences (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
 			alert_type TEXT NOT NULL,
-			timestamp DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL,
+			channel TEXT NOT NULL,
+			enabled BOOLEAN NOT NULL DEFAULT 0,
+			UNIQUE(user_id, alert_type, channel),
 			FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
 		);`,
-		`CREATE TABLE IF NOT EXISTS sla_alert_cache (
-			id INTEGER PRIMARY KEY AUTOINCREMENT, -- Use INTEGER for AUTOINCREMENT
-			user_id INT NOT NULL,
-			ticket_id INT NOT NULL,
-			alert_type VARCHAR(255) NOT NULL,
-			breach_at TIMESTAMP NOT NULL,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
-			UNIQUE(user_id, ticket_id, alert_type),
+		`CREATE TABLE IF NOT EXISTS ticket_activity (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			ticket_id INTEGER NOT NULL,
+			tags TEXT NOT NULL,
+			alert_type TEXT NOT NULL,
+			sla_label TEXT,
+			organization_id INTEGER,
+			requester_id INTEGER,
+			event_at DATETIME NOT NULL,
+			observed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(ticket_id, alert_type, event_at)
+		);`,
+		`CREATE TABLE IF NOT EXISTS shadow_alert_logs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			alert_type TEXT NOT NULL,
+			channel TEXT NOT NULL,
+			destination TEXT,
+			user_id INTEGER,
+			team_id INTEGER,
+			rule_id INTEGER,
+			ticket_id INTEGER,
+			tag TEXT,
+			sla_label TEXT,
+			subject TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE TABLE IF NOT EXISTS digest_queue (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			channel TEXT NOT NULL,
+			destination TEXT NOT NULL,
+			interval_minutes INTEGER NOT NULL,
+			alert_type TEXT NOT NULL,
+			ticket_id INTEGER NOT NULL,
+			subject TEXT NOT NULL,
+			status TEXT,
+			priority TEXT,
+			tag TEXT,
+			sla_label TEXT,
+			occurrences INTEGER NOT NULL DEFAULT 1,
+			created_at DATETIME NOT NULL,
+			UNIQUE(channel, destination, ticket_id, alert_type)
+		);`,
+		`CREATE TABLE IF NOT EXISTS quiet_hours (
+			user_id INTEGER PRIMARY KEY,
+			enabled BOOLEAN NOT NULL DEFAULT 0,
+			start_time TEXT NOT NULL,
+			end_time TEXT NOT NULL,
+			days TEXT NOT NULL,
+			action TEXT NOT NULL DEFAULT 'hold',
+			backup_user_id INTEGER,
+			allow_breaches BOOLEAN NOT NULL DEFAULT 0,
+			FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY(backup_user_id) REFERENCES users(id) ON DELETE SET NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS held_notifications (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			channel TEXT NOT NULL,
+			alert_type TEXT NOT NULL,
+			ticket_id INTEGER NOT NULL,
+			subject TEXT NOT NULL,
+			status TEXT,
+			priority TEXT,
+			requester_id INTEGER,
+			organization_id INTEGER,
+			tag TEXT,
+			sla_label TEXT,
+			created_at DATETIME NOT NULL,
+			UNIQUE(user_id, channel, ticket_id, alert_type),
 			FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
 		);`,
+		`CREATE TABLE IF NOT EXISTS absences (
+			user_id INTEGER PRIMARY KEY,
+			away_from DATETIME NOT NULL,
+			away_until DATETIME NOT NULL,
+			delegate_user_id INTEGER,
+			delegate_channel TEXT,
+			FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY(delegate_user_id) REFERENCES users(id) ON DELETE SET NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS oncall_schedules (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			rotation_start DATETIME NOT NULL,
+			rotation_days INTEGER NOT NULL DEFAULT 7,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE TABLE IF NOT EXISTS oncall_members (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			schedule_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			position INTEGER NOT NULL,
+			UNIQUE(schedule_id, user_id),
+			FOREIGN KEY(schedule_id) REFERENCES oncall_schedules(id) ON DELETE CASCADE,
+			FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`,
+		`CREATE TABLE IF NOT EXISTS oncall_overrides (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			schedule_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			starts_at DATETIME NOT NULL,
+			ends_at DATETIME NOT NULL,
+			uid TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(schedule_id, uid),
+			FOREIGN KEY(schedule_id) REFERENCES oncall_schedules(id) ON DELETE CASCADE,
+			FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`,
+		`CREATE TABLE IF NOT EXISTS ticket_mutes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			ticket_id INTEGER NOT NULL,
+			user_id INTEGER,
+			channel_id TEXT,
+			condition TEXT NOT NULL DEFAULT 'time',
+			sla_stage TEXT,
+			until DATETIME NOT NULL,
+			muted_by TEXT,
+			created_at DATETIME NOT NULL,
+			FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`,
+		`CREATE TABLE IF NOT EXISTS ticket_watches (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			ticket_id INTEGER NOT NULL,
+			created_at DATETIME NOT NULL,
+			UNIQUE(user_id, ticket_id),
+			FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`,
+		`CREATE TABLE IF NOT EXISTS vip_entries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			kind TEXT NOT NULL,
+			value TEXT NOT NULL,
+			note TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(kind, value)
+		);`,
+		`CREATE TABLE IF NOT EXISTS zendesk_records (
+			kind TEXT NOT NULL,
+			id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			email TEXT,
+			fetched_at DATETIME NOT NULL,
+			PRIMARY KEY(kind, id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS ticket_snapshots (
+			ticket_id INTEGER PRIMARY KEY,
+			status TEXT NOT NULL,
+			priority TEXT NOT NULL DEFAULT '',
+			assignee_id INTEGER NOT NULL DEFAULT 0,
+			group_id INTEGER NOT NULL DEFAULT 0,
+			tags TEXT NOT NULL DEFAULT '',
+			satisfaction_score TEXT NOT NULL DEFAULT '',
+			updated_at DATETIME,
+			observed_at DATETIME NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS age_alert_cache (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			rule_id INTEGER NOT NULL,
+			ticket_id INTEGER NOT NULL,
+			since DATETIME NOT NULL,
+			created_at DATETIME NOT NULL,
+			UNIQUE(rule_id, ticket_id),
+			FOREIGN KEY(rule_id) REFERENCES user_tag_alerts(id) ON DELETE CASCADE
+		);`,
+		`CREATE TABLE IF NOT EXISTS tag_aliases (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			alias TEXT NOT NULL UNIQUE,
+			canonical TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);`,
+		`CREATE TABLE IF NOT EXISTS tag_catalog (
+			name TEXT PRIMARY KEY,
+			ticket_count INTEGER NOT NULL DEFAULT 0,
+			last_seen_at DATETIME,
+			synced_at DATETIME,
+			created_at DATETIME NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS ticket_arrivals (
+			ticket_id INTEGER PRIMARY KEY,
+			tags TEXT NOT NULL DEFAULT '',
+			organization_id INTEGER NOT NULL DEFAULT 0,
+			requester_id INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS volume_spikes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			rule_id INTEGER NOT NULL,
+			ticket_count INTEGER NOT NULL,
+			baseline REAL NOT NULL,
+			created_at DATETIME NOT NULL,
+			FOREIGN KEY(rule_id) REFERENCES user_tag_alerts(id) ON DELETE CASCADE
+		);`,
+		`CREATE TABLE IF NOT EXISTS ticket_durations (
+			ticket_id INTEGER PRIMARY KEY,
+			priority TEXT NOT NULL DEFAULT '',
+			group_id INTEGER NOT NULL DEFAULT 0,
+			tags TEXT NOT NULL DEFAULT '',
+			first_reply_minutes INTEGER,
+			solve_minutes INTEGER,
+			recorded_at DATETIME NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS sla_risk_scores (
+			ticket_id INTEGER NOT NULL,
+			subject TEXT NOT NULL DEFAULT '',
+			metric TEXT NOT NULL,
+			breach_at DATETIME NOT NULL,
+			score INTEGER NOT NULL,
+			samples INTEGER NOT NULL,
+			updated_at DATETIME NOT NULL,
+			PRIMARY KEY(ticket_id, metric)
+		);`,
+		`CREATE TABLE IF NOT EXISTS incidents (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			tag TEXT NOT NULL DEFAULT '',
+			keyword TEXT NOT NULL DEFAULT '',
+			started_at DATETIME NOT NULL,
+			resolved_at DATETIME,
+			declared_by TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS incident_channels (
+			incident_id INTEGER NOT NULL,
+			channel_id TEXT NOT NULL,
+			thread_ts TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY(incident_id, channel_id),
+			FOREIGN KEY(incident_id) REFERENCES incidents(id) ON DELETE CASCADE
+		);`,
+		`CREATE TABLE IF NOT EXISTS incident_tickets (
+			incident_id INTEGER NOT NULL,
+			ticket_id INTEGER NOT NULL,
+			subject TEXT NOT NULL DEFAULT '',
+			organization_id INTEGER NOT NULL DEFAULT 0,
+			organization_name TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL,
+			PRIMARY KEY(incident_id, ticket_id),
+			FOREIGN KEY(incident_id) REFERENCES incidents(id) ON DELETE CASCADE
+		);`,
+		`CREATE TABLE IF NOT EXISTS incident_updates (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			incident_id INTEGER NOT NULL,
+			message TEXT NOT NULL,
+			posted_by TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL,
+			FOREIGN KEY(incident_id) REFERENCES incidents(id) ON DELETE CASCADE
+		);`,
+		`CREATE TABLE IF NOT EXISTS ticket_assignments (
+			ticket_id INTEGER PRIMARY KEY,
+			rule_id INTEGER NOT NULL,
+			team_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			assignee_id INTEGER NOT NULL,
+			assigned_at DATETIME NOT NULL
+		);`,
+		`CREATE TABLE IF NOT EXISTS shifts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			team_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			start_time TEXT NOT NULL,
+			end_time TEXT NOT NULL,
+			days TEXT NOT NULL,
+			slack_channel_id TEXT NOT NULL,
+			last_report_at DATETIME,
+			created_at DATETIME NOT NULL,
+			FOREIGN KEY(team_id) REFERENCES teams(id) ON DELETE CASCADE
+		);`,
 	}
 
 	for _, stmt := range tablesSQL {
@@ -151,5 +483,143 @@ func (s *SQLDatabase) initTables() error {
 		}
 	}
 
+	if err := s.rebuildTables(); err != nil {
+		return err
+	}
+
+	return s.migrateColumns()
+}
+
+// tableRebuild describes a table whose column constraints changed after its
+// initial release. SQLite cannot alter constraints in place, so the table is
+// recreated from columns and the existing rows are copied across.
+type tableRebuild struct {
+	table string
+	// nullableColumn is the column that used to be NOT NULL. The table is only
+	// rebuilt while that constraint is still present.
+	nullableColumn string
+	columns        string
+}
+
+var tableRebuilds = []tableRebuild{
+	{"user_tag_alerts", "user_id", userTagAlertsColumns},
+	{"alert_logs", "user_id", alertLogsColumns},
+	{"sla_alert_cache", "user_id", slaAlertCacheColumns},
+}
+
+// rebuildTables upgrades tables listed in tableRebuilds that still carry the old constraints.
+func (s *SQLDatabase) rebuildTables() error {
+	for _, rb := range tableRebuilds {
+		var notNull bool
+		err := s.Get(&notNull, `SELECT "notnull" FROM pragma_table_info(?) WHERE name = ?`, rb.table, rb.nullableColumn)
+		if err != nil {
+			return fmt.Errorf("failed to inspect %s: %w", rb.table, err)
+		}
+		if !notNull {
+			continue
+		}
+		if err := s.rebuildTable(rb); err != nil {
+			return fmt.Errorf("failed to rebuild %s: %w", rb.table, err)
+		}
+	}
+	return nil
+}
+
+func (s *SQLDatabase) rebuildTable(rb tableRebuild) error {
+	tmpTable := rb.table + "_rebuild"
+
+	tx, err := s.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(fmt.Sprintf("CREATE TABLE %s %s", tmpTable, rb.columns)); err != nil {
+		return err
+	}
+
+	// Copy every column the old and new tables have in common
+	var shared []string
+	err = tx.Select(&shared, `
+		SELECT old.name FROM pragma_table_info(?) AS old
+		JOIN pragma_table_info(?) AS new ON new.name = old.name
+		ORDER BY old.cid
+	`, rb.table, tmpTable)
+	if err != nil {
+		return err
+	}
+	columnList := strings.Join(shared, ", ")
+	if _, err := tx.Exec(fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", tmpTable, columnList, columnList, rb.table)); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf("DROP TABLE %s", rb.table)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s RENAME TO %s", tmpTable, rb.table)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// columnMigration describes a column that was added to a table after its
+// initial release. Databases created before the column existed are upgraded
+// in place when the application starts.
+type columnMigration struct {
+	table      string
+	column     string
+	definition string
+}
+
+var columnMigrations = []columnMigration{
+	{"user_tag_alerts", "pagerduty", "BOOLEAN NOT NULL DEFAULT 0"},
+	{"users", "webhook_url", "TEXT"},
+	{"user_tag_alerts", "digest_interval", "INTEGER NOT NULL DEFAULT 0"},
+	{"user_tag_alerts", "rate_limit", "INTEGER NOT NULL DEFAULT 0"},
+	{"user_tag_alerts", "oncall_schedule_id", "INTEGER"},
+	{"user_tag_alerts", "organization", "TEXT NOT NULL DEFAULT ''"},
+	{"user_tag_alerts", "vip_only", "BOOLEAN NOT NULL DEFAULT 0"},
+	{"ticket_activity", "organization_id", "INTEGER"},
+	{"ticket_activity", "requester_id", "INTEGER"},
+	{"user_tag_alerts", "group_id", "INTEGER NOT NULL DEFAULT 0"},
+	{"ticket_snapshots", "satisfaction_score", "TEXT NOT NULL DEFAULT ''"},
+	{"user_tag_alerts", "threshold_minutes", "INTEGER NOT NULL DEFAULT 0"},
+	{"user_tag_alerts", "keywords", "TEXT NOT NULL DEFAULT ''"},
+	{"user_tag_alerts", "keyword_regex", "BOOLEAN NOT NULL DEFAULT 0"},
+	{"user_tag_alerts", "keyword_case_sensitive", "BOOLEAN NOT NULL DEFAULT 0"},
+	{"user_tag_alerts", "keyword_whole_word", "BOOLEAN NOT NULL DEFAULT 0"},
+	{"user_tag_alerts", "spike_factor", "REAL NOT NULL DEFAULT 0"},
+	{"user_tag_alerts", "assign_team_id", "INTEGER"},
+	{"user_tag_alerts", "assign_max_open", "INTEGER NOT NULL DEFAULT 0"},
+	{"alert_logs", "acknowledged_at", "DATETIME"},
+	{"alert_logs", "acknowledged_by", "TEXT"},
+	{"pagerduty_incidents", "shadow", "BOOLEAN NOT NULL DEFAULT 0"},
+}
+
+// migrateColumns adds any columns from columnMigrations that are missing from
+// an existing database.
+func (s *SQLDatabase) migrateColumns() error {
+	for _, m := range columnMigrations {
+		exists, err := s.columnExists(m.table, m.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if _, err := s.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", m.table, m.column, m.definition)); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", m.table, m.column, err)
+		}
+	}
 	return nil
 }
+
+// columnExists reports whether the given table already has the named column.
+func (s *SQLDatabase) columnExists(table, column string) (bool, error) {
+	var count int
+	err := s.Get(&count, "SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -179,7 +179,7 @@ func TestInitDB(t *testing.T) {
 	assert.NotNil(t, database.GetDB(), "Expected the underlying *sqlx.DB to be initialized")
 
 	// Check that the tables exist
-	tables := []string{"users", "user_tag_alerts", "configuration", "alert_logs", "sla_alert_cache"}
+	tables := []string{"users", "user_tag_alerts", "configuration", "alert_logs", "sla_alert_cache", "pagerduty_incidents", "notification_preferences", "teams", "team_members", "ticket_activity", "shadow_alert_logs", "digest_queue", "quiet_hours", "held_notifications", "absences", "oncall_schedules", "oncall_members", "oncall_overrides", "ticket_mutes", "ticket_watches", "vip_entries", "zendesk_records", "ticket_snapshots", "age_alert_cache", "tag_aliases", "tag_catalog", "ticket_arrivals", "volume_spikes", "ticket_durations", "sla_risk_scores", "incidents", "incident_channels", "incident_tickets", "incident_updates", "ticket_assignments", "shifts"}
 	for _, table := range tables {
 		var tableName string
 		err := database.Get(&tableName, "SELECT name FROM sqlite_master WHERE type='table' AND name=?", table)
@@ -187,3 +187,75 @@ func TestInitDB(t *testing.T) {
 		assert.Equal(t, table, tableName, "Expected table name to match")
 	}
 }
+
+func TestInitDB_MigratesExistingDatabase(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "testdb-*.sqlite")
+	assert.NoError(t, err, "Expected no error creating temporary database file")
+	defer os.Remove(tmpFile.Name())
+
+	// Create a database with the original user_tag_alerts schema
+	legacy := db.InitDB(tmpFile.Name())
+	_, err = legacy.Exec("DROP TABLE user_tag_alerts")
+	assert.NoError(t, err, "Expected no error dropping user_tag_alerts")
+	_, err = legacy.Exec(`CREATE TABLE user_tag_alerts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		tag TEXT NOT NULL,
+		slack_channel_id TEXT NOT NULL,
+		alert_type TEXT NOT NULL
+	)`)
+	assert.NoError(t, err, "Expected no error creating legacy user_tag_alerts")
+	legacy.Close()
+
+	// Re-open the database, which should add the missing columns
+	database := db.InitDB(tmpFile.Name())
+	defer database.Close()
+
+	for _, column := range []string{"pagerduty", "digest_interval", "rate_limit", "oncall_schedule_id", "organization", "vip_only", "group_id", "threshold_minutes", "keywords", "keyword_regex", "keyword_case_sensitive", "keyword_whole_word", "spike_factor", "assign_team_id", "assign_max_open"} {
+		var count int
+		err = database.Get(&count, "SELECT COUNT(*) FROM pragma_table_info('user_tag_alerts') WHERE name = ?", column)
+		assert.NoError(t, err, "Expected no error inspecting user_tag_alerts")
+		assert.Equal(t, 1, count, "Expected %s column to be added", column)
+	}
+}
+
+func TestInitDB_RebuildsUserOwnedTables(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "testdb-*.sqlite")
+	assert.NoError(t, err, "Expected no error creating temporary database file")
+	defer os.Remove(tmpFile.Name())
+
+	// Create a database where alert_logs still requires a user
+	legacy := db.InitDB(tmpFile.Name())
+	_, err = legacy.Exec("DROP TABLE alert_logs")
+	assert.NoError(t, err, "Expected no error dropping alert_logs")
+	_, err = legacy.Exec(`CREATE TABLE alert_logs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		ticket_id INTEGER NOT NULL,
+		tag TEXT NOT NULL,
+		alert_type TEXT NOT NULL,
+		timestamp DATETIME DEFAULT CURRENT_TIMESTAMP NOT NULL
+	)`)
+	assert.NoError(t, err, "Expected no error creating legacy alert_logs")
+	_, err = legacy.Exec("INSERT INTO alert_logs (user_id, ticket_id, tag, alert_type) VALUES (1, 42, 'billing', 'new_ticket')")
+	assert.NoError(t, err, "Expected no error inserting legacy alert log")
+	legacy.Close()
+
+	database := db.InitDB(tmpFile.Name())
+	defer database.Close()
+
+	var ticketID int
+	err = database.Get(&ticketID, "SELECT ticket_id FROM alert_logs WHERE user_id = 1")
+	assert.NoError(t, err, "Expected existing alert logs to be preserved")
+	assert.Equal(t, 42, ticketID, "Expected ticket ID to be copied")
+
+	_, err = database.Exec("INSERT INTO alert_logs (team_id, ticket_id, tag, alert_type) VALUES (1, 43, 'billing', 'new_ticket')")
+	assert.NoError(t, err, "Expected team-owned alert logs to be accepted")
+
+	for _, column := range []string{"acknowledged_at", "acknowledged_by"} {
+		var count int
+		err = database.Get(&count, "SELECT COUNT(*) FROM pragma_table_info('alert_logs') WHERE name = ?", column)
+		assert.NoError(t, err, "Expected no error inspecting alert_logs")
+		assert.Equal(t, 1, count, "Expected %s column to be added", column)
+	}
+}
@@ -172,6 +172,7 @@ func (h *AppHandler) TagManagementHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	data["TagAlerts"] = tagAlerts
+	h.addTagCatalogData(data, tagAlerts)
 
 	h.renderTemplate(w, "templates/admin/tag_management.html", data)
 }
@@ -192,6 +193,37 @@ func (h *AppHandler) DeleteTagAlertHandler(w http.ResponseWriter, r *http.Reques
 	http.Redirect(w, r, "/admin/tags", http.StatusSeeOther)
 }
 
+// shadowLogLimit caps how many shadow log entries are shown.
+const shadowLogLimit = 500
+
+func (h *AppHandler) ShadowLogHandler(w http.ResponseWriter, r *http.Request) {
+	logs, err := models.GetRecentShadowAlertLogs(h.DB, shadowLogLimit)
+	if err != nil {
+		http.Error(w, "Unable to retrieve shadow log", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := h.getCommonData(r, "Shadow Log")
+	if err != nil {
+		http.Error(w, "Unable to retrieve common data", http.StatusInternalServerError)
+		return
+	}
+
+	data["ShadowLogs"] = logs
+	data["ShadowLogLimit"] = shadowLogLimit
+
+	h.renderTemplate(w, "templates/admin/shadow_log.html", data)
+}
+
+func (h *AppHandler) ClearShadowLogHandler(w http.ResponseWriter, r *http.Request) {
+	if err := models.ClearShadowAlertLogs(h.DB); err != nil {
+		http.Error(w, "Unable to clear shadow log", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/shadow-log", http.StatusSeeOther)
+}
+
 func (h *AppHandler) getCurrentUser(r *http.Request) models.User {
 	session, _ := store.Get(r, "session-name")
 	userID := session.Values["user_id"].(int)
@@ -41,11 +41,21 @@ func (h *AppHandler) ConfigurationHandler(w http.ResponseWriter, r *http.Request
 func (h *AppHandler) saveConfigurationSettings(r *http.Request) error {
 	configs := map[string]string{
 		"daily_summary_enabled": r.FormValue("daily_summary_enabled"),
+		"shadow_mode":           r.FormValue("shadow_mode"),
 		"slack_app_token":       r.FormValue("slack_app_token"),
 		"slack_bot_token":       r.FormValue("slack_bot_token"),
 		"zendesk_api_key":       r.FormValue("zendesk_api_key"),
 		"zendesk_subdomain":     r.FormValue("zendesk_subdomain"),
 		"zendesk_email":         r.FormValue("zendesk_email"), // New entry
+		"pagerduty_routing_key": r.FormValue("pagerduty_routing_key"),
+		"pagerduty_events_url":  r.FormValue("pagerduty_events_url"),
+		"smtp_host":             r.FormValue("smtp_host"),
+		"smtp_port":             r.FormValue("smtp_port"),
+		"smtp_username":         r.FormValue("smtp_username"),
+		"smtp_password":         r.FormValue("smtp_password"),
+		"smtp_from":             r.FormValue("smtp_from"),
+		"rate_limit_channel":    r.FormValue("rate_limit_channel"),
+		"rate_limit_window":     r.FormValue("rate_limit_window"),
 	}
 
 	for key, value := range configs {
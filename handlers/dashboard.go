@@ -8,6 +8,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/TylerConlee/TicketPulse/models"
 	"github.com/TylerConlee/TicketPulse/services"
 )
 
@@ -50,6 +51,13 @@ func (h *AppHandler) DashboardHandler(w http.ResponseWriter, r *http.Request, da
 		return
 	}
 
+	// Tickets predicted to breach are scored each poll, so the dashboard does not call Zendesk
+	likelyToBreach, err := models.GetSLARiskScores(h.DB, services.SLARiskThreshold)
+	if err != nil {
+		log.Println("Error getting SLA risk scores:", err)
+	}
+	zendeskSubdomain, _ := models.GetConfiguration(h.DB, "zendesk_subdomain")
+
 	// Render the dashboard template with the processed data
 	t := template.Must(template.New("layout.html").Funcs(funcMap).ParseFiles("templates/layout.html", "templates/dashboard.html"))
 	if err := t.ExecuteTemplate(w, "layout.html", map[string]interface{}{
@@ -63,6 +71,8 @@ func (h *AppHandler) DashboardHandler(w http.ResponseWriter, r *http.Request, da
 		"SlaDeadlineData":     template.JS(slaDeadlineDataJSON),
 		"TicketUpdateData":    template.JS(ticketUpdateDataJSON),
 		"Notifications":       data["Notifications"],
+		"LikelyToBreach":      likelyToBreach,
+		"ZendeskSubdomain":    zendeskSubdomain,
 	}); err != nil {
 		log.Printf("Error rendering template: %v", err)
 		http.Error(w, "Unable to render template", http.StatusInternalServerError)
@@ -10,6 +10,7 @@ import (
 	"github.com/TylerConlee/TicketPulse/db"
 	"github.com/TylerConlee/TicketPulse/middlewares"
 	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/TylerConlee/TicketPulse/services"
 	"github.com/gorilla/mux"
 )
 
@@ -133,6 +134,7 @@ func (h *AppHandler) getCommonData(r *http.Request, title string) (map[string]in
 		"User":          user,
 		"FirstUserID":   firstUserID,
 		"Notifications": notifications,
+		"ShadowMode":    services.ShadowModeEnabled(h.DB),
 	}
 
 	return data, nil
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/TylerConlee/TicketPulse/services"
+	"github.com/gorilla/mux"
+)
+
+// IncidentsHandler lists incidents and declares new ones.
+func (h *AppHandler) IncidentsHandler(w http.ResponseWriter, r *http.Request, slackService *services.SlackService, incidentService *services.IncidentService) {
+	if r.Method == "POST" {
+		incident := models.Incident{
+			Name:       strings.TrimSpace(r.FormValue("name")),
+			Tag:        strings.TrimSpace(r.FormValue("tag")),
+			Keyword:    strings.TrimSpace(r.FormValue("keyword")),
+			StartedAt:  time.Now(),
+			DeclaredBy: h.getCurrentUser(r).Email,
+		}
+		if incident.Name == "" || (incident.Tag == "" && incident.Keyword == "") {
+			http.Error(w, "An incident needs a name and a tag or keyword", http.StatusBadRequest)
+			return
+		}
+		if err := services.ValidateTagPattern(incident.Tag); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if startedAt := r.FormValue("started_at"); startedAt != "" {
+			var err error
+			incident.StartedAt, err = time.ParseInLocation(dateTimeLocalFormat, startedAt, time.Local)
+			if err != nil {
+				http.Error(w, "Invalid start time", http.StatusBadRequest)
+				return
+			}
+		}
+
+		incidentID, err := incidentService.Declare(r.Context(), incident, r.Form["channels"])
+		if incidentID == 0 {
+			log.Println("Error declaring incident:", err)
+			http.Error(w, "Unable to declare incident", http.StatusInternalServerError)
+			return
+		}
+		if err != nil {
+			log.Printf("Incident %d declared, but not every channel could be posted to: %v", incidentID, err)
+		}
+		http.Redirect(w, r, "/admin/incidents/"+strconv.FormatInt(incidentID, 10), http.StatusSeeOther)
+		return
+	}
+
+	incidents, err := models.GetIncidents(h.DB)
+	if err != nil {
+		http.Error(w, "Unable to retrieve incidents", http.StatusInternalServerError)
+		return
+	}
+	var summaries []services.IncidentSummary
+	for _, incident := range incidents {
+		summary, err := incidentService.Summary(incident.ID)
+		if err != nil || summary == nil {
+			log.Printf("Error summarizing incident %d: %v", incident.ID, err)
+			continue
+		}
+		summaries = append(summaries, *summary)
+	}
+
+	data, err := h.getCommonData(r, "Incidents")
+	if err != nil {
+		http.Error(w, "Unable to retrieve common data", http.StatusInternalServerError)
+		return
+	}
+	data["Incidents"] = summaries
+	data["SlackChannels"] = slackChannelOptions(slackService)
+
+	h.renderTemplate(w, "templates/admin/incidents.html", data)
+}
+
+// IncidentHandler shows an incident's tickets, status updates and subscribed channels.
+func (h *AppHandler) IncidentHandler(w http.ResponseWriter, r *http.Request, slackService *services.SlackService, incidentService *services.IncidentService) {
+	incidentID, ok := incidentIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+	summary, err := incidentService.Summary(incidentID)
+	if err != nil {
+		http.Error(w, "Unable to retrieve incident", http.StatusInternalServerError)
+		return
+	}
+	if summary == nil {
+		http.NotFound(w, r)
+		return
+	}
+	updates, err := models.GetIncidentUpdates(h.DB, incidentID)
+	if err != nil {
+		http.Error(w, "Unable to retrieve incident updates", http.StatusInternalServerError)
+		return
+	}
+	channels, err := models.GetIncidentChannels(h.DB, incidentID)
+	if err != nil {
+		http.Error(w, "Unable to retrieve incident channels", http.StatusInternalServerError)
+		return
+	}
+
+	// Show subscribed channels by name where Slack knows them
+	slackChannels := slackChannelOptions(slackService)
+	channelNames := make(map[string]string)
+	for _, channel := range slackChannels {
+		channelNames[channel.ID] = channel.Name
+	}
+	var subscribed []SlackChannelOption
+	for _, channel := range channels {
+		name := channelNames[channel.ChannelID]
+		if name == "" {
+			name = channel.ChannelID
+		}
+		subscribed = append(subscribed, SlackChannelOption{ID: channel.ChannelID, Name: name})
+	}
+
+	data, err := h.getCommonData(r, "Incident: "+summary.Name)
+	if err != nil {
+		http.Error(w, "Unable to retrieve common data", http.StatusInternalServerError)
+		return
+	}
+	data["Incident"] = summary
+	data["Updates"] = updates
+	data["Subscribed"] = subscribed
+	data["SlackChannels"] = slackChannels
+	data["ZendeskSubdomain"], _ = models.GetConfiguration(h.DB, "zendesk_subdomain")
+
+	h.renderTemplate(w, "templates/admin/incident.html", data)
+}
+
+// IncidentUpdateHandler broadcasts a status update to the incident's channels.
+func (h *AppHandler) IncidentUpdateHandler(w http.ResponseWriter, r *http.Request, incidentService *services.IncidentService) {
+	incidentID, ok := incidentIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+	message := strings.TrimSpace(r.FormValue("message"))
+	if message == "" {
+		http.Error(w, "An update message is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := incidentService.PostUpdate(r.Context(), incidentID, message, h.getCurrentUser(r).Email); err != nil {
+		log.Printf("Error posting update to incident %d: %v", incidentID, err)
+	}
+	http.Redirect(w, r, "/admin/incidents/"+strconv.FormatInt(incidentID, 10), http.StatusSeeOther)
+}
+
+// ResolveIncidentHandler resolves an incident, so matching tickets alert individually again.
+func (h *AppHandler) ResolveIncidentHandler(w http.ResponseWriter, r *http.Request, incidentService *services.IncidentService) {
+	incidentID, ok := incidentIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	message := strings.TrimSpace(r.FormValue("message"))
+	if err := incidentService.Resolve(r.Context(), incidentID, message, h.getCurrentUser(r).Email); err != nil {
+		log.Printf("Error resolving incident %d: %v", incidentID, err)
+	}
+	http.Redirect(w, r, "/admin/incidents/"+strconv.FormatInt(incidentID, 10), http.StatusSeeOther)
+}
+
+// SubscribeIncidentHandler starts the incident's thread in another channel.
+func (h *AppHandler) SubscribeIncidentHandler(w http.ResponseWriter, r *http.Request, incidentService *services.IncidentService) {
+	incidentID, ok := incidentIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+	channelID := r.FormValue("channel_id")
+	if channelID == "" {
+		http.Error(w, "A Slack channel is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := incidentService.Subscribe(r.Context(), incidentID, channelID); err != nil {
+		log.Printf("Error subscribing channel %s to incident %d: %v", channelID, incidentID, err)
+		http.Error(w, "Unable to post the incident to that channel", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/incidents/"+strconv.FormatInt(incidentID, 10), http.StatusSeeOther)
+}
+
+// UnsubscribeIncidentHandler stops posting the incident's updates to a channel.
+func (h *AppHandler) UnsubscribeIncidentHandler(w http.ResponseWriter, r *http.Request) {
+	incidentID, ok := incidentIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteIncidentChannel(r.Context(), h.DB, incidentID, mux.Vars(r)["channelID"]); err != nil {
+		http.Error(w, "Unable to unsubscribe channel", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/incidents/"+strconv.FormatInt(incidentID, 10), http.StatusSeeOther)
+}
+
+// incidentIDFromRequest parses the incident ID from the URL, replying with an
+// error when it is invalid.
+func incidentIDFromRequest(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	incidentID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid incident ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return incidentID, true
+}
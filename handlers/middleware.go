@@ -64,3 +64,13 @@ func AdminMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// IsAdminRequest reports whether the request's session belongs to an administrator.
+func IsAdminRequest(r *http.Request) bool {
+	session, err := store.Get(r, "session-name")
+	if err != nil {
+		return false
+	}
+	role, ok := session.Values["role"].(models.Role)
+	return ok && role == models.AdminRole
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/TylerConlee/TicketPulse/services"
+	"github.com/gorilla/mux"
+)
+
+// slackChannelNames maps Slack channel IDs to their names for display.
+func slackChannelNames(slackService *services.SlackService) map[string]string {
+	names := make(map[string]string)
+	for _, channel := range slackChannelOptions(slackService) {
+		names[channel.ID] = channel.Name
+	}
+	return names
+}
+
+// TicketsHandler lists the user's watchlist and every muted ticket, and opens a ticket by its ID.
+func (h *AppHandler) TicketsHandler(w http.ResponseWriter, r *http.Request, slackService *services.SlackService) {
+	if id := r.URL.Query().Get("id"); id != "" {
+		ticketID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid ticket ID", http.StatusBadRequest)
+			return
+		}
+		http.Redirect(w, r, ticketURL(ticketID), http.StatusSeeOther)
+		return
+	}
+
+	mutes, err := models.GetActiveTicketMutes(h.DB)
+	if err != nil {
+		http.Error(w, "Unable to retrieve muted tickets", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := h.getCommonData(r, "Tickets")
+	if err != nil {
+		http.Error(w, "Unable to retrieve common data", http.StatusInternalServerError)
+		return
+	}
+	watches, err := models.GetWatchedTickets(h.DB, data["User"].(models.User).ID)
+	if err != nil {
+		http.Error(w, "Unable to retrieve watched tickets", http.StatusInternalServerError)
+		return
+	}
+	data["Watches"] = watches
+	data["Mutes"] = mutes
+	data["ChannelNames"] = slackChannelNames(slackService)
+
+	h.renderTemplate(w, "templates/tickets.html", data)
+}
+
+// TicketHandler shows a ticket with its mutes and a form to mute it.
+func (h *AppHandler) TicketHandler(w http.ResponseWriter, r *http.Request, slackService *services.SlackService) {
+	ticketID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ticket ID", http.StatusBadRequest)
+		return
+	}
+
+	mutes, err := models.GetTicketMutes(h.DB, ticketID)
+	if err != nil {
+		http.Error(w, "Unable to retrieve ticket mutes", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := h.getCommonData(r, "Ticket #"+strconv.FormatInt(ticketID, 10))
+	if err != nil {
+		http.Error(w, "Unable to retrieve common data", http.StatusInternalServerError)
+		return
+	}
+
+	// The ticket details are a convenience; mutes can be managed without them
+	if zc, err := services.NewZendeskClient(h.DB); err == nil {
+		ticket, err := zc.GetTicketByID(ticketID)
+		if err != nil {
+			log.Printf("Error fetching Ticket #%d from Zendesk: %v", ticketID, err)
+		}
+		data["Ticket"] = ticket
+		data["ZendeskSubdomain"] = zc.Subdomain
+	}
+	watching, err := models.IsWatchingTicket(h.DB, data["User"].(models.User).ID, ticketID)
+	if err != nil {
+		http.Error(w, "Unable to retrieve watchlist", http.StatusInternalServerError)
+		return
+	}
+	data["TicketID"] = ticketID
+	data["Watching"] = watching
+	data["Mutes"] = mutes
+	data["MuteOptions"] = services.MuteOptions
+	data["SlackChannels"] = slackChannelOptions(slackService)
+	data["ChannelNames"] = slackChannelNames(slackService)
+
+	h.renderTemplate(w, "templates/ticket.html", data)
+}
+
+// MuteTicketHandler mutes a ticket for the current user or for a Slack channel.
+func (h *AppHandler) MuteTicketHandler(w http.ResponseWriter, r *http.Request) {
+	ticketID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ticket ID", http.StatusBadRequest)
+		return
+	}
+
+	user := h.getCurrentUser(r)
+	mute, err := services.NewTicketMute(ticketID, r.FormValue("duration"), user.Name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	services.RecordMuteSLAStage(h.DB, &mute)
+	if channelID := r.FormValue("channel_id"); channelID != "" {
+		mute.ChannelID = channelID
+	} else {
+		mute.UserID = sql.NullInt64{Int64: int64(user.ID), Valid: true}
+	}
+
+	if err := models.CreateTicketMute(r.Context(), h.DB, mute); err != nil {
+		log.Println("Error muting ticket:", err)
+		http.Error(w, "Unable to mute ticket", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, ticketURL(ticketID), http.StatusSeeOther)
+}
+
+// UnmuteTicketHandler ends one of a ticket's mutes.
+func (h *AppHandler) UnmuteTicketHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	ticketID, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ticket ID", http.StatusBadRequest)
+		return
+	}
+	muteID, err := strconv.ParseInt(vars["muteID"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid mute ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DeleteTicketMute(r.Context(), h.DB, ticketID, muteID); err != nil {
+		http.Error(w, "Unable to unmute ticket", http.StatusInternalServerError)
+		return
+	}
+
+	if r.FormValue("return") == "list" {
+		http.Redirect(w, r, "/tickets", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, ticketURL(ticketID), http.StatusSeeOther)
+}
+
+func ticketURL(ticketID int64) string {
+	return "/tickets/" + strconv.FormatInt(ticketID, 10)
+}
@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/TylerConlee/TicketPulse/services"
+	"github.com/gorilla/mux"
+)
+
+// maxCalendarUpload limits the size of iCalendar files imported as overrides.
+const maxCalendarUpload = 5 << 20
+
+// OnCallScheduleStatus is a schedule along with who is on call for it now.
+type OnCallScheduleStatus struct {
+	Schedule models.OnCallSchedule
+	Shift    *services.OnCallShift
+}
+
+// onCallStatuses lists every schedule with its current on-call shift.
+func (h *AppHandler) onCallStatuses() ([]OnCallScheduleStatus, error) {
+	schedules, err := models.GetAllOnCallSchedules(h.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var statuses []OnCallScheduleStatus
+	for _, schedule := range schedules {
+		shift, err := services.CurrentOnCall(h.DB, schedule.ID, now)
+		if err != nil {
+			log.Printf("Error resolving on-call for schedule %d: %v", schedule.ID, err)
+		}
+		statuses = append(statuses, OnCallScheduleStatus{Schedule: schedule, Shift: shift})
+	}
+	return statuses, nil
+}
+
+// OnCallHandler shows who is currently on call for every schedule.
+func (h *AppHandler) OnCallHandler(w http.ResponseWriter, r *http.Request) {
+	statuses, err := h.onCallStatuses()
+	if err != nil {
+		http.Error(w, "Unable to retrieve on-call schedules", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := h.getCommonData(r, "On Call")
+	if err != nil {
+		http.Error(w, "Unable to retrieve common data", http.StatusInternalServerError)
+		return
+	}
+	data["Schedules"] = statuses
+
+	h.renderTemplate(w, "templates/oncall.html", data)
+}
+
+// OnCallManagementHandler lists on-call schedules and creates new ones.
+func (h *AppHandler) OnCallManagementHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		schedule, err := onCallScheduleFromForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := models.CreateOnCallSchedule(h.DB, schedule.Name, schedule.RotationStart, schedule.RotationDays); err != nil {
+			log.Println("Error creating on-call schedule:", err)
+			http.Error(w, "Unable to create on-call schedule", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/admin/oncall", http.StatusSeeOther)
+		return
+	}
+
+	statuses, err := h.onCallStatuses()
+	if err != nil {
+		http.Error(w, "Unable to retrieve on-call schedules", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := h.getCommonData(r, "On-Call Schedules")
+	if err != nil {
+		http.Error(w, "Unable to retrieve common data", http.StatusInternalServerError)
+		return
+	}
+	data["Schedules"] = statuses
+
+	h.renderTemplate(w, "templates/admin/oncall.html", data)
+}
+
+func (h *AppHandler) DeleteOnCallScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	scheduleID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid schedule ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DeleteOnCallSchedule(h.DB, scheduleID); err != nil {
+		http.Error(w, "Unable to delete on-call schedule", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/oncall", http.StatusSeeOther)
+}
+
+// EditOnCallScheduleHandler shows a schedule's rotation and overrides, and updates its rotation.
+func (h *AppHandler) EditOnCallScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	scheduleID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid schedule ID", http.StatusBadRequest)
+		return
+	}
+
+	schedule, err := models.GetOnCallScheduleByID(h.DB, scheduleID)
+	if err != nil {
+		http.Error(w, "On-call schedule not found", http.StatusNotFound)
+		return
+	}
+
+	if r.Method == "POST" {
+		updated, err := onCallScheduleFromForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		updated.ID = scheduleID
+		if err := models.UpdateOnCallSchedule(h.DB, updated); err != nil {
+			log.Println("Error updating on-call schedule:", err)
+			http.Error(w, "Unable to update on-call schedule", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, onCallScheduleURL(scheduleID), http.StatusSeeOther)
+		return
+	}
+
+	members, err := models.GetOnCallMembers(h.DB, scheduleID)
+	if err != nil {
+		http.Error(w, "Unable to retrieve schedule members", http.StatusInternalServerError)
+		return
+	}
+	overrides, err := models.GetUpcomingOnCallOverrides(h.DB, scheduleID, time.Now())
+	if err != nil {
+		http.Error(w, "Unable to retrieve overrides", http.StatusInternalServerError)
+		return
+	}
+	users, err := models.GetAllUsers(h.DB)
+	if err != nil {
+		http.Error(w, "Unable to retrieve users", http.StatusInternalServerError)
+		return
+	}
+	shift, err := services.CurrentOnCall(h.DB, scheduleID, time.Now())
+	if err != nil {
+		log.Printf("Error resolving on-call for schedule %d: %v", scheduleID, err)
+	}
+
+	data, err := h.getCommonData(r, schedule.Name)
+	if err != nil {
+		http.Error(w, "Unable to retrieve common data", http.StatusInternalServerError)
+		return
+	}
+	data["Schedule"] = schedule
+	data["Shift"] = shift
+	data["Members"] = members
+	data["Overrides"] = overrides
+	data["Users"] = users
+	data["Imported"] = r.URL.Query().Get("imported")
+	data["Skipped"] = r.URL.Query().Get("skipped")
+
+	h.renderTemplate(w, "templates/admin/edit_oncall.html", data)
+}
+
+func (h *AppHandler) AddOnCallMemberHandler(w http.ResponseWriter, r *http.Request) {
+	scheduleID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid schedule ID", http.StatusBadRequest)
+		return
+	}
+	userID, err := strconv.Atoi(r.FormValue("user_id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.AddOnCallMember(h.DB, scheduleID, userID); err != nil {
+		log.Println("Error adding on-call member:", err)
+		http.Error(w, "Unable to add member", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, onCallScheduleURL(scheduleID), http.StatusSeeOther)
+}
+
+func (h *AppHandler) RemoveOnCallMemberHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	scheduleID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid schedule ID", http.StatusBadRequest)
+		return
+	}
+	userID, err := strconv.Atoi(vars["userID"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.RemoveOnCallMember(h.DB, scheduleID, userID); err != nil {
+		http.Error(w, "Unable to remove member", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, onCallScheduleURL(scheduleID), http.StatusSeeOther)
+}
+
+// AddOnCallOverrideHandler puts a user on call for a period entered in the server's time zone.
+func (h *AppHandler) AddOnCallOverrideHandler(w http.ResponseWriter, r *http.Request) {
+	scheduleID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid schedule ID", http.StatusBadRequest)
+		return
+	}
+	userID, err := strconv.Atoi(r.FormValue("user_id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	startsAt, err := time.ParseInLocation(dateTimeLocalFormat, r.FormValue("starts_at"), time.Local)
+	if err != nil {
+		http.Error(w, "Invalid override start", http.StatusBadRequest)
+		return
+	}
+	endsAt, err := time.ParseInLocation(dateTimeLocalFormat, r.FormValue("ends_at"), time.Local)
+	if err != nil || !endsAt.After(startsAt) {
+		http.Error(w, "Invalid override end", http.StatusBadRequest)
+		return
+	}
+
+	err = models.SaveOnCallOverride(r.Context(), h.DB, models.OnCallOverride{
+		ScheduleID: scheduleID,
+		UserID:     userID,
+		StartsAt:   startsAt,
+		EndsAt:     endsAt,
+	})
+	if err != nil {
+		log.Println("Error adding on-call override:", err)
+		http.Error(w, "Unable to add override", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, onCallScheduleURL(scheduleID), http.StatusSeeOther)
+}
+
+func (h *AppHandler) DeleteOnCallOverrideHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	scheduleID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid schedule ID", http.StatusBadRequest)
+		return
+	}
+	overrideID, err := strconv.Atoi(vars["overrideID"])
+	if err != nil {
+		http.Error(w, "Invalid override ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DeleteOnCallOverride(h.DB, scheduleID, overrideID); err != nil {
+		http.Error(w, "Unable to delete override", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, onCallScheduleURL(scheduleID), http.StatusSeeOther)
+}
+
+// ImportOnCallCalendarHandler imports overrides from an uploaded iCalendar file.
+func (h *AppHandler) ImportOnCallCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	scheduleID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid schedule ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseMultipartForm(maxCalendarUpload); err != nil {
+		http.Error(w, "Invalid calendar upload", http.StatusBadRequest)
+		return
+	}
+	file, _, err := r.FormFile("calendar")
+	if err != nil {
+		http.Error(w, "Choose an iCalendar file to import", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	imported, skipped, err := services.ImportOnCallOverrides(r.Context(), h.DB, scheduleID, file)
+	if err != nil {
+		log.Println("Error importing on-call calendar:", err)
+		http.Error(w, "Unable to import calendar: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := url.Values{}
+	query.Set("imported", strconv.Itoa(imported))
+	query.Set("skipped", strconv.Itoa(skipped))
+	http.Redirect(w, r, onCallScheduleURL(scheduleID)+"?"+query.Encode(), http.StatusSeeOther)
+}
+
+func onCallScheduleURL(scheduleID int) string {
+	return "/admin/oncall/" + strconv.Itoa(scheduleID)
+}
+
+// onCallScheduleFromForm reads the schedule name and rotation. The rotation
+// start is entered in the server's time zone.
+func onCallScheduleFromForm(r *http.Request) (models.OnCallSchedule, error) {
+	schedule := models.OnCallSchedule{Name: strings.TrimSpace(r.FormValue("name"))}
+	if schedule.Name == "" {
+		return schedule, fmt.Errorf("schedule name is required")
+	}
+	rotationStart, err := time.ParseInLocation(dateTimeLocalFormat, r.FormValue("rotation_start"), time.Local)
+	if err != nil {
+		return schedule, fmt.Errorf("invalid rotation start")
+	}
+	schedule.RotationStart = rotationStart
+	rotationDays, err := strconv.Atoi(r.FormValue("rotation_days"))
+	if err != nil || rotationDays < 1 {
+		return schedule, fmt.Errorf("rotation length must be at least one day")
+	}
+	schedule.RotationDays = rotationDays
+	return schedule, nil
+}
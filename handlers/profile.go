@@ -3,6 +3,7 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"html/template"
 	"log"
 	"net/http"
@@ -16,7 +17,7 @@ import (
 )
 
 // ProfileHandler handles requests related to the user's profile.
-func (h *AppHandler) ProfileHandler(w http.ResponseWriter, r *http.Request, slackService *services.SlackService) {
+func (h *AppHandler) ProfileHandler(w http.ResponseWriter, r *http.Request, slackService *services.SlackService, notificationService *services.NotificationService) {
 	session, _ := store.Get(r, "session-name")
 	userID := session.Values["user_id"].(int)
 
@@ -76,13 +77,89 @@ func (h *AppHandler) ProfileHandler(w http.ResponseWriter, r *http.Request, slac
 		return
 	}
 
+	// Handle updating notification delivery preferences
+	if r.Method == "POST" && r.URL.Path == "/profile/update-notification-preferences" {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Invalid form submission", http.StatusBadRequest)
+			return
+		}
+
+		webhookURL := strings.TrimSpace(r.FormValue("webhook_url"))
+		if webhookURL != "" {
+			if err := services.ValidateWebhookURL(webhookURL); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if err := models.UpdateWebhookURL(h.DB, userID, webhookURL); err != nil {
+			log.Printf("Error updating webhook URL: %v", err)
+			http.Error(w, "Unable to update webhook URL", http.StatusInternalServerError)
+			return
+		}
+
+		err := notificationService.SavePreferences(userID, func(alertType, channel string) bool {
+			return r.FormValue("pref_"+alertType+"_"+channel) == "on"
+		})
+		if err != nil {
+			log.Printf("Error saving notification preferences: %v", err)
+			http.Error(w, "Unable to save notification preferences", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/profile", http.StatusSeeOther)
+		return
+	}
+
+	// Handle updating quiet hours
+	if r.Method == "POST" && r.URL.Path == "/profile/update-quiet-hours" {
+		quiet, err := quietHoursFromForm(r, userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := models.SaveQuietHours(h.DB, quiet); err != nil {
+			log.Printf("Error saving quiet hours: %v", err)
+			http.Error(w, "Unable to save quiet hours", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/profile", http.StatusSeeOther)
+		return
+	}
+
+	// Handle declaring or clearing an out-of-office absence
+	if r.Method == "POST" && r.URL.Path == "/profile/update-absence" {
+		absence, err := absenceFromForm(r, userID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := models.SetAbsence(h.DB, absence); err != nil {
+			log.Printf("Error saving absence: %v", err)
+			http.Error(w, "Unable to save out of office", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/profile", http.StatusSeeOther)
+		return
+	}
+	if r.Method == "POST" && r.URL.Path == "/profile/clear-absence" {
+		if err := models.DeleteAbsence(h.DB, userID); err != nil {
+			log.Printf("Error clearing absence: %v", err)
+			http.Error(w, "Unable to clear out of office", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/profile", http.StatusSeeOther)
+		return
+	}
+
 	// Handle adding a new tag alert
 	if r.Method == "POST" && r.URL.Path == "/profile/add-tag" {
-		tag := r.FormValue("tag")
-		slackChannelID := r.FormValue("slack_channel")
-		alertType := r.FormValue("alert_type")
+		alert, err := tagAlertFromForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		alert.UserID = userID
 
-		if err := models.CreateTagAlert(h.DB, userID, tag, slackChannelID, alertType); err != nil {
+		if err := models.CreateTagAlert(h.DB, alert); err != nil {
 			http.Error(w, "Unable to add tag alert", http.StatusInternalServerError)
 			return
 		}
@@ -92,12 +169,21 @@ func (h *AppHandler) ProfileHandler(w http.ResponseWriter, r *http.Request, slac
 
 	// Handle deleting a tag alert
 	if r.Method == "POST" && strings.HasPrefix(r.URL.Path, "/profile/delete-tag/") {
-		alertID, _ := strconv.Atoi(mux.Vars(r)["id"])
+		alertID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid tag alert ID", http.StatusBadRequest)
+			return
+		}
 
-		if err := models.DeleteTagAlert(h.DB, alertID); err != nil {
+		deleted, err := models.DeleteUserTagAlert(h.DB, alertID, userID)
+		if err != nil {
 			http.Error(w, "Unable to delete tag alert", http.StatusInternalServerError)
 			return
 		}
+		if !deleted {
+			http.Error(w, "Tag alert not found", http.StatusNotFound)
+			return
+		}
 		http.Redirect(w, r, "/profile", http.StatusSeeOther)
 		return
 	}
@@ -109,28 +195,59 @@ func (h *AppHandler) ProfileHandler(w http.ResponseWriter, r *http.Request, slac
 		return
 	}
 
-	// Fetch available Slack channels
-	channels := []struct {
-		ID   string
-		Name string
-	}{}
-	if slackService != nil && slackService.IsReady() {
-		slackChannels, err := slackService.GetConversations()
-		if err == nil {
-			for _, channel := range slackChannels {
-				channels = append(channels, struct {
-					ID   string
-					Name string
-				}{
-					ID:   channel.ID,
-					Name: channel.Name,
-				})
-			}
-		} else {
-			log.Println("Error fetching Slack channels:", err)
+	// Build the notification preferences matrix
+	preferenceRows, err := notificationService.PreferenceMatrix(userID)
+	if err != nil {
+		http.Error(w, "Unable to retrieve notification preferences", http.StatusInternalServerError)
+		return
+	}
+
+	quietHours, err := models.GetQuietHours(h.DB, userID)
+	if err != nil {
+		http.Error(w, "Unable to retrieve quiet hours", http.StatusInternalServerError)
+		return
+	}
+	absence, err := models.GetAbsence(h.DB, userID)
+	if err != nil {
+		http.Error(w, "Unable to retrieve out of office", http.StatusInternalServerError)
+		return
+	}
+	coveredAbsences, err := models.GetAbsencesCoveredBy(h.DB, userID)
+	if err != nil {
+		http.Error(w, "Unable to retrieve out of office", http.StatusInternalServerError)
+		return
+	}
+	heldCount, err := models.CountHeldNotifications(h.DB, userID)
+	if err != nil {
+		http.Error(w, "Unable to retrieve held notifications", http.StatusInternalServerError)
+		return
+	}
+	users, err := models.GetAllUsers(h.DB)
+	if err != nil {
+		http.Error(w, "Unable to retrieve users", http.StatusInternalServerError)
+		return
+	}
+	var backupUsers []models.User
+	for _, u := range users {
+		if u.ID != userID {
+			backupUsers = append(backupUsers, u)
 		}
 	}
 
+	// Fetch available Slack channels
+	channels := slackChannelOptions(slackService)
+
+	onCallSchedules, err := models.GetAllOnCallSchedules(h.DB)
+	if err != nil {
+		http.Error(w, "Unable to retrieve on-call schedules", http.StatusInternalServerError)
+		return
+	}
+	assignTeams, err := models.GetAllTeams(h.DB)
+	if err != nil {
+		http.Error(w, "Unable to retrieve teams", http.StatusInternalServerError)
+		return
+	}
+
 	// Prepare common data for the template
 	data, err := h.getCommonData(r, "Profile")
 	if err != nil {
@@ -138,9 +255,26 @@ func (h *AppHandler) ProfileHandler(w http.ResponseWriter, r *http.Request, slac
 		return
 	}
 	data["SlackChannels"] = channels
+	data["OnCallSchedules"] = onCallSchedules
+	data["AssignTeams"] = assignTeams
 	data["TagAlerts"] = tagAlerts
 	data["User"] = user
+	h.addTagCatalogData(data, tagAlerts)
 	data["SummaryTime"] = summaryTime
+	data["NotificationChannels"] = notificationService.Channels()
+	data["NotificationPreferences"] = preferenceRows
+	data["QuietHours"] = quietHours
+	data["QuietDays"] = weekdayOptions(quietHours.HasDay)
+	data["QuietBackupID"] = int(quietHours.BackupUserID.Int64)
+	data["HeldCount"] = heldCount
+	data["Absence"] = absence
+	data["AbsenceActive"] = absence != nil && absence.Active(time.Now())
+	data["CoveredAbsences"] = coveredAbsences
+	data["AbsenceDelegateID"] = 0
+	if absence != nil {
+		data["AbsenceDelegateID"] = int(absence.DelegateUserID.Int64)
+	}
+	data["BackupUsers"] = backupUsers
 
 	// Render the template
 	t := template.Must(template.ParseFiles("templates/layout.html", "templates/profile.html"))
@@ -150,8 +284,120 @@ func (h *AppHandler) ProfileHandler(w http.ResponseWriter, r *http.Request, slac
 	}
 }
 
+// thresholdFromForm reads the threshold and its unit from the form in minutes.
+func thresholdFromForm(r *http.Request) (int, error) {
+	threshold, err := strconv.Atoi(strings.TrimSpace(r.FormValue("threshold")))
+	if err != nil || threshold <= 0 {
+		return 0, fmt.Errorf("invalid threshold")
+	}
+	switch r.FormValue("threshold_unit") {
+	case "hours":
+		threshold *= 60
+	case "days":
+		threshold *= 24 * 60
+	}
+	return threshold, nil
+}
+
+// tagAlertFromForm reads the tag alert fields shared by the profile and team rule forms.
+func tagAlertFromForm(r *http.Request) (models.TagAlert, error) {
+	alert := models.TagAlert{
+		Tag:            strings.TrimSpace(r.FormValue("tag")),
+		SlackChannelID: r.FormValue("slack_channel"),
+		AlertType:      r.FormValue("alert_type"),
+		PagerDuty:      r.FormValue("pagerduty") == "on",
+		Organization:   strings.TrimSpace(r.FormValue("organization")),
+		VIPOnly:        r.FormValue("vip_only") == "on",
+
+		Keywords:             strings.TrimSpace(r.FormValue("keywords")),
+		KeywordRegex:         r.FormValue("keyword_regex") == "on",
+		KeywordCaseSensitive: r.FormValue("keyword_case_sensitive") == "on",
+		KeywordWholeWord:     r.FormValue("keyword_whole_word") == "on",
+	}
+	if alert.Tag == "" && alert.Organization == "" && !alert.VIPOnly && alert.Keywords == "" {
+		return alert, fmt.Errorf("a tag, organization, VIP, or keyword filter is required")
+	}
+	if err := services.ValidateTagPattern(alert.Tag); err != nil {
+		return alert, err
+	}
+	if _, err := services.KeywordPattern(alert); err != nil {
+		return alert, err
+	}
+
+	if value := strings.TrimSpace(r.FormValue("digest_interval")); value != "" {
+		interval, err := strconv.Atoi(value)
+		if err != nil || interval < 0 {
+			return alert, fmt.Errorf("invalid digest interval")
+		}
+		alert.DigestInterval = interval
+	}
+	if value := r.FormValue("oncall_schedule_id"); value != "" {
+		scheduleID, err := strconv.Atoi(value)
+		if err != nil {
+			return alert, fmt.Errorf("invalid on-call schedule")
+		}
+		alert.OnCallScheduleID = sql.NullInt64{Int64: int64(scheduleID), Valid: true}
+	}
+	if value := strings.TrimSpace(r.FormValue("group_id")); value != "" && alert.AlertType == services.AlertTypeGroupChanged {
+		groupID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || groupID < 0 {
+			return alert, fmt.Errorf("invalid group ID")
+		}
+		alert.GroupID = groupID
+	}
+	if services.IsAgeAlertType(alert.AlertType) {
+		threshold, err := thresholdFromForm(r)
+		if err != nil {
+			return alert, fmt.Errorf("age alerts need a threshold")
+		}
+		alert.ThresholdMinutes = threshold
+	}
+	if alert.AlertType == services.AlertTypeVolumeSpike {
+		if alert.Keywords != "" {
+			return alert, fmt.Errorf("volume spike alerts cannot filter by keyword")
+		}
+		factor, err := strconv.ParseFloat(strings.TrimSpace(r.FormValue("spike_factor")), 64)
+		if err != nil || factor <= 1 {
+			return alert, fmt.Errorf("volume spike alerts need a spike factor above 1")
+		}
+		alert.SpikeFactor = factor
+		if strings.TrimSpace(r.FormValue("threshold")) != "" {
+			window, err := thresholdFromForm(r)
+			if err != nil {
+				return alert, fmt.Errorf("invalid volume spike window")
+			}
+			alert.ThresholdMinutes = window
+		}
+	}
+	if value := r.FormValue("assign_team_id"); value != "" {
+		if alert.AlertType != services.AlertTypeNewTicket {
+			return alert, fmt.Errorf("only new ticket alerts can assign tickets")
+		}
+		teamID, err := strconv.Atoi(value)
+		if err != nil {
+			return alert, fmt.Errorf("invalid assignment team")
+		}
+		alert.AssignTeamID = sql.NullInt64{Int64: int64(teamID), Valid: true}
+		if value := strings.TrimSpace(r.FormValue("assign_max_open")); value != "" {
+			maxOpen, err := strconv.Atoi(value)
+			if err != nil || maxOpen < 0 {
+				return alert, fmt.Errorf("invalid open ticket cap")
+			}
+			alert.AssignMaxOpen = maxOpen
+		}
+	}
+	if value := strings.TrimSpace(r.FormValue("rate_limit")); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			return alert, fmt.Errorf("invalid rate limit")
+		}
+		alert.RateLimit = limit
+	}
+	return alert, nil
+}
+
 // OnDemandSummaryHandler handles the on-demand summary generation.
-func (h *AppHandler) OnDemandSummaryHandler(w http.ResponseWriter, r *http.Request, slackService *services.SlackService) {
+func (h *AppHandler) OnDemandSummaryHandler(w http.ResponseWriter, r *http.Request, notificationService *services.NotificationService) {
 	session, _ := store.Get(r, "session-name")
 	userEmail, ok := session.Values["user_email"].(string)
 	if !ok || userEmail == "" {
@@ -168,7 +414,7 @@ func (h *AppHandler) OnDemandSummaryHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	summary, err := zendeskClient.GenerateDailySummary(userEmail, slackService)
+	summary, err := zendeskClient.GenerateDailySummary(userEmail, notificationService)
 	if err != nil {
 		log.Printf("Error generating summary: %v", err)
 		http.Error(w, "Failed to generate summary", http.StatusInternalServerError)
@@ -178,3 +424,118 @@ func (h *AppHandler) OnDemandSummaryHandler(w http.ResponseWriter, r *http.Reque
 	// Return the summary as JSON
 	json.NewEncoder(w).Encode(map[string]string{"message": summary})
 }
+
+// PreviewTagAlertHandler evaluates an unsaved tag alert against open tickets and
+// recent activity and returns the result as JSON. Nothing is sent.
+func (h *AppHandler) PreviewTagAlertHandler(w http.ResponseWriter, r *http.Request) {
+	alert, err := tagAlertFromForm(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	zendeskClient, err := services.NewZendeskClient(h.DB)
+	if err != nil {
+		log.Printf("Error creating Zendesk client: %v", err)
+		http.Error(w, "Failed to create Zendesk client", http.StatusInternalServerError)
+		return
+	}
+
+	preview, err := zendeskClient.PreviewRule(alert)
+	if err != nil {
+		log.Printf("Error previewing tag alert: %v", err)
+		http.Error(w, "Failed to preview tag alert", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preview)
+}
+
+// WeekdayOption is a weekday checkbox in the quiet hours and shift forms.
+type WeekdayOption struct {
+	Value   int
+	Label   string
+	Checked bool
+}
+
+// weekdayOptions lists the weekdays for a form, starting on Monday.
+func weekdayOptions(hasDay func(time.Weekday) bool) []WeekdayOption {
+	var days []WeekdayOption
+	for i := 1; i <= 7; i++ {
+		day := time.Weekday(i % 7)
+		days = append(days, WeekdayOption{Value: int(day), Label: day.String()[:3], Checked: hasDay(day)})
+	}
+	return days
+}
+
+// quietHoursFromForm reads the quiet hours form on the profile page.
+func quietHoursFromForm(r *http.Request, userID int) (models.QuietHours, error) {
+	if err := r.ParseForm(); err != nil {
+		return models.QuietHours{}, fmt.Errorf("invalid form submission")
+	}
+
+	quiet := models.QuietHours{
+		UserID:        userID,
+		Enabled:       r.FormValue("quiet_enabled") == "on",
+		Start:         r.FormValue("quiet_start"),
+		End:           r.FormValue("quiet_end"),
+		Days:          strings.Join(r.Form["quiet_days"], ","),
+		Action:        models.QuietHoursAction(r.FormValue("quiet_action")),
+		AllowBreaches: r.FormValue("quiet_allow_breaches") == "on",
+	}
+	if _, err := time.Parse("15:04", quiet.Start); err != nil {
+		return quiet, fmt.Errorf("invalid quiet hours start time")
+	}
+	if _, err := time.Parse("15:04", quiet.End); err != nil {
+		return quiet, fmt.Errorf("invalid quiet hours end time")
+	}
+
+	switch quiet.Action {
+	case models.QuietHoursHold, models.QuietHoursDrop:
+	case models.QuietHoursReroute:
+		backupID, err := strconv.Atoi(r.FormValue("quiet_backup_user_id"))
+		if err != nil || backupID == userID {
+			return quiet, fmt.Errorf("choose a backup to reroute alerts to")
+		}
+		quiet.BackupUserID = sql.NullInt64{Int64: int64(backupID), Valid: true}
+	default:
+		return quiet, fmt.Errorf("invalid quiet hours action")
+	}
+	return quiet, nil
+}
+
+// dateTimeLocalFormat is the layout used by datetime-local inputs.
+const dateTimeLocalFormat = "2006-01-02T15:04"
+
+// absenceFromForm reads the out of office form on the profile page. Times are
+// entered in the server's time zone.
+func absenceFromForm(r *http.Request, userID int) (models.Absence, error) {
+	absence := models.Absence{UserID: userID, DelegateChannel: r.FormValue("delegate_channel")}
+
+	awayFrom, err := time.ParseInLocation(dateTimeLocalFormat, r.FormValue("away_from"), time.Local)
+	if err != nil {
+		return absence, fmt.Errorf("invalid out of office start")
+	}
+	awayUntil, err := time.ParseInLocation(dateTimeLocalFormat, r.FormValue("away_until"), time.Local)
+	if err != nil {
+		return absence, fmt.Errorf("invalid out of office end")
+	}
+	if !awayUntil.After(awayFrom) {
+		return absence, fmt.Errorf("out of office must end after it starts")
+	}
+	absence.AwayFrom = awayFrom
+	absence.AwayUntil = awayUntil
+
+	if value := r.FormValue("delegate_user_id"); value != "" {
+		delegateID, err := strconv.Atoi(value)
+		if err != nil || delegateID == userID {
+			return absence, fmt.Errorf("invalid delegate")
+		}
+		absence.DelegateUserID = sql.NullInt64{Int64: int64(delegateID), Valid: true}
+	}
+	if !absence.DelegateUserID.Valid && absence.DelegateChannel == "" {
+		return absence, fmt.Errorf("choose a delegate or a Slack channel to send your alerts to")
+	}
+	return absence, nil
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/TylerConlee/TicketPulse/services"
+	"github.com/gorilla/mux"
+)
+
+// TagAliasesHandler lists the tag aliases and adds new ones.
+func (h *AppHandler) TagAliasesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		alias := models.TagAlias{
+			Alias:     strings.TrimSpace(r.FormValue("alias")),
+			Canonical: strings.TrimSpace(r.FormValue("canonical")),
+		}
+		if alias.Alias == "" || alias.Canonical == "" {
+			http.Error(w, "An alias and a canonical tag are required", http.StatusBadRequest)
+			return
+		}
+		if strings.ContainsAny(alias.Alias+alias.Canonical, " \t") || services.IsTagPattern(alias.Alias) || services.IsTagPattern(alias.Canonical) {
+			http.Error(w, "Aliases must be single tags, not patterns", http.StatusBadRequest)
+			return
+		}
+		if err := models.AddTagAlias(h.DB, alias); err != nil {
+			log.Println("Error adding tag alias:", err)
+			http.Error(w, "Unable to add tag alias", http.StatusInternalServerError)
+			return
+		}
+		h.reloadTagAliases()
+		http.Redirect(w, r, "/admin/tag-aliases", http.StatusSeeOther)
+		return
+	}
+
+	aliases, err := models.GetTagAliases(h.DB)
+	if err != nil {
+		http.Error(w, "Unable to retrieve tag aliases", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := h.getCommonData(r, "Tag Aliases")
+	if err != nil {
+		http.Error(w, "Unable to retrieve common data", http.StatusInternalServerError)
+		return
+	}
+	data["Aliases"] = aliases
+
+	h.renderTemplate(w, "templates/admin/tag_aliases.html", data)
+}
+
+func (h *AppHandler) DeleteTagAliasHandler(w http.ResponseWriter, r *http.Request) {
+	aliasID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid tag alias ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DeleteTagAlias(h.DB, aliasID); err != nil {
+		http.Error(w, "Unable to delete tag alias", http.StatusInternalServerError)
+		return
+	}
+	h.reloadTagAliases()
+
+	http.Redirect(w, r, "/admin/tag-aliases", http.StatusSeeOther)
+}
+
+// reloadTagAliases applies alias changes to rule matching straight away
+// rather than on the next poll.
+func (h *AppHandler) reloadTagAliases() {
+	if err := services.LoadTagAliases(h.DB); err != nil {
+		log.Println("Error reloading tag aliases:", err)
+	}
+}
+
+// addTagCatalogData adds the tag catalog for autocompleting the add-tag form
+// and the warnings for rules whose tag is unknown or unused. A catalog that
+// cannot be read only loses the suggestions, so errors are logged.
+func (h *AppHandler) addTagCatalogData(data map[string]interface{}, rules []models.TagAlert) {
+	catalog, err := models.GetTagCatalog(h.DB)
+	if err != nil {
+		log.Println("Error retrieving tag catalog:", err)
+	}
+	stale, err := services.StaleRuleTags(h.DB, rules)
+	if err != nil {
+		log.Println("Error checking rule tags:", err)
+	}
+	data["TagCatalog"] = catalog
+	data["StaleTags"] = stale
+}
+
+// SyncTagCatalogHandler refreshes the tag catalog from Zendesk straight away.
+func (h *AppHandler) SyncTagCatalogHandler(w http.ResponseWriter, r *http.Request) {
+	zc, err := services.NewZendeskClient(h.DB)
+	if err != nil {
+		http.Error(w, "Zendesk is not configured", http.StatusInternalServerError)
+		return
+	}
+	if err := zc.SyncTagCatalog(r.Context()); err != nil {
+		log.Println("Error syncing tag catalog:", err)
+		http.Error(w, "Unable to sync tags from Zendesk", http.StatusBadGateway)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/tags", http.StatusSeeOther)
+}
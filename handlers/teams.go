@@ -0,0 +1,405 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/TylerConlee/TicketPulse/services"
+	"github.com/gorilla/mux"
+)
+
+// SlackChannelOption is a Slack channel offered in rule forms.
+type SlackChannelOption struct {
+	ID   string
+	Name string
+}
+
+// slackChannelOptions lists the Slack channels available for alert rules.
+func slackChannelOptions(slackService *services.SlackService) []SlackChannelOption {
+	channels := []SlackChannelOption{}
+	if slackService == nil || !slackService.IsReady() {
+		return channels
+	}
+	slackChannels, err := slackService.GetConversations()
+	if err != nil {
+		log.Println("Error fetching Slack channels:", err)
+		return channels
+	}
+	for _, channel := range slackChannels {
+		channels = append(channels, SlackChannelOption{ID: channel.ID, Name: channel.Name})
+	}
+	return channels
+}
+
+// canManageTeam reports whether the user may change a team's rules.
+func (h *AppHandler) canManageTeam(user models.User, teamID int) bool {
+	if user.Role == models.AdminRole {
+		return true
+	}
+	isLead, err := models.IsTeamLead(h.DB, teamID, user.ID)
+	if err != nil {
+		log.Printf("Error checking team lead for user %d: %v", user.ID, err)
+		return false
+	}
+	return isLead
+}
+
+// TeamsHandler lists the teams the current user belongs to. Admins see every team.
+func (h *AppHandler) TeamsHandler(w http.ResponseWriter, r *http.Request) {
+	data, err := h.getCommonData(r, "Teams")
+	if err != nil {
+		http.Error(w, "Unable to retrieve common data", http.StatusInternalServerError)
+		return
+	}
+	user := data["User"].(models.User)
+
+	memberships, err := models.GetTeamsForUser(h.DB, user.ID)
+	if err != nil {
+		http.Error(w, "Unable to retrieve teams", http.StatusInternalServerError)
+		return
+	}
+
+	if user.Role == models.AdminRole {
+		roles := make(map[int]models.TeamRole)
+		for _, m := range memberships {
+			roles[m.TeamID] = m.Role
+		}
+		teams, err := models.GetAllTeams(h.DB)
+		if err != nil {
+			http.Error(w, "Unable to retrieve teams", http.StatusInternalServerError)
+			return
+		}
+		memberships = memberships[:0]
+		for _, team := range teams {
+			memberships = append(memberships, models.TeamMember{TeamID: team.ID, TeamName: team.Name, UserID: user.ID, Role: roles[team.ID]})
+		}
+	}
+
+	data["Teams"] = memberships
+	h.renderTemplate(w, "templates/teams.html", data)
+}
+
+// TeamHandler shows a team's members, rules and shifts, and lets team leads and admins manage the rules and shifts.
+func (h *AppHandler) TeamHandler(w http.ResponseWriter, r *http.Request, slackService *services.SlackService) {
+	teamID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return
+	}
+
+	team, err := models.GetTeamByID(h.DB, teamID)
+	if err != nil {
+		http.Error(w, "Team not found", http.StatusNotFound)
+		return
+	}
+
+	user := h.getCurrentUser(r)
+	canManage := h.canManageTeam(user, teamID)
+
+	if r.Method == "POST" {
+		if !canManage {
+			http.Error(w, "Only team leads can manage team alerts", http.StatusForbidden)
+			return
+		}
+		if strings.Contains(r.URL.Path, "-shift") {
+			h.handleTeamShiftPost(w, r, teamID)
+		} else {
+			h.handleTeamRulePost(w, r, teamID)
+		}
+		return
+	}
+
+	members, err := models.GetTeamMembers(h.DB, teamID)
+	if err != nil {
+		http.Error(w, "Unable to retrieve team members", http.StatusInternalServerError)
+		return
+	}
+
+	isMember := false
+	for _, m := range members {
+		if m.UserID == user.ID {
+			isMember = true
+		}
+	}
+	if !isMember && user.Role != models.AdminRole {
+		http.Error(w, "You are not a member of this team", http.StatusForbidden)
+		return
+	}
+
+	tagAlerts, err := models.GetTagAlertsByTeam(h.DB, teamID)
+	if err != nil {
+		http.Error(w, "Unable to retrieve tag alerts", http.StatusInternalServerError)
+		return
+	}
+
+	shifts, err := models.GetShiftsByTeam(h.DB, teamID)
+	if err != nil {
+		http.Error(w, "Unable to retrieve team shifts", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := h.getCommonData(r, team.Name)
+	if err != nil {
+		http.Error(w, "Unable to retrieve common data", http.StatusInternalServerError)
+		return
+	}
+	data["Team"] = team
+	data["Shifts"] = shifts
+	data["ShiftDays"] = weekdayOptions(models.Shift{Days: "1,2,3,4,5"}.HasDay)
+	data["Members"] = members
+	data["TagAlerts"] = tagAlerts
+	data["CanManage"] = canManage
+	h.addTagCatalogData(data, tagAlerts)
+	if canManage {
+		data["SlackChannels"] = slackChannelOptions(slackService)
+		onCallSchedules, err := models.GetAllOnCallSchedules(h.DB)
+		if err != nil {
+			http.Error(w, "Unable to retrieve on-call schedules", http.StatusInternalServerError)
+			return
+		}
+		data["OnCallSchedules"] = onCallSchedules
+		assignTeams, err := models.GetAllTeams(h.DB)
+		if err != nil {
+			http.Error(w, "Unable to retrieve teams", http.StatusInternalServerError)
+			return
+		}
+		data["AssignTeams"] = assignTeams
+	}
+
+	h.renderTemplate(w, "templates/team.html", data)
+}
+
+func (h *AppHandler) handleTeamRulePost(w http.ResponseWriter, r *http.Request, teamID int) {
+	teamURL := "/teams/" + strconv.Itoa(teamID)
+
+	if strings.HasSuffix(r.URL.Path, "/add-tag") {
+		alert, err := tagAlertFromForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		alert.TeamID = sql.NullInt64{Int64: int64(teamID), Valid: true}
+		if err := models.CreateTagAlert(h.DB, alert); err != nil {
+			http.Error(w, "Unable to add tag alert", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, teamURL, http.StatusSeeOther)
+		return
+	}
+
+	alertID, err := strconv.Atoi(mux.Vars(r)["alertID"])
+	if err != nil {
+		http.Error(w, "Invalid tag alert ID", http.StatusBadRequest)
+		return
+	}
+	alert, err := models.GetTagAlertByID(h.DB, alertID)
+	if err != nil || !alert.TeamID.Valid || int(alert.TeamID.Int64) != teamID {
+		http.Error(w, "Tag alert not found", http.StatusNotFound)
+		return
+	}
+	if err := models.DeleteTagAlert(h.DB, alertID); err != nil {
+		http.Error(w, "Unable to delete tag alert", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, teamURL, http.StatusSeeOther)
+}
+
+// handleTeamShiftPost adds or deletes one of a team's shifts.
+func (h *AppHandler) handleTeamShiftPost(w http.ResponseWriter, r *http.Request, teamID int) {
+	teamURL := "/teams/" + strconv.Itoa(teamID)
+
+	if strings.HasSuffix(r.URL.Path, "/add-shift") {
+		shift, err := shiftFromForm(r, teamID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := models.CreateShift(h.DB, shift); err != nil {
+			log.Printf("Error creating shift: %v", err)
+			http.Error(w, "Unable to add shift", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, teamURL, http.StatusSeeOther)
+		return
+	}
+
+	shiftID, err := strconv.ParseInt(mux.Vars(r)["shiftID"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid shift ID", http.StatusBadRequest)
+		return
+	}
+	shift, err := models.GetShift(h.DB, shiftID)
+	if err != nil || shift == nil || shift.TeamID != teamID {
+		http.Error(w, "Shift not found", http.StatusNotFound)
+		return
+	}
+	if err := models.DeleteShift(h.DB, shiftID); err != nil {
+		http.Error(w, "Unable to delete shift", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, teamURL, http.StatusSeeOther)
+}
+
+// shiftFromForm reads the add shift form on the team page.
+func shiftFromForm(r *http.Request, teamID int) (models.Shift, error) {
+	if err := r.ParseForm(); err != nil {
+		return models.Shift{}, fmt.Errorf("invalid form submission")
+	}
+
+	shift := models.Shift{
+		TeamID:         teamID,
+		Name:           strings.TrimSpace(r.FormValue("shift_name")),
+		Start:          r.FormValue("shift_start"),
+		End:            r.FormValue("shift_end"),
+		Days:           strings.Join(r.Form["shift_days"], ","),
+		SlackChannelID: r.FormValue("shift_channel"),
+	}
+	if shift.Name == "" {
+		return shift, fmt.Errorf("a shift needs a name")
+	}
+	if _, err := time.Parse("15:04", shift.Start); err != nil {
+		return shift, fmt.Errorf("invalid shift start time")
+	}
+	if _, err := time.Parse("15:04", shift.End); err != nil {
+		return shift, fmt.Errorf("invalid shift end time")
+	}
+	if shift.Days == "" {
+		return shift, fmt.Errorf("choose the days the shift starts on")
+	}
+	if shift.SlackChannelID == "" {
+		return shift, fmt.Errorf("a Slack channel is required for handoff reports")
+	}
+	return shift, nil
+}
+
+// TeamManagementHandler lists teams and creates new ones.
+func (h *AppHandler) TeamManagementHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		name := strings.TrimSpace(r.FormValue("name"))
+		if name == "" {
+			http.Error(w, "Team name is required", http.StatusBadRequest)
+			return
+		}
+		if err := models.CreateTeam(h.DB, name); err != nil {
+			log.Println("Error creating team:", err)
+			http.Error(w, "Unable to create team", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/admin/teams", http.StatusSeeOther)
+		return
+	}
+
+	teams, err := models.GetAllTeams(h.DB)
+	if err != nil {
+		http.Error(w, "Unable to retrieve teams", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := h.getCommonData(r, "Team Management")
+	if err != nil {
+		http.Error(w, "Unable to retrieve common data", http.StatusInternalServerError)
+		return
+	}
+	data["Teams"] = teams
+
+	h.renderTemplate(w, "templates/admin/teams.html", data)
+}
+
+func (h *AppHandler) DeleteTeamHandler(w http.ResponseWriter, r *http.Request) {
+	teamID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DeleteTeam(h.DB, teamID); err != nil {
+		http.Error(w, "Unable to delete team", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/teams", http.StatusSeeOther)
+}
+
+// EditTeamHandler shows a team's members and adds members or changes their role.
+func (h *AppHandler) EditTeamHandler(w http.ResponseWriter, r *http.Request) {
+	teamID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return
+	}
+
+	team, err := models.GetTeamByID(h.DB, teamID)
+	if err != nil {
+		http.Error(w, "Team not found", http.StatusNotFound)
+		return
+	}
+
+	if r.Method == "POST" {
+		userID, err := strconv.Atoi(r.FormValue("user_id"))
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		role := models.TeamRole(r.FormValue("role"))
+		if role != models.TeamLeadRole {
+			role = models.TeamMemberRole
+		}
+		if err := models.SetTeamMember(h.DB, teamID, userID, role); err != nil {
+			log.Println("Error updating team member:", err)
+			http.Error(w, "Unable to update team member", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/admin/teams/"+strconv.Itoa(teamID), http.StatusSeeOther)
+		return
+	}
+
+	members, err := models.GetTeamMembers(h.DB, teamID)
+	if err != nil {
+		http.Error(w, "Unable to retrieve team members", http.StatusInternalServerError)
+		return
+	}
+
+	users, err := models.GetAllUsers(h.DB)
+	if err != nil {
+		http.Error(w, "Unable to retrieve users", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := h.getCommonData(r, "Edit Team")
+	if err != nil {
+		http.Error(w, "Unable to retrieve common data", http.StatusInternalServerError)
+		return
+	}
+	data["Team"] = team
+	data["Members"] = members
+	data["Users"] = users
+
+	h.renderTemplate(w, "templates/admin/edit_team.html", data)
+}
+
+func (h *AppHandler) RemoveTeamMemberHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	teamID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return
+	}
+	userID, err := strconv.Atoi(vars["userID"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.RemoveTeamMember(h.DB, teamID, userID); err != nil {
+		http.Error(w, "Unable to remove team member", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/teams/"+strconv.Itoa(teamID), http.StatusSeeOther)
+}
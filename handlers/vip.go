@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/gorilla/mux"
+)
+
+// VIPManagementHandler lists the VIP organizations and domains and adds new ones.
+func (h *AppHandler) VIPManagementHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		entry := models.VIPEntry{
+			Kind:  r.FormValue("kind"),
+			Value: strings.TrimPrefix(strings.TrimSpace(r.FormValue("value")), "@"),
+			Note:  strings.TrimSpace(r.FormValue("note")),
+		}
+		if entry.Kind != models.VIPOrganization && entry.Kind != models.VIPDomain {
+			http.Error(w, "Invalid VIP type", http.StatusBadRequest)
+			return
+		}
+		if entry.Value == "" {
+			http.Error(w, "An organization or domain is required", http.StatusBadRequest)
+			return
+		}
+		if err := models.AddVIPEntry(h.DB, entry); err != nil {
+			log.Println("Error adding VIP entry:", err)
+			http.Error(w, "Unable to add VIP entry", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/admin/vip", http.StatusSeeOther)
+		return
+	}
+
+	entries, err := models.GetVIPEntries(h.DB)
+	if err != nil {
+		http.Error(w, "Unable to retrieve VIP list", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := h.getCommonData(r, "VIP Customers")
+	if err != nil {
+		http.Error(w, "Unable to retrieve common data", http.StatusInternalServerError)
+		return
+	}
+	data["Entries"] = entries
+
+	h.renderTemplate(w, "templates/admin/vip.html", data)
+}
+
+func (h *AppHandler) DeleteVIPEntryHandler(w http.ResponseWriter, r *http.Request) {
+	entryID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid VIP entry ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DeleteVIPEntry(h.DB, entryID); err != nil {
+		http.Error(w, "Unable to delete VIP entry", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/vip", http.StatusSeeOther)
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/gorilla/mux"
+)
+
+// WatchTicketHandler adds a ticket to the current user's watchlist.
+func (h *AppHandler) WatchTicketHandler(w http.ResponseWriter, r *http.Request) {
+	ticketID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ticket ID", http.StatusBadRequest)
+		return
+	}
+
+	user := h.getCurrentUser(r)
+	if err := models.WatchTicket(r.Context(), h.DB, user.ID, ticketID); err != nil {
+		log.Println("Error watching ticket:", err)
+		http.Error(w, "Unable to watch ticket", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, ticketURL(ticketID), http.StatusSeeOther)
+}
+
+// UnwatchTicketHandler removes a ticket from the current user's watchlist.
+func (h *AppHandler) UnwatchTicketHandler(w http.ResponseWriter, r *http.Request) {
+	ticketID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ticket ID", http.StatusBadRequest)
+		return
+	}
+
+	user := h.getCurrentUser(r)
+	if err := models.UnwatchTicket(r.Context(), h.DB, user.ID, ticketID); err != nil {
+		http.Error(w, "Unable to unwatch ticket", http.StatusInternalServerError)
+		return
+	}
+
+	if r.FormValue("return") == "list" {
+		http.Redirect(w, r, "/tickets", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, ticketURL(ticketID), http.StatusSeeOther)
+}
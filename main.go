@@ -20,8 +20,10 @@ import (
 var sseServer = middlewares.NewSSEServer()
 
 type Services struct {
-	SlackService     *services.SlackService
-	DashboardService *services.DashboardService
+	SlackService        *services.SlackService
+	DashboardService    *services.DashboardService
+	NotificationService *services.NotificationService
+	IncidentService     *services.IncidentService
 }
 
 var Service *Services
@@ -37,16 +39,19 @@ func main() {
 	startZenPollingChan := make(chan struct{})
 	startSlackPollingChan := make(chan struct{})
 
-	slackService, dashboardService := initializeServices(startZenPollingChan, startSlackPollingChan)
+	slackService, dashboardService, notificationService, incidentService := initializeServices(startZenPollingChan, startSlackPollingChan)
 	Service = &Services{
-		SlackService:     slackService,
-		DashboardService: dashboardService,
+		SlackService:        slackService,
+		DashboardService:    dashboardService,
+		NotificationService: notificationService,
+		IncidentService:     incidentService,
 	}
 
 	// Set up the router
 	r := setupRouter()
 
 	// SSE endpoint
+	sseServer.IsAdmin = handlers.IsAdminRequest
 	r.Handle("/events", sseServer)
 
 	// Start the HTTP server
@@ -75,7 +80,7 @@ func envCheck() {
 	}
 }
 
-func initializeServices(startZenPollingChan, startSlackPollingChan chan struct{}) (*services.SlackService, *services.DashboardService) {
+func initializeServices(startZenPollingChan, startSlackPollingChan chan struct{}) (*services.SlackService, *services.DashboardService, *services.NotificationService, *services.IncidentService) {
 	ctx := context.Background()
 	// Periodically check configuration and start polling when ready
 	go checkZenPolling(startZenPollingChan)
@@ -97,10 +102,24 @@ func initializeServices(startZenPollingChan, startSlackPollingChan chan struct{}
 	// Initialize DashboardService
 	dashboardService := services.NewDashboardService(database)
 
-	// Start Zendesk polling with the SlackService
-	go services.StartZendeskPolling(ctx, database, sseServer, slackService) // <-- Start Zendesk polling here
+	// Register every channel alerts can be delivered over
+	notificationService := services.NewNotificationService(database, services.NewNotifierRegistry(
+		services.NewSlackChannelNotifier(slackService),
+		services.NewSlackDMNotifier(slackService),
+		services.NewEmailNotifier(database),
+		services.NewWebhookNotifier(database),
+	), sseServer)
 
-	return slackService, dashboardService
+	// Send queued digests and rate limit rollups as their windows elapse
+	go notificationService.StartDeliveryScheduler(ctx)
+
+	// Incidents group matching tickets into Slack threads while they are active
+	incidentService := services.NewIncidentService(database, slackService)
+
+	// Start Zendesk polling with the NotificationService
+	go services.StartZendeskPolling(ctx, database, sseServer, notificationService, incidentService) // <-- Start Zendesk polling here
+
+	return slackService, dashboardService, notificationService, incidentService
 }
 func checkZenPolling(startPollingChan chan struct{}) {
 	for {
@@ -192,23 +211,64 @@ func setupProtectedRoutes(r *mux.Router) *mux.Router {
 		appHandler.DashboardHandler(w, r, Service.DashboardService)
 	}).Methods("GET")
 	protected.HandleFunc("/profile", func(w http.ResponseWriter, r *http.Request) {
-		appHandler.ProfileHandler(w, r, Service.SlackService)
+		appHandler.ProfileHandler(w, r, Service.SlackService, Service.NotificationService)
 	}).Methods("GET", "POST")
 	protected.HandleFunc("/profile/add-tag", func(w http.ResponseWriter, r *http.Request) {
-		appHandler.ProfileHandler(w, r, Service.SlackService)
+		appHandler.ProfileHandler(w, r, Service.SlackService, Service.NotificationService)
 	}).Methods("POST")
+	protected.HandleFunc("/profile/preview-tag", appHandler.PreviewTagAlertHandler).Methods("POST")
 	protected.HandleFunc("/profile/delete-tag/{id}", func(w http.ResponseWriter, r *http.Request) {
-		appHandler.ProfileHandler(w, r, Service.SlackService)
+		appHandler.ProfileHandler(w, r, Service.SlackService, Service.NotificationService)
 	}).Methods("POST")
 	protected.HandleFunc("/profile/update-summary-settings", func(w http.ResponseWriter, r *http.Request) {
-		appHandler.ProfileHandler(w, r, Service.SlackService)
+		appHandler.ProfileHandler(w, r, Service.SlackService, Service.NotificationService)
 	}).Methods("POST")
 	protected.HandleFunc("/profile/update-profile", func(w http.ResponseWriter, r *http.Request) {
-		appHandler.ProfileHandler(w, r, Service.SlackService)
+		appHandler.ProfileHandler(w, r, Service.SlackService, Service.NotificationService)
+	}).Methods("POST")
+	protected.HandleFunc("/profile/update-notification-preferences", func(w http.ResponseWriter, r *http.Request) {
+		appHandler.ProfileHandler(w, r, Service.SlackService, Service.NotificationService)
+	}).Methods("POST")
+	protected.HandleFunc("/profile/update-quiet-hours", func(w http.ResponseWriter, r *http.Request) {
+		appHandler.ProfileHandler(w, r, Service.SlackService, Service.NotificationService)
+	}).Methods("POST")
+	protected.HandleFunc("/profile/update-absence", func(w http.ResponseWriter, r *http.Request) {
+		appHandler.ProfileHandler(w, r, Service.SlackService, Service.NotificationService)
+	}).Methods("POST")
+	protected.HandleFunc("/profile/clear-absence", func(w http.ResponseWriter, r *http.Request) {
+		appHandler.ProfileHandler(w, r, Service.SlackService, Service.NotificationService)
 	}).Methods("POST")
 	protected.HandleFunc("/profile/summary/now", func(w http.ResponseWriter, r *http.Request) {
-		appHandler.OnDemandSummaryHandler(w, r, Service.SlackService)
+		appHandler.OnDemandSummaryHandler(w, r, Service.NotificationService)
+	}).Methods("GET")
+
+	protected.HandleFunc("/oncall", appHandler.OnCallHandler).Methods("GET")
+	protected.HandleFunc("/tickets", func(w http.ResponseWriter, r *http.Request) {
+		appHandler.TicketsHandler(w, r, Service.SlackService)
+	}).Methods("GET")
+	protected.HandleFunc("/tickets/{id}", func(w http.ResponseWriter, r *http.Request) {
+		appHandler.TicketHandler(w, r, Service.SlackService)
 	}).Methods("GET")
+	protected.HandleFunc("/tickets/{id}/watch", appHandler.WatchTicketHandler).Methods("POST")
+	protected.HandleFunc("/tickets/{id}/unwatch", appHandler.UnwatchTicketHandler).Methods("POST")
+	protected.HandleFunc("/tickets/{id}/mute", appHandler.MuteTicketHandler).Methods("POST")
+	protected.HandleFunc("/tickets/{id}/mutes/{muteID}/delete", appHandler.UnmuteTicketHandler).Methods("POST")
+	protected.HandleFunc("/teams", appHandler.TeamsHandler).Methods("GET")
+	protected.HandleFunc("/teams/{id}", func(w http.ResponseWriter, r *http.Request) {
+		appHandler.TeamHandler(w, r, Service.SlackService)
+	}).Methods("GET")
+	protected.HandleFunc("/teams/{id}/add-tag", func(w http.ResponseWriter, r *http.Request) {
+		appHandler.TeamHandler(w, r, Service.SlackService)
+	}).Methods("POST")
+	protected.HandleFunc("/teams/{id}/delete-tag/{alertID}", func(w http.ResponseWriter, r *http.Request) {
+		appHandler.TeamHandler(w, r, Service.SlackService)
+	}).Methods("POST")
+	protected.HandleFunc("/teams/{id}/add-shift", func(w http.ResponseWriter, r *http.Request) {
+		appHandler.TeamHandler(w, r, Service.SlackService)
+	}).Methods("POST")
+	protected.HandleFunc("/teams/{id}/delete-shift/{shiftID}", func(w http.ResponseWriter, r *http.Request) {
+		appHandler.TeamHandler(w, r, Service.SlackService)
+	}).Methods("POST")
 
 	protected.HandleFunc("/logout", appHandler.LogoutHandler).Methods("GET")
 
@@ -224,9 +284,45 @@ func setupAdminRoutes(protected *mux.Router) {
 	admin.HandleFunc("/users/edit/{id}", adminHandler.EditUserHandler).Methods("GET", "POST")
 	admin.HandleFunc("/users/delete/{id}", adminHandler.DeleteUserHandler).Methods("POST")
 	admin.HandleFunc("/users/new", adminHandler.NewUserHandler).Methods("GET", "POST")
+	admin.HandleFunc("/teams", adminHandler.TeamManagementHandler).Methods("GET", "POST")
+	admin.HandleFunc("/teams/delete/{id}", adminHandler.DeleteTeamHandler).Methods("POST")
+	admin.HandleFunc("/teams/{id}", adminHandler.EditTeamHandler).Methods("GET")
+	admin.HandleFunc("/teams/{id}/members", adminHandler.EditTeamHandler).Methods("POST")
+	admin.HandleFunc("/teams/{id}/members/{userID}/delete", adminHandler.RemoveTeamMemberHandler).Methods("POST")
+	admin.HandleFunc("/oncall", adminHandler.OnCallManagementHandler).Methods("GET", "POST")
+	admin.HandleFunc("/oncall/delete/{id}", adminHandler.DeleteOnCallScheduleHandler).Methods("POST")
+	admin.HandleFunc("/oncall/{id}", adminHandler.EditOnCallScheduleHandler).Methods("GET", "POST")
+	admin.HandleFunc("/oncall/{id}/members", adminHandler.AddOnCallMemberHandler).Methods("POST")
+	admin.HandleFunc("/oncall/{id}/members/{userID}/delete", adminHandler.RemoveOnCallMemberHandler).Methods("POST")
+	admin.HandleFunc("/oncall/{id}/overrides", adminHandler.AddOnCallOverrideHandler).Methods("POST")
+	admin.HandleFunc("/oncall/{id}/overrides/{overrideID}/delete", adminHandler.DeleteOnCallOverrideHandler).Methods("POST")
+	admin.HandleFunc("/oncall/{id}/import", adminHandler.ImportOnCallCalendarHandler).Methods("POST")
+	admin.HandleFunc("/vip", adminHandler.VIPManagementHandler).Methods("GET", "POST")
+	admin.HandleFunc("/vip/delete/{id}", adminHandler.DeleteVIPEntryHandler).Methods("POST")
 	admin.HandleFunc("/tags", adminHandler.TagManagementHandler).Methods("GET")
+	admin.HandleFunc("/tags/sync", adminHandler.SyncTagCatalogHandler).Methods("POST")
 	admin.HandleFunc("/tag/delete/{id}", adminHandler.DeleteTagAlertHandler).Methods("POST")
+	admin.HandleFunc("/tag-aliases", adminHandler.TagAliasesHandler).Methods("GET", "POST")
+	admin.HandleFunc("/tag-aliases/delete/{id}", adminHandler.DeleteTagAliasHandler).Methods("POST")
+	admin.HandleFunc("/incidents", func(w http.ResponseWriter, r *http.Request) {
+		adminHandler.IncidentsHandler(w, r, Service.SlackService, Service.IncidentService)
+	}).Methods("GET", "POST")
+	admin.HandleFunc("/incidents/{id}", func(w http.ResponseWriter, r *http.Request) {
+		adminHandler.IncidentHandler(w, r, Service.SlackService, Service.IncidentService)
+	}).Methods("GET")
+	admin.HandleFunc("/incidents/{id}/update", func(w http.ResponseWriter, r *http.Request) {
+		adminHandler.IncidentUpdateHandler(w, r, Service.IncidentService)
+	}).Methods("POST")
+	admin.HandleFunc("/incidents/{id}/resolve", func(w http.ResponseWriter, r *http.Request) {
+		adminHandler.ResolveIncidentHandler(w, r, Service.IncidentService)
+	}).Methods("POST")
+	admin.HandleFunc("/incidents/{id}/channels", func(w http.ResponseWriter, r *http.Request) {
+		adminHandler.SubscribeIncidentHandler(w, r, Service.IncidentService)
+	}).Methods("POST")
+	admin.HandleFunc("/incidents/{id}/channels/{channelID}/delete", adminHandler.UnsubscribeIncidentHandler).Methods("POST")
 	admin.HandleFunc("/configuration", adminHandler.ConfigurationHandler).Methods("GET", "POST")
+	admin.HandleFunc("/shadow-log", adminHandler.ShadowLogHandler).Methods("GET")
+	admin.HandleFunc("/shadow-log/clear", adminHandler.ClearShadowLogHandler).Methods("POST")
 }
 
 func startServer(r *mux.Router) {
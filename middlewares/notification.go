@@ -42,6 +42,19 @@ func AddGlobalNotification(sseServer *SSEServer, category, message, severity str
 	}
 }
 
+// AddAdminNotification sends a notification to connected administrators over SSE.
+// Unlike global notifications it is not shown to other users.
+func AddAdminNotification(sseServer *SSEServer, category, message, severity string) {
+	if sseServer == nil {
+		return
+	}
+	sseServer.NotifyAdmins(formatNotificationMessage(Notification{
+		Category: category,
+		Message:  message,
+		Severity: severity,
+	}))
+}
+
 // Formats the notification message for SSE
 func formatNotificationMessage(notification Notification) string {
 	return notification.Category + ": " + notification.Message + " (" + notification.Severity + ")"
@@ -9,13 +9,17 @@ import (
 
 type SSEServer struct {
 	Clients          map[chan string]bool
+	AdminClients     map[chan string]bool // Subset of Clients opened by administrators
 	mu               sync.Mutex
 	ConnectionStatus map[string]map[string]string // Store status of services
+	// IsAdmin reports whether a connecting client is an administrator
+	IsAdmin func(r *http.Request) bool
 }
 
 func NewSSEServer() *SSEServer {
 	return &SSEServer{
 		Clients:          make(map[chan string]bool),
+		AdminClients:     make(map[chan string]bool),
 		ConnectionStatus: make(map[string]map[string]string), // Initialize the connection status map
 	}
 }
@@ -28,13 +32,18 @@ func (s *SSEServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	notificationChan := make(chan string)
+	isAdmin := s.IsAdmin != nil && s.IsAdmin(r)
 	s.mu.Lock()
 	s.Clients[notificationChan] = true
+	if isAdmin {
+		s.AdminClients[notificationChan] = true
+	}
 	s.mu.Unlock()
 
 	defer func() {
 		s.mu.Lock()
 		delete(s.Clients, notificationChan)
+		delete(s.AdminClients, notificationChan)
 		s.mu.Unlock()
 		close(notificationChan)
 	}()
@@ -91,3 +100,13 @@ func (s *SSEServer) NotifyAll(message string) {
 		client <- message
 	}
 }
+
+// NotifyAdmins sends a message to administrator clients only.
+func (s *SSEServer) NotifyAdmins(message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for client := range s.AdminClients {
+		client <- message
+	}
+}
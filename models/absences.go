@@ -0,0 +1,85 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+)
+
+// Absence is a period when a user is out of office. While it is active the
+// user's alerts go to the delegate user or, when no delegate user is set, to
+// the delegate Slack channel.
+type Absence struct {
+	UserID          int            `db:"user_id"`
+	UserName        string         `db:"user_name"`
+	AwayFrom        time.Time      `db:"away_from"`
+	AwayUntil       time.Time      `db:"away_until"`
+	DelegateUserID  sql.NullInt64  `db:"delegate_user_id"`
+	DelegateName    sql.NullString `db:"delegate_name"`
+	DelegateChannel string         `db:"delegate_channel"`
+}
+
+// Active reports whether now falls within the absence.
+func (a Absence) Active(now time.Time) bool {
+	return !now.Before(a.AwayFrom) && now.Before(a.AwayUntil)
+}
+
+const absenceSelect = `
+	SELECT a.user_id, COALESCE(u.name, '') AS user_name, a.away_from, a.away_until, a.delegate_user_id,
+		d.name AS delegate_name, COALESCE(a.delegate_channel, '') AS delegate_channel
+	FROM absences a
+	JOIN users u ON u.id = a.user_id
+	LEFT JOIN users d ON d.id = a.delegate_user_id
+`
+
+// GetAbsence returns the user's current or upcoming absence, or nil if none has been declared.
+func GetAbsence(db db.Database, userID int) (*Absence, error) {
+	var absence Absence
+	err := db.Get(&absence, absenceSelect+`WHERE a.user_id = ?`, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absence: %w", err)
+	}
+	return &absence, nil
+}
+
+// GetAbsencesCoveredBy returns the current and upcoming absences the user is the delegate for.
+func GetAbsencesCoveredBy(db db.Database, delegateUserID int) ([]Absence, error) {
+	var absences []Absence
+	err := db.Select(&absences, absenceSelect+`WHERE a.delegate_user_id = ? AND a.away_until > ? ORDER BY a.away_from`,
+		delegateUserID, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get covered absences: %w", err)
+	}
+	return absences, nil
+}
+
+// SetAbsence declares the user's absence, replacing any absence already declared.
+func SetAbsence(db db.Database, absence Absence) error {
+	_, err := db.Exec(`
+		INSERT INTO absences (user_id, away_from, away_until, delegate_user_id, delegate_channel)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET
+			away_from = excluded.away_from,
+			away_until = excluded.away_until,
+			delegate_user_id = excluded.delegate_user_id,
+			delegate_channel = excluded.delegate_channel
+	`, absence.UserID, absence.AwayFrom.UTC(), absence.AwayUntil.UTC(), absence.DelegateUserID, absence.DelegateChannel)
+	if err != nil {
+		return fmt.Errorf("failed to save absence: %w", err)
+	}
+	return nil
+}
+
+// DeleteAbsence clears the user's absence.
+func DeleteAbsence(db db.Database, userID int) error {
+	if _, err := db.Exec(`DELETE FROM absences WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to delete absence: %w", err)
+	}
+	return nil
+}
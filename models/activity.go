@@ -0,0 +1,70 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+)
+
+// TicketActivity records a ticket event that would have fired rules of the
+// given alert type, regardless of whether any rule was configured at the time.
+// It lets new rules be replayed against recent history.
+type TicketActivity struct {
+	ID        int64          `db:"id"`
+	TicketID  int64          `db:"ticket_id"`
+	Tags      string         `db:"tags"` // Space separated ticket tags
+	AlertType string         `db:"alert_type"`
+	SLALabel  sql.NullString `db:"sla_label"`
+	// OrganizationID and RequesterID let previews replay organization and VIP filters
+	OrganizationID int64     `db:"organization_id"`
+	RequesterID    int64     `db:"requester_id"`
+	EventAt        time.Time `db:"event_at"`
+	ObservedAt     time.Time `db:"observed_at"`
+}
+
+// TagList returns the ticket's tags at the time of the event.
+func (a TicketActivity) TagList() []string {
+	return strings.Fields(a.Tags)
+}
+
+// RecordTicketActivity stores an event. Events already recorded for the same
+// ticket, alert type, and event time are ignored.
+func RecordTicketActivity(ctx context.Context, db db.Database, activity TicketActivity) error {
+	query := `
+		INSERT INTO ticket_activity (ticket_id, tags, alert_type, sla_label, organization_id, requester_id, event_at, observed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT(ticket_id, alert_type, event_at) DO NOTHING
+	`
+	_, err := db.ExecContext(ctx, query, activity.TicketID, activity.Tags, activity.AlertType, activity.SLALabel,
+		nullableID(int(activity.OrganizationID)), nullableID(int(activity.RequesterID)), activity.EventAt.UTC(), time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to record ticket activity: %w", err)
+	}
+	return nil
+}
+
+// GetTicketActivitySince retrieves every event observed since the given time.
+func GetTicketActivitySince(db db.Database, since time.Time) ([]TicketActivity, error) {
+	var activity []TicketActivity
+	err := db.Select(&activity, `
+		SELECT id, ticket_id, tags, alert_type, sla_label, COALESCE(organization_id, 0) AS organization_id,
+			COALESCE(requester_id, 0) AS requester_id, event_at, observed_at
+		FROM ticket_activity
+		WHERE observed_at >= $1
+		ORDER BY observed_at
+	`, since.UTC())
+	return activity, err
+}
+
+// PruneTicketActivity deletes events observed before the given time.
+func PruneTicketActivity(ctx context.Context, db db.Database, before time.Time) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM ticket_activity WHERE observed_at < $1`, before.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to prune ticket activity: %w", err)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"time"
 
@@ -11,6 +12,7 @@ import (
 type SLAAlertCache struct {
 	ID        int64     `db:"id"`
 	UserID    int64     `db:"user_id"`
+	TeamID    int64     `db:"team_id"`
 	TicketID  int64     `db:"ticket_id"`
 	AlertType string    `db:"alert_type"`
 	BreachAt  time.Time `db:"breach_at"`
@@ -20,11 +22,11 @@ type SLAAlertCache struct {
 // CreateSLAAlertCache inserts a new entry into the sla_alert_cache table.
 func CreateSLAAlertCache(ctx context.Context, db db.Database, cacheEntry SLAAlertCache) error {
 	query := `
-        INSERT INTO sla_alert_cache (user_id, ticket_id, alert_type, breach_at)
-        VALUES ($1, $2, $3, $4)
+        INSERT INTO sla_alert_cache (user_id, team_id, ticket_id, alert_type, breach_at)
+        VALUES ($1, $2, $3, $4, $5)
         RETURNING id
     `
-	err := db.QueryRowContext(ctx, query, cacheEntry.UserID, cacheEntry.TicketID, cacheEntry.AlertType, cacheEntry.BreachAt).Scan(&cacheEntry.ID)
+	err := db.QueryRowContext(ctx, query, nullableID(int(cacheEntry.UserID)), nullableID(int(cacheEntry.TeamID)), cacheEntry.TicketID, cacheEntry.AlertType, cacheEntry.BreachAt).Scan(&cacheEntry.ID)
 	if err != nil {
 		return fmt.Errorf("failed to create SLA alert cache entry: %w", err)
 	}
@@ -32,14 +34,18 @@ func CreateSLAAlertCache(ctx context.Context, db db.Database, cacheEntry SLAAler
 	return nil
 }
 
-// GetSLAAlertCache retrieves an SLA alert cache entry by user, ticket, and alert type.
-func GetSLAAlertCache(ctx context.Context, db db.Database, userID, ticketID int, alertType string) (*SLAAlertCache, error) {
+// GetSLAAlertCache retrieves an SLA alert cache entry by owning user or team, ticket, and alert type.
+// Pass a zero userID for team-owned rules and a zero teamID for personal rules.
+func GetSLAAlertCache(ctx context.Context, db db.Database, userID, teamID, ticketID int, alertType string) (*SLAAlertCache, error) {
 	var cacheEntry SLAAlertCache
-	query := `SELECT id, user_id, ticket_id, alert_type, breach_at, created_at FROM sla_alert_cache WHERE user_id = $1 AND ticket_id = $2 AND alert_type = $3`
-	err := db.QueryRowContext(ctx, query, userID, ticketID, alertType).Scan(&cacheEntry.ID, &cacheEntry.UserID, &cacheEntry.TicketID, &cacheEntry.AlertType, &cacheEntry.BreachAt, &cacheEntry.CreatedAt)
+	var cacheUserID, cacheTeamID sql.NullInt64
+	query := `SELECT id, user_id, team_id, ticket_id, alert_type, breach_at, created_at FROM sla_alert_cache WHERE user_id IS $1 AND team_id IS $2 AND ticket_id = $3 AND alert_type = $4`
+	err := db.QueryRowContext(ctx, query, nullableID(userID), nullableID(teamID), ticketID, alertType).Scan(&cacheEntry.ID, &cacheUserID, &cacheTeamID, &cacheEntry.TicketID, &cacheEntry.AlertType, &cacheEntry.BreachAt, &cacheEntry.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
+	cacheEntry.UserID = cacheUserID.Int64
+	cacheEntry.TeamID = cacheTeamID.Int64
 	return &cacheEntry, nil
 }
 
@@ -53,9 +59,42 @@ func ClearSLAAlertCache(ctx context.Context, db db.Database, cacheID int64) erro
 	return nil
 }
 
+// AgeAlertSent reports whether the rule has already alerted about the ticket
+// since it entered its current state at the given time.
+func AgeAlertSent(ctx context.Context, db db.Database, ruleID int, ticketID int64, since time.Time) (bool, error) {
+	var count int
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM age_alert_cache WHERE rule_id = $1 AND ticket_id = $2 AND since = $3`, ruleID, ticketID, since.UTC()).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check age alert cache: %w", err)
+	}
+	return count > 0, nil
+}
+
+// RecordAgeAlert records that the rule alerted about the ticket, replacing
+// the entry for any earlier time the ticket was in the same state.
+func RecordAgeAlert(ctx context.Context, db db.Database, ruleID int, ticketID int64, since time.Time) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO age_alert_cache (rule_id, ticket_id, since, created_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT(rule_id, ticket_id) DO UPDATE SET since = excluded.since, created_at = excluded.created_at
+	`, ruleID, ticketID, since.UTC(), time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to record age alert: %w", err)
+	}
+	return nil
+}
+
+// PruneAgeAlertCache deletes age alerts sent before the given time.
+func PruneAgeAlertCache(ctx context.Context, db db.Database, before time.Time) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM age_alert_cache WHERE created_at < $1`, before.UTC()); err != nil {
+		return fmt.Errorf("failed to prune age alert cache: %w", err)
+	}
+	return nil
+}
+
 type AlertLog struct {
 	ID        int64  `db:"id"`
 	UserID    int64  `db:"user_id"`
+	TeamID    int64  `db:"team_id"`
 	TicketID  int64  `db:"ticket_id"`
 	Tag       string `db:"tag"`
 	AlertType string `db:"alert_type"`
@@ -65,13 +104,158 @@ type AlertLog struct {
 // CreateAlertLog inserts a new alert log entry into the database.
 func CreateAlertLog(ctx context.Context, db db.Database, logEntry AlertLog) error {
 	query := `
-		INSERT INTO alert_logs (user_id, ticket_id, tag, alert_type, timestamp)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO alert_logs (user_id, team_id, ticket_id, tag, alert_type, timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING id
 	`
-	err := db.QueryRowContext(ctx, query, logEntry.UserID, logEntry.TicketID, logEntry.Tag, logEntry.AlertType, logEntry.Timestamp).Scan(&logEntry.ID)
+	err := db.QueryRowContext(ctx, query, nullableID(int(logEntry.UserID)), nullableID(int(logEntry.TeamID)), logEntry.TicketID, logEntry.Tag, logEntry.AlertType, logEntry.Timestamp).Scan(&logEntry.ID)
 	if err != nil {
 		return fmt.Errorf("failed to create alert log: %w", err)
 	}
 	return nil
 }
+
+// AcknowledgeTicketAlerts marks every unacknowledged alert logged about a
+// ticket as acknowledged by the given person.
+func AcknowledgeTicketAlerts(ctx context.Context, db db.Database, ticketID int64, acknowledgedBy string) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE alert_logs SET acknowledged_at = $1, acknowledged_by = $2
+		WHERE ticket_id = $3 AND acknowledged_at IS NULL
+	`, time.Now().UTC(), acknowledgedBy, ticketID)
+	if err != nil {
+		return fmt.Errorf("failed to acknowledge ticket alerts: %w", err)
+	}
+	return nil
+}
+
+// GetUnacknowledgedTeamAlerts returns the alerts logged since the given time
+// for a team's rules or its members' personal rules that nobody has
+// acknowledged yet, oldest first.
+func GetUnacknowledgedTeamAlerts(db db.Database, teamID int, since time.Time) ([]AlertLog, error) {
+	var logs []AlertLog
+	query := `
+		SELECT id, COALESCE(user_id, 0) AS user_id, COALESCE(team_id, 0) AS team_id, ticket_id, tag, alert_type, timestamp
+		FROM alert_logs
+		WHERE (team_id = $1 OR user_id IN (SELECT user_id FROM team_members WHERE team_id = $1))
+			AND acknowledged_at IS NULL
+			AND timestamp >= $2
+		ORDER BY timestamp, id
+	`
+	// Alert log timestamps are written in local time
+	if err := db.Select(&logs, query, teamID, since.Local().Format("2006-01-02 15:04:05")); err != nil {
+		return nil, fmt.Errorf("failed to get unacknowledged alerts: %w", err)
+	}
+	return logs, nil
+}
+
+type PagerDutyIncident struct {
+	ID        int64     `db:"id"`
+	TicketID  int64     `db:"ticket_id"`
+	Metric    string    `db:"metric"`
+	DedupKey  string    `db:"dedup_key"`
+	Shadow    bool      `db:"shadow"` // Recorded in shadow mode rather than sent to PagerDuty
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// CreatePagerDutyIncident records an incident that was triggered in PagerDuty so it can be resolved later.
+// Triggering the same dedup key twice is a no-op, except that a real trigger
+// replaces one recorded in shadow mode.
+func CreatePagerDutyIncident(ctx context.Context, db db.Database, incident PagerDutyIncident) error {
+	query := `
+		INSERT INTO pagerduty_incidents (ticket_id, metric, dedup_key, shadow)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT(dedup_key) DO UPDATE SET shadow = pagerduty_incidents.shadow AND excluded.shadow
+	`
+	_, err := db.ExecContext(ctx, query, incident.TicketID, incident.Metric, incident.DedupKey, incident.Shadow)
+	if err != nil {
+		return fmt.Errorf("failed to create PagerDuty incident: %w", err)
+	}
+	return nil
+}
+
+// PagerDutyIncidentOpen reports whether an incident with the dedup key has
+// been triggered and not yet resolved. Incidents recorded in shadow mode only
+// count while shadow mode is on, so turning it off pages for real.
+func PagerDutyIncidentOpen(ctx context.Context, db db.Database, dedupKey string, shadow bool) (bool, error) {
+	var count int
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM pagerduty_incidents WHERE dedup_key = $1 AND (shadow = 0 OR $2)`, dedupKey, shadow).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check PagerDuty incident: %w", err)
+	}
+	return count > 0, nil
+}
+
+// GetOpenPagerDutyIncidents returns every incident that has been triggered but not yet resolved.
+func GetOpenPagerDutyIncidents(ctx context.Context, db db.Database) ([]PagerDutyIncident, error) {
+	var incidents []PagerDutyIncident
+	query := `SELECT id, ticket_id, metric, dedup_key, shadow, created_at FROM pagerduty_incidents ORDER BY id`
+	if err := db.Select(&incidents, query); err != nil {
+		return nil, fmt.Errorf("failed to get open PagerDuty incidents: %w", err)
+	}
+	return incidents, nil
+}
+
+// DeletePagerDutyIncident removes an incident once it has been resolved in PagerDuty.
+func DeletePagerDutyIncident(ctx context.Context, db db.Database, incidentID int64) error {
+	query := `DELETE FROM pagerduty_incidents WHERE id = $1`
+	_, err := db.ExecContext(ctx, query, incidentID)
+	if err != nil {
+		return fmt.Errorf("failed to delete PagerDuty incident: %w", err)
+	}
+	return nil
+}
+
+// ShadowAlertLog records a notification that would have been delivered while shadow mode was enabled.
+type ShadowAlertLog struct {
+	ID          int64     `db:"id"`
+	AlertType   string    `db:"alert_type"`
+	Channel     string    `db:"channel"`
+	Destination string    `db:"destination"`
+	UserID      int64     `db:"user_id"`
+	TeamID      int64     `db:"team_id"`
+	RuleID      int64     `db:"rule_id"`
+	TicketID    int64     `db:"ticket_id"`
+	Tag         string    `db:"tag"`
+	SLALabel    string    `db:"sla_label"`
+	Subject     string    `db:"subject"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// CreateShadowAlertLog inserts a new shadow log entry.
+func CreateShadowAlertLog(ctx context.Context, db db.Database, logEntry ShadowAlertLog) error {
+	query := `
+		INSERT INTO shadow_alert_logs (alert_type, channel, destination, user_id, team_id, rule_id, ticket_id, tag, sla_label, subject)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+	_, err := db.ExecContext(ctx, query, logEntry.AlertType, logEntry.Channel, logEntry.Destination,
+		nullableID(int(logEntry.UserID)), nullableID(int(logEntry.TeamID)), nullableID(int(logEntry.RuleID)), nullableID(int(logEntry.TicketID)),
+		logEntry.Tag, logEntry.SLALabel, logEntry.Subject)
+	if err != nil {
+		return fmt.Errorf("failed to create shadow alert log: %w", err)
+	}
+	return nil
+}
+
+// GetRecentShadowAlertLogs returns the most recent shadow log entries, newest first.
+func GetRecentShadowAlertLogs(db db.Database, limit int) ([]ShadowAlertLog, error) {
+	var logs []ShadowAlertLog
+	query := `
+		SELECT id, alert_type, channel, COALESCE(destination, '') AS destination,
+			COALESCE(user_id, 0) AS user_id, COALESCE(team_id, 0) AS team_id, COALESCE(rule_id, 0) AS rule_id,
+			COALESCE(ticket_id, 0) AS ticket_id, COALESCE(tag, '') AS tag, COALESCE(sla_label, '') AS sla_label,
+			COALESCE(subject, '') AS subject, created_at
+		FROM shadow_alert_logs
+		ORDER BY id DESC
+		LIMIT $1
+	`
+	if err := db.Select(&logs, query, limit); err != nil {
+		return nil, fmt.Errorf("failed to get shadow alert logs: %w", err)
+	}
+	return logs, nil
+}
+
+// ClearShadowAlertLogs deletes every shadow log entry.
+func ClearShadowAlertLogs(db db.Database) error {
+	_, err := db.Exec(`DELETE FROM shadow_alert_logs`)
+	return err
+}
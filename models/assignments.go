@@ -0,0 +1,74 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+)
+
+// TicketAssignment records a ticket TicketPulse assigned to a team member.
+type TicketAssignment struct {
+	TicketID   int64     `db:"ticket_id"`
+	RuleID     int       `db:"rule_id"`
+	TeamID     int64     `db:"team_id"`
+	UserID     int       `db:"user_id"`
+	AssigneeID int64     `db:"assignee_id"` // The member's Zendesk user ID
+	AssignedAt time.Time `db:"assigned_at"`
+}
+
+// RecordTicketAssignment records that a ticket was assigned.
+func RecordTicketAssignment(ctx context.Context, db db.Database, assignment TicketAssignment) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO ticket_assignments (ticket_id, rule_id, team_id, user_id, assignee_id, assigned_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT(ticket_id) DO UPDATE SET rule_id = excluded.rule_id, team_id = excluded.team_id,
+			user_id = excluded.user_id, assignee_id = excluded.assignee_id, assigned_at = excluded.assigned_at
+	`, assignment.TicketID, assignment.RuleID, assignment.TeamID, assignment.UserID, assignment.AssigneeID, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to record ticket assignment: %w", err)
+	}
+	return nil
+}
+
+// LastTeamAssignee returns the team member most recently assigned a ticket,
+// or zero when the team has not been assigned any.
+func LastTeamAssignee(db db.Database, teamID int64) (int, error) {
+	var userID int
+	err := db.Get(&userID, `SELECT user_id FROM ticket_assignments WHERE team_id = $1 ORDER BY assigned_at DESC, ticket_id DESC LIMIT 1`, teamID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last team assignee: %w", err)
+	}
+	return userID, nil
+}
+
+// TicketAssigned reports whether TicketPulse has already assigned the ticket.
+func TicketAssigned(db db.Database, ticketID int64) (bool, error) {
+	var count int
+	if err := db.Get(&count, `SELECT COUNT(*) FROM ticket_assignments WHERE ticket_id = $1`, ticketID); err != nil {
+		return false, fmt.Errorf("failed to check ticket assignment: %w", err)
+	}
+	return count > 0, nil
+}
+
+// PruneTicketAssignments removes assignments made before the cutoff. The
+// latest assignment of each team is kept so its rotation carries on.
+func PruneTicketAssignments(ctx context.Context, db db.Database, before time.Time) error {
+	_, err := db.ExecContext(ctx, `
+		DELETE FROM ticket_assignments
+		WHERE assigned_at < $1
+		AND ticket_id NOT IN (
+			SELECT ticket_id FROM ticket_assignments a
+			WHERE a.assigned_at = (SELECT MAX(assigned_at) FROM ticket_assignments b WHERE b.team_id = a.team_id)
+		)
+	`, before.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to prune ticket assignments: %w", err)
+	}
+	return nil
+}
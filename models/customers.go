@@ -0,0 +1,102 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+)
+
+// VIP entry kinds.
+const (
+	VIPOrganization = "organization" // Value is a Zendesk organization ID or name
+	VIPDomain       = "domain"       // Value is a requester email domain
+)
+
+// VIPEntry marks an organization or a requester email domain as a VIP customer.
+type VIPEntry struct {
+	ID        int       `db:"id"`
+	Kind      string    `db:"kind"`
+	Value     string    `db:"value"`
+	Note      string    `db:"note"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// GetVIPEntries returns the VIP list ordered by kind and value.
+func GetVIPEntries(db db.Database) ([]VIPEntry, error) {
+	var entries []VIPEntry
+	err := db.Select(&entries, `SELECT id, kind, value, COALESCE(note, '') AS note, created_at FROM vip_entries ORDER BY kind, value`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get VIP entries: %w", err)
+	}
+	return entries, nil
+}
+
+// AddVIPEntry adds an organization or domain to the VIP list. Values are
+// stored in lower case so they match regardless of how they were entered.
+func AddVIPEntry(db db.Database, entry VIPEntry) error {
+	_, err := db.Exec(`
+		INSERT INTO vip_entries (kind, value, note) VALUES (?, ?, ?)
+		ON CONFLICT(kind, value) DO UPDATE SET note = excluded.note
+	`, entry.Kind, strings.ToLower(strings.TrimSpace(entry.Value)), entry.Note)
+	if err != nil {
+		return fmt.Errorf("failed to add VIP entry: %w", err)
+	}
+	return nil
+}
+
+// DeleteVIPEntry removes an entry from the VIP list.
+func DeleteVIPEntry(db db.Database, entryID int) error {
+	if _, err := db.Exec(`DELETE FROM vip_entries WHERE id = ?`, entryID); err != nil {
+		return fmt.Errorf("failed to delete VIP entry: %w", err)
+	}
+	return nil
+}
+
+// Zendesk record kinds kept in the lookup cache.
+const (
+	ZendeskOrganizationRecord = "organization"
+	ZendeskUserRecord         = "user"
+)
+
+// ZendeskRecord is a cached Zendesk organization or user, so that rules can
+// match on names and email domains without an API call for every ticket.
+type ZendeskRecord struct {
+	Kind      string    `db:"kind"`
+	ID        int64     `db:"id"`
+	Name      string    `db:"name"`
+	Email     string    `db:"email"`
+	FetchedAt time.Time `db:"fetched_at"`
+}
+
+// GetZendeskRecord returns the cached record, or nil if it has not been cached.
+func GetZendeskRecord(db db.Database, kind string, id int64) (*ZendeskRecord, error) {
+	var record ZendeskRecord
+	err := db.Get(&record, `SELECT kind, id, name, COALESCE(email, '') AS email, fetched_at FROM zendesk_records WHERE kind = ? AND id = ?`, kind, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cached Zendesk %s: %w", kind, err)
+	}
+	return &record, nil
+}
+
+// SaveZendeskRecord caches a record, replacing any earlier copy.
+func SaveZendeskRecord(ctx context.Context, db db.Database, record ZendeskRecord) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO zendesk_records (kind, id, name, email, fetched_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT(kind, id) DO UPDATE SET
+			name = excluded.name,
+			email = excluded.email,
+			fetched_at = excluded.fetched_at
+	`, record.Kind, record.ID, record.Name, nullableString(record.Email), time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to cache Zendesk %s: %w", record.Kind, err)
+	}
+	return nil
+}
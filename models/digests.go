@@ -0,0 +1,80 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+)
+
+// DigestEntry is a ticket waiting to be included in the next digest posted to a destination.
+type DigestEntry struct {
+	ID              int64     `db:"id"`
+	Channel         string    `db:"channel"`
+	Destination     string    `db:"destination"`
+	IntervalMinutes int       `db:"interval_minutes"`
+	AlertType       string    `db:"alert_type"`
+	TicketID        int64     `db:"ticket_id"`
+	Subject         string    `db:"subject"`
+	Status          string    `db:"status"`
+	Priority        string    `db:"priority"`
+	Tag             string    `db:"tag"`
+	SLALabel        string    `db:"sla_label"`
+	Occurrences     int       `db:"occurrences"` // Times the ticket matched during the window
+	CreatedAt       time.Time `db:"created_at"`
+}
+
+// Due reports whether the entry's digest window has elapsed.
+func (e DigestEntry) Due(now time.Time) bool {
+	return !now.Before(e.CreatedAt.Add(time.Duration(e.IntervalMinutes) * time.Minute))
+}
+
+// EnqueueDigestEntry queues a ticket for the destination's next digest. A
+// ticket that is already queued for the same alert type is updated with its
+// latest details instead of being listed twice.
+func EnqueueDigestEntry(ctx context.Context, db db.Database, entry DigestEntry) error {
+	query := `
+		INSERT INTO digest_queue (channel, destination, interval_minutes, alert_type, ticket_id, subject, status, priority, tag, sla_label, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT(channel, destination, ticket_id, alert_type) DO UPDATE SET
+			interval_minutes = MIN(interval_minutes, excluded.interval_minutes),
+			subject = excluded.subject,
+			status = excluded.status,
+			priority = excluded.priority,
+			sla_label = excluded.sla_label,
+			occurrences = occurrences + 1
+	`
+	_, err := db.ExecContext(ctx, query, entry.Channel, entry.Destination, entry.IntervalMinutes, entry.AlertType, entry.TicketID,
+		entry.Subject, entry.Status, entry.Priority, entry.Tag, entry.SLALabel, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to queue digest entry: %w", err)
+	}
+	return nil
+}
+
+// GetDigestEntries returns every queued entry in the order it was queued.
+func GetDigestEntries(db db.Database) ([]DigestEntry, error) {
+	var entries []DigestEntry
+	query := `
+		SELECT id, channel, destination, interval_minutes, alert_type, ticket_id, subject,
+			COALESCE(status, '') AS status, COALESCE(priority, '') AS priority, COALESCE(tag, '') AS tag,
+			COALESCE(sla_label, '') AS sla_label, occurrences, created_at
+		FROM digest_queue
+		ORDER BY id
+	`
+	if err := db.Select(&entries, query); err != nil {
+		return nil, fmt.Errorf("failed to get digest entries: %w", err)
+	}
+	return entries, nil
+}
+
+// DeleteDigestEntries removes a destination's entries up to and including
+// maxID, leaving anything queued while the digest was being sent.
+func DeleteDigestEntries(ctx context.Context, db db.Database, channel, destination string, maxID int64) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM digest_queue WHERE channel = $1 AND destination = $2 AND id <= $3`, channel, destination, maxID)
+	if err != nil {
+		return fmt.Errorf("failed to delete digest entries: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+)
+
+// Incident groups the tickets raised by an outage, so they are tracked in one
+// Slack thread per channel instead of alerting one by one. Tickets match an
+// incident by tag or keyword once it has started, until it is resolved.
+type Incident struct {
+	ID         int64        `db:"id"`
+	Name       string       `db:"name"`
+	Tag        string       `db:"tag"`
+	Keyword    string       `db:"keyword"`
+	StartedAt  time.Time    `db:"started_at"`
+	ResolvedAt sql.NullTime `db:"resolved_at"`
+	DeclaredBy string       `db:"declared_by"`
+	CreatedAt  time.Time    `db:"created_at"`
+}
+
+// Active reports whether the incident has started and is not yet resolved.
+func (i Incident) Active(now time.Time) bool {
+	return !i.ResolvedAt.Valid && !i.StartedAt.After(now)
+}
+
+// Criteria describes what tickets the incident groups.
+func (i Incident) Criteria() string {
+	switch {
+	case i.Tag != "" && i.Keyword != "":
+		return fmt.Sprintf("tag %s or %q", i.Tag, i.Keyword)
+	case i.Tag != "":
+		return "tag " + i.Tag
+	}
+	return fmt.Sprintf("%q", i.Keyword)
+}
+
+// IncidentChannel is a Slack channel subscribed to an incident, with the
+// thread the incident is tracked in there.
+type IncidentChannel struct {
+	IncidentID int64  `db:"incident_id"`
+	ChannelID  string `db:"channel_id"`
+	ThreadTS   string `db:"thread_ts"`
+}
+
+// IncidentTicket is a ticket grouped into an incident.
+type IncidentTicket struct {
+	IncidentID       int64     `db:"incident_id"`
+	TicketID         int64     `db:"ticket_id"`
+	Subject          string    `db:"subject"`
+	OrganizationID   int64     `db:"organization_id"`
+	OrganizationName string    `db:"organization_name"`
+	CreatedAt        time.Time `db:"created_at"`
+}
+
+// IncidentUpdate is a status update posted to an incident's subscribers.
+type IncidentUpdate struct {
+	ID         int64     `db:"id"`
+	IncidentID int64     `db:"incident_id"`
+	Message    string    `db:"message"`
+	PostedBy   string    `db:"posted_by"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+const incidentColumns = `id, name, tag, keyword, started_at, resolved_at, declared_by, created_at`
+
+// CreateIncident declares an incident and returns its ID.
+func CreateIncident(ctx context.Context, db db.Database, incident Incident) (int64, error) {
+	if incident.Tag == "" && incident.Keyword == "" {
+		return 0, errors.New("an incident needs a tag or keyword to match tickets")
+	}
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO incidents (name, tag, keyword, started_at, declared_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, incident.Name, incident.Tag, incident.Keyword, incident.StartedAt.UTC(), incident.DeclaredBy, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to create incident: %w", err)
+	}
+	return result.LastInsertId()
+}
+
+// GetIncident returns an incident, or nil when it does not exist.
+func GetIncident(db db.Database, incidentID int64) (*Incident, error) {
+	var incident Incident
+	err := db.Get(&incident, `SELECT `+incidentColumns+` FROM incidents WHERE id = $1`, incidentID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get incident: %w", err)
+	}
+	return &incident, nil
+}
+
+// GetIncidents returns unresolved incidents first, then resolved ones, newest first.
+func GetIncidents(db db.Database) ([]Incident, error) {
+	var incidents []Incident
+	err := db.Select(&incidents, `SELECT `+incidentColumns+` FROM incidents ORDER BY resolved_at IS NOT NULL, started_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get incidents: %w", err)
+	}
+	return incidents, nil
+}
+
+// GetUnresolvedIncidents returns the incidents that have not been resolved,
+// including any scheduled to start later.
+func GetUnresolvedIncidents(db db.Database) ([]Incident, error) {
+	var incidents []Incident
+	err := db.Select(&incidents, `SELECT `+incidentColumns+` FROM incidents WHERE resolved_at IS NULL ORDER BY started_at`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unresolved incidents: %w", err)
+	}
+	return incidents, nil
+}
+
+// ResolveIncident marks an incident resolved so it stops grouping tickets.
+func ResolveIncident(ctx context.Context, db db.Database, incidentID int64) error {
+	_, err := db.ExecContext(ctx, `UPDATE incidents SET resolved_at = $1 WHERE id = $2 AND resolved_at IS NULL`, time.Now().UTC(), incidentID)
+	if err != nil {
+		return fmt.Errorf("failed to resolve incident: %w", err)
+	}
+	return nil
+}
+
+// GetIncidentChannels returns the Slack channels subscribed to an incident.
+func GetIncidentChannels(db db.Database, incidentID int64) ([]IncidentChannel, error) {
+	var channels []IncidentChannel
+	err := db.Select(&channels, `SELECT incident_id, channel_id, thread_ts FROM incident_channels WHERE incident_id = $1 ORDER BY channel_id`, incidentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get incident channels: %w", err)
+	}
+	return channels, nil
+}
+
+// SaveIncidentChannel subscribes a channel to an incident, or records the
+// thread the incident was posted to in it.
+func SaveIncidentChannel(ctx context.Context, db db.Database, channel IncidentChannel) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO incident_channels (incident_id, channel_id, thread_ts) VALUES ($1, $2, $3)
+		ON CONFLICT(incident_id, channel_id) DO UPDATE SET thread_ts = excluded.thread_ts
+	`, channel.IncidentID, channel.ChannelID, channel.ThreadTS)
+	if err != nil {
+		return fmt.Errorf("failed to save incident channel: %w", err)
+	}
+	return nil
+}
+
+// DeleteIncidentChannel unsubscribes a channel from an incident.
+func DeleteIncidentChannel(ctx context.Context, db db.Database, incidentID int64, channelID string) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM incident_channels WHERE incident_id = $1 AND channel_id = $2`, incidentID, channelID)
+	if err != nil {
+		return fmt.Errorf("failed to delete incident channel: %w", err)
+	}
+	return nil
+}
+
+// AddIncidentTicket groups a ticket into an incident. It reports whether the
+// ticket is new to the incident.
+func AddIncidentTicket(ctx context.Context, db db.Database, ticket IncidentTicket) (bool, error) {
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO incident_tickets (incident_id, ticket_id, subject, organization_id, organization_name, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT(incident_id, ticket_id) DO NOTHING
+	`, ticket.IncidentID, ticket.TicketID, ticket.Subject, ticket.OrganizationID, ticket.OrganizationName, time.Now().UTC())
+	if err != nil {
+		return false, fmt.Errorf("failed to add incident ticket: %w", err)
+	}
+	added, err := result.RowsAffected()
+	return added > 0, err
+}
+
+// GetIncidentTickets returns the tickets grouped into an incident, oldest first.
+func GetIncidentTickets(db db.Database, incidentID int64) ([]IncidentTicket, error) {
+	var tickets []IncidentTicket
+	err := db.Select(&tickets, `
+		SELECT incident_id, ticket_id, subject, organization_id, organization_name, created_at
+		FROM incident_tickets
+		WHERE incident_id = $1
+		ORDER BY created_at, ticket_id
+	`, incidentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get incident tickets: %w", err)
+	}
+	return tickets, nil
+}
+
+// AddIncidentUpdate records a status update posted to an incident.
+func AddIncidentUpdate(ctx context.Context, db db.Database, update IncidentUpdate) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO incident_updates (incident_id, message, posted_by, created_at)
+		VALUES ($1, $2, $3, $4)
+	`, update.IncidentID, update.Message, update.PostedBy, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to add incident update: %w", err)
+	}
+	return nil
+}
+
+// GetIncidentUpdates returns an incident's status updates, newest first.
+func GetIncidentUpdates(db db.Database, incidentID int64) ([]IncidentUpdate, error) {
+	var updates []IncidentUpdate
+	err := db.Select(&updates, `
+		SELECT id, incident_id, message, posted_by, created_at
+		FROM incident_updates
+		WHERE incident_id = $1
+		ORDER BY created_at DESC, id DESC
+	`, incidentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get incident updates: %w", err)
+	}
+	return updates, nil
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+)
+
+// MuteCondition is what ends a ticket mute before its Until time.
+type MuteCondition string
+
+const (
+	MuteUntilTime           MuteCondition = "time"           // Only the Until time ends the mute
+	MuteUntilCustomerReply  MuteCondition = "customer_reply" // The requester commenting ends the mute
+	MuteUntilSLAStageChange MuteCondition = "sla_stage"      // The ticket's SLA stage changing ends the mute
+)
+
+// TicketMute silences alerts for a ticket, either for the rules a user owns
+// or for the rules that post to a Slack channel.
+type TicketMute struct {
+	ID        int64         `db:"id"`
+	TicketID  int64         `db:"ticket_id"`
+	UserID    sql.NullInt64 `db:"user_id"`
+	UserName  string        `db:"user_name"`
+	ChannelID string        `db:"channel_id"`
+	Condition MuteCondition `db:"condition"`
+	// SLAStage is the SLA stage when the ticket was muted, for mutes that end
+	// when it changes. Polling records it when Zendesk could not be reached.
+	SLAStage  string    `db:"sla_stage"`
+	Until     time.Time `db:"until"`
+	MutedBy   string    `db:"muted_by"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// Applies reports whether the mute silences the rule. User mutes cover the
+// rules the user owns and channel mutes cover the rules posting to the channel.
+func (m TicketMute) Applies(rule TagAlert) bool {
+	if m.UserID.Valid {
+		return !rule.TeamID.Valid && rule.UserID == int(m.UserID.Int64)
+	}
+	return m.ChannelID != "" && rule.SlackChannelID == m.ChannelID && !rule.OnCallScheduleID.Valid
+}
+
+// Description says how long the mute lasts.
+func (m TicketMute) Description() string {
+	switch m.Condition {
+	case MuteUntilCustomerReply:
+		return "until the customer replies"
+	case MuteUntilSLAStageChange:
+		return "until the SLA stage changes"
+	}
+	return "until " + m.Until.Local().Format("Jan 2 15:04")
+}
+
+// CreateTicketMute adds a mute. Muting a ticket again for the same user or
+// channel replaces the earlier mute.
+func CreateTicketMute(ctx context.Context, db db.Database, mute TicketMute) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM ticket_mutes WHERE ticket_id = $1 AND user_id IS $2 AND COALESCE(channel_id, '') = $3`,
+		mute.TicketID, mute.UserID, mute.ChannelID)
+	if err != nil {
+		return fmt.Errorf("failed to replace ticket mute: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO ticket_mutes (ticket_id, user_id, channel_id, condition, sla_stage, until, muted_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, mute.TicketID, mute.UserID, nullableString(mute.ChannelID), mute.Condition, nullableString(mute.SLAStage), mute.Until.UTC(), mute.MutedBy, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to create ticket mute: %w", err)
+	}
+	return tx.Commit()
+}
+
+const ticketMuteSelect = `
+	SELECT m.id, m.ticket_id, m.user_id, COALESCE(u.name, '') AS user_name, COALESCE(m.channel_id, '') AS channel_id,
+		m.condition, COALESCE(m.sla_stage, '') AS sla_stage, m.until, COALESCE(m.muted_by, '') AS muted_by, m.created_at
+	FROM ticket_mutes m
+	LEFT JOIN users u ON u.id = m.user_id
+`
+
+// GetActiveTicketMutes returns every mute that has not expired, grouped by ticket.
+func GetActiveTicketMutes(db db.Database) (map[int64][]TicketMute, error) {
+	var mutes []TicketMute
+	if err := db.Select(&mutes, ticketMuteSelect+`WHERE m.until > ? ORDER BY m.ticket_id, m.id`, time.Now().UTC()); err != nil {
+		return nil, fmt.Errorf("failed to get ticket mutes: %w", err)
+	}
+
+	byTicket := make(map[int64][]TicketMute)
+	for _, mute := range mutes {
+		byTicket[mute.TicketID] = append(byTicket[mute.TicketID], mute)
+	}
+	return byTicket, nil
+}
+
+// GetTicketMutes returns the unexpired mutes for a ticket.
+func GetTicketMutes(db db.Database, ticketID int64) ([]TicketMute, error) {
+	var mutes []TicketMute
+	if err := db.Select(&mutes, ticketMuteSelect+`WHERE m.ticket_id = ? AND m.until > ? ORDER BY m.id`, ticketID, time.Now().UTC()); err != nil {
+		return nil, fmt.Errorf("failed to get ticket mutes: %w", err)
+	}
+	return mutes, nil
+}
+
+// SetTicketMuteSLAStage records the SLA stage a mute is waiting to change from.
+func SetTicketMuteSLAStage(ctx context.Context, db db.Database, muteID int64, stage string) error {
+	if _, err := db.ExecContext(ctx, `UPDATE ticket_mutes SET sla_stage = $1 WHERE id = $2`, stage, muteID); err != nil {
+		return fmt.Errorf("failed to update ticket mute: %w", err)
+	}
+	return nil
+}
+
+// DeleteTicketMute ends a mute on the ticket.
+func DeleteTicketMute(ctx context.Context, db db.Database, ticketID, muteID int64) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM ticket_mutes WHERE ticket_id = $1 AND id = $2`, ticketID, muteID); err != nil {
+		return fmt.Errorf("failed to delete ticket mute: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredTicketMutes removes mutes whose Until time has passed.
+func DeleteExpiredTicketMutes(ctx context.Context, db db.Database) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM ticket_mutes WHERE until <= $1`, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to delete expired ticket mutes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTicketMuteApplies(t *testing.T) {
+	userMute := TicketMute{UserID: sql.NullInt64{Int64: 7, Valid: true}}
+	channelMute := TicketMute{ChannelID: "C1"}
+	team := sql.NullInt64{Int64: 3, Valid: true}
+	onCall := sql.NullInt64{Int64: 2, Valid: true}
+
+	tests := []struct {
+		name    string
+		mute    TicketMute
+		rule    TagAlert
+		applies bool
+	}{
+		{"user's own rule", userMute, TagAlert{UserID: 7, SlackChannelID: "C1"}, true},
+		{"another user's rule", userMute, TagAlert{UserID: 8, SlackChannelID: "C1"}, false},
+		{"team rule of a member", userMute, TagAlert{UserID: 7, TeamID: team}, false},
+		{"rule posting to the channel", channelMute, TagAlert{UserID: 8, SlackChannelID: "C1"}, true},
+		{"team rule posting to the channel", channelMute, TagAlert{TeamID: team, SlackChannelID: "C1"}, true},
+		{"rule posting elsewhere", channelMute, TagAlert{UserID: 8, SlackChannelID: "C2"}, false},
+		{"on-call rule with the channel as fallback", channelMute, TagAlert{UserID: 8, SlackChannelID: "C1", OnCallScheduleID: onCall}, false},
+		{"mute without an owner", TicketMute{}, TagAlert{UserID: 7}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.applies, tt.mute.Applies(tt.rule))
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+)
+
+// OnCallSchedule is a rotation through its members. Each member is on call
+// for RotationDays, handing over at the time of day RotationStart falls on.
+type OnCallSchedule struct {
+	ID            int       `db:"id"`
+	Name          string    `db:"name"`
+	RotationStart time.Time `db:"rotation_start"`
+	RotationDays  int       `db:"rotation_days"`
+	CreatedAt     time.Time `db:"created_at"`
+}
+
+// OnCallMember is a user's place in a schedule's rotation.
+type OnCallMember struct {
+	ScheduleID int    `db:"schedule_id"`
+	UserID     int    `db:"user_id"`
+	Name       string `db:"name"`
+	Email      string `db:"email"`
+	Position   int    `db:"position"`
+}
+
+// OnCallOverride puts a user on call for a period regardless of the rotation.
+type OnCallOverride struct {
+	ID         int       `db:"id"`
+	ScheduleID int       `db:"schedule_id"`
+	UserID     int       `db:"user_id"`
+	Name       string    `db:"name"`
+	Email      string    `db:"email"`
+	StartsAt   time.Time `db:"starts_at"`
+	EndsAt     time.Time `db:"ends_at"`
+	UID        string    `db:"uid"` // Calendar event UID for imported overrides
+}
+
+// CreateOnCallSchedule adds a new schedule.
+func CreateOnCallSchedule(db db.Database, name string, rotationStart time.Time, rotationDays int) error {
+	_, err := db.Exec(`INSERT INTO oncall_schedules (name, rotation_start, rotation_days) VALUES (?, ?, ?)`,
+		name, rotationStart.UTC(), rotationDays)
+	return err
+}
+
+// UpdateOnCallSchedule changes a schedule's name and rotation.
+func UpdateOnCallSchedule(db db.Database, schedule OnCallSchedule) error {
+	_, err := db.Exec(`UPDATE oncall_schedules SET name = ?, rotation_start = ?, rotation_days = ? WHERE id = ?`,
+		schedule.Name, schedule.RotationStart.UTC(), schedule.RotationDays, schedule.ID)
+	return err
+}
+
+// GetOnCallScheduleByID retrieves a schedule by its ID.
+func GetOnCallScheduleByID(db db.Database, scheduleID int) (OnCallSchedule, error) {
+	var schedule OnCallSchedule
+	err := db.Get(&schedule, `SELECT id, name, rotation_start, rotation_days, created_at FROM oncall_schedules WHERE id = ?`, scheduleID)
+	return schedule, err
+}
+
+// GetAllOnCallSchedules retrieves every schedule ordered by name.
+func GetAllOnCallSchedules(db db.Database) ([]OnCallSchedule, error) {
+	var schedules []OnCallSchedule
+	err := db.Select(&schedules, `SELECT id, name, rotation_start, rotation_days, created_at FROM oncall_schedules ORDER BY name`)
+	return schedules, err
+}
+
+// DeleteOnCallSchedule removes a schedule with its members and overrides.
+// Rules that targeted the schedule go back to their owner and Slack channel.
+func DeleteOnCallSchedule(db db.Database, scheduleID int) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		`UPDATE user_tag_alerts SET oncall_schedule_id = NULL WHERE oncall_schedule_id = ?`,
+		`DELETE FROM oncall_overrides WHERE schedule_id = ?`,
+		`DELETE FROM oncall_members WHERE schedule_id = ?`,
+		`DELETE FROM oncall_schedules WHERE id = ?`,
+	} {
+		if _, err := tx.Exec(stmt, scheduleID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetOnCallMembers returns a schedule's members in rotation order.
+func GetOnCallMembers(db db.Database, scheduleID int) ([]OnCallMember, error) {
+	var members []OnCallMember
+	err := db.Select(&members, `
+		SELECT m.schedule_id, m.user_id, u.name, u.email, m.position
+		FROM oncall_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.schedule_id = ?
+		ORDER BY m.position, m.id
+	`, scheduleID)
+	return members, err
+}
+
+// AddOnCallMember appends a user to the end of a schedule's rotation.
+func AddOnCallMember(db db.Database, scheduleID, userID int) error {
+	_, err := db.Exec(`
+		INSERT INTO oncall_members (schedule_id, user_id, position)
+		VALUES (?, ?, (SELECT COALESCE(MAX(position), 0) + 1 FROM oncall_members WHERE schedule_id = ?))
+		ON CONFLICT(schedule_id, user_id) DO NOTHING
+	`, scheduleID, userID, scheduleID)
+	return err
+}
+
+// RemoveOnCallMember takes a user out of a schedule's rotation.
+func RemoveOnCallMember(db db.Database, scheduleID, userID int) error {
+	_, err := db.Exec(`DELETE FROM oncall_members WHERE schedule_id = ? AND user_id = ?`, scheduleID, userID)
+	return err
+}
+
+const onCallOverrideSelect = `
+	SELECT o.id, o.schedule_id, o.user_id, u.name, u.email, o.starts_at, o.ends_at, COALESCE(o.uid, '') AS uid
+	FROM oncall_overrides o
+	JOIN users u ON u.id = o.user_id
+`
+
+// GetUpcomingOnCallOverrides returns a schedule's current and future overrides in start order.
+func GetUpcomingOnCallOverrides(db db.Database, scheduleID int, now time.Time) ([]OnCallOverride, error) {
+	var overrides []OnCallOverride
+	err := db.Select(&overrides, onCallOverrideSelect+`WHERE o.schedule_id = ? AND o.ends_at > ? ORDER BY o.starts_at`,
+		scheduleID, now.UTC())
+	return overrides, err
+}
+
+// GetActiveOnCallOverride returns the override covering now, preferring the
+// most recently added when several overlap, or nil if there is none.
+func GetActiveOnCallOverride(db db.Database, scheduleID int, now time.Time) (*OnCallOverride, error) {
+	var overrides []OnCallOverride
+	err := db.Select(&overrides, onCallOverrideSelect+`WHERE o.schedule_id = ? AND o.starts_at <= ? AND o.ends_at > ? ORDER BY o.id DESC LIMIT 1`,
+		scheduleID, now.UTC(), now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get on-call override: %w", err)
+	}
+	if len(overrides) == 0 {
+		return nil, nil
+	}
+	return &overrides[0], nil
+}
+
+// SaveOnCallOverride adds an override. Imported overrides with a UID replace
+// the override previously imported from the same calendar event.
+func SaveOnCallOverride(ctx context.Context, db db.Database, override OnCallOverride) error {
+	uid := nullableString(override.UID)
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO oncall_overrides (schedule_id, user_id, starts_at, ends_at, uid) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT(schedule_id, uid) DO UPDATE SET
+			user_id = excluded.user_id,
+			starts_at = excluded.starts_at,
+			ends_at = excluded.ends_at
+	`, override.ScheduleID, override.UserID, override.StartsAt.UTC(), override.EndsAt.UTC(), uid)
+	if err != nil {
+		return fmt.Errorf("failed to save on-call override: %w", err)
+	}
+	return nil
+}
+
+// DeleteOnCallOverride removes one of a schedule's overrides.
+func DeleteOnCallOverride(db db.Database, scheduleID, overrideID int) error {
+	_, err := db.Exec(`DELETE FROM oncall_overrides WHERE schedule_id = ? AND id = ?`, scheduleID, overrideID)
+	return err
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"github.com/TylerConlee/TicketPulse/db"
+)
+
+// NotificationPreference records whether a user wants a given alert type
+// delivered over a given notification channel.
+type NotificationPreference struct {
+	UserID    int    `db:"user_id"`
+	AlertType string `db:"alert_type"`
+	Channel   string `db:"channel"`
+	Enabled   bool   `db:"enabled"`
+}
+
+// GetNotificationPreferences returns the user's saved preferences keyed by alert type and then channel.
+// Combinations the user has never saved are absent so callers can apply their own defaults.
+func GetNotificationPreferences(db db.Database, userID int) (map[string]map[string]bool, error) {
+	var rows []NotificationPreference
+	err := db.Select(&rows, `SELECT user_id, alert_type, channel, enabled FROM notification_preferences WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	prefs := make(map[string]map[string]bool)
+	for _, row := range rows {
+		if _, ok := prefs[row.AlertType]; !ok {
+			prefs[row.AlertType] = make(map[string]bool)
+		}
+		prefs[row.AlertType][row.Channel] = row.Enabled
+	}
+	return prefs, nil
+}
+
+// SetNotificationPreference saves whether the user wants an alert type delivered over a channel.
+func SetNotificationPreference(db db.Database, pref NotificationPreference) error {
+	_, err := db.Exec(`
+		INSERT INTO notification_preferences (user_id, alert_type, channel, enabled) VALUES (?, ?, ?, ?)
+		ON CONFLICT(user_id, alert_type, channel) DO UPDATE SET enabled = excluded.enabled
+	`, pref.UserID, pref.AlertType, pref.Channel, pref.Enabled)
+	return err
+}
@@ -0,0 +1,174 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+)
+
+// QuietHoursAction is what happens to a personal alert that arrives during quiet hours.
+type QuietHoursAction string
+
+const (
+	QuietHoursHold    QuietHoursAction = "hold"    // Deliver once quiet hours end
+	QuietHoursDrop    QuietHoursAction = "drop"    // Discard the alert
+	QuietHoursReroute QuietHoursAction = "reroute" // Deliver to the backup user instead
+)
+
+// QuietHours is a user's do-not-disturb schedule. Start and End are "15:04"
+// times in the server's time zone; an End before Start spans midnight.
+type QuietHours struct {
+	UserID        int              `db:"user_id"`
+	Enabled       bool             `db:"enabled"`
+	Start         string           `db:"start_time"`
+	End           string           `db:"end_time"`
+	Days          string           `db:"days"` // Comma separated weekdays, Sunday is 0
+	Action        QuietHoursAction `db:"action"`
+	BackupUserID  sql.NullInt64    `db:"backup_user_id"`
+	AllowBreaches bool             `db:"allow_breaches"` // Let SLA breaches through regardless
+}
+
+// HasDay reports whether quiet hours start on the given weekday.
+func (q QuietHours) HasDay(day time.Weekday) bool {
+	return hasWeekday(q.Days, day)
+}
+
+// Active reports whether now falls within the user's quiet hours. A window
+// that spans midnight belongs to the day it starts on.
+func (q QuietHours) Active(now time.Time) bool {
+	if !q.Enabled {
+		return false
+	}
+	start, err := time.Parse("15:04", q.Start)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse("15:04", q.End)
+	if err != nil {
+		return false
+	}
+
+	minute := now.Hour()*60 + now.Minute()
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+
+	if startMinute <= endMinute {
+		return q.HasDay(now.Weekday()) && minute >= startMinute && minute < endMinute
+	}
+	if minute >= startMinute {
+		return q.HasDay(now.Weekday())
+	}
+	return minute < endMinute && q.HasDay(now.AddDate(0, 0, -1).Weekday())
+}
+
+// GetQuietHours returns the user's quiet hours. Users who have never saved
+// quiet hours get a disabled overnight schedule to start from.
+func GetQuietHours(db db.Database, userID int) (QuietHours, error) {
+	var q QuietHours
+	err := db.Get(&q, `
+		SELECT user_id, enabled, start_time, end_time, days, action, backup_user_id, allow_breaches
+		FROM quiet_hours WHERE user_id = ?
+	`, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return QuietHours{UserID: userID, Start: "22:00", End: "07:00", Days: "0,1,2,3,4,5,6", Action: QuietHoursHold}, nil
+	}
+	if err != nil {
+		return q, fmt.Errorf("failed to get quiet hours: %w", err)
+	}
+	return q, nil
+}
+
+// SaveQuietHours creates or replaces the user's quiet hours.
+func SaveQuietHours(db db.Database, q QuietHours) error {
+	_, err := db.Exec(`
+		INSERT INTO quiet_hours (user_id, enabled, start_time, end_time, days, action, backup_user_id, allow_breaches)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET
+			enabled = excluded.enabled,
+			start_time = excluded.start_time,
+			end_time = excluded.end_time,
+			days = excluded.days,
+			action = excluded.action,
+			backup_user_id = excluded.backup_user_id,
+			allow_breaches = excluded.allow_breaches
+	`, q.UserID, q.Enabled, q.Start, q.End, q.Days, q.Action, q.BackupUserID, q.AllowBreaches)
+	if err != nil {
+		return fmt.Errorf("failed to save quiet hours: %w", err)
+	}
+	return nil
+}
+
+// HeldNotification is a personal alert held until the recipient's quiet hours end.
+type HeldNotification struct {
+	ID             int64     `db:"id"`
+	UserID         int       `db:"user_id"`
+	Channel        string    `db:"channel"`
+	AlertType      string    `db:"alert_type"`
+	TicketID       int64     `db:"ticket_id"`
+	Subject        string    `db:"subject"`
+	Status         string    `db:"status"`
+	Priority       string    `db:"priority"`
+	RequesterID    int64     `db:"requester_id"`
+	OrganizationID int64     `db:"organization_id"`
+	Tag            string    `db:"tag"`
+	SLALabel       string    `db:"sla_label"`
+	CreatedAt      time.Time `db:"created_at"`
+}
+
+// HoldNotification stores an alert for delivery after quiet hours. A ticket
+// already held for the same channel and alert type is updated in place.
+func HoldNotification(ctx context.Context, db db.Database, held HeldNotification) error {
+	query := `
+		INSERT INTO held_notifications (user_id, channel, alert_type, ticket_id, subject, status, priority, requester_id, organization_id, tag, sla_label, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT(user_id, channel, ticket_id, alert_type) DO UPDATE SET
+			subject = excluded.subject,
+			status = excluded.status,
+			priority = excluded.priority,
+			sla_label = excluded.sla_label
+	`
+	_, err := db.ExecContext(ctx, query, held.UserID, held.Channel, held.AlertType, held.TicketID,
+		held.Subject, held.Status, held.Priority, held.RequesterID, held.OrganizationID, held.Tag, held.SLALabel, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to hold notification: %w", err)
+	}
+	return nil
+}
+
+// GetHeldNotifications returns every held notification in the order it was held.
+func GetHeldNotifications(db db.Database) ([]HeldNotification, error) {
+	var held []HeldNotification
+	query := `
+		SELECT id, user_id, channel, alert_type, ticket_id, subject,
+			COALESCE(status, '') AS status, COALESCE(priority, '') AS priority,
+			COALESCE(requester_id, 0) AS requester_id, COALESCE(organization_id, 0) AS organization_id,
+			COALESCE(tag, '') AS tag, COALESCE(sla_label, '') AS sla_label, created_at
+		FROM held_notifications
+		ORDER BY id
+	`
+	if err := db.Select(&held, query); err != nil {
+		return nil, fmt.Errorf("failed to get held notifications: %w", err)
+	}
+	return held, nil
+}
+
+// CountHeldNotifications returns how many notifications are held for the user.
+func CountHeldNotifications(db db.Database, userID int) (int, error) {
+	var count int
+	if err := db.Get(&count, `SELECT COUNT(*) FROM held_notifications WHERE user_id = ?`, userID); err != nil {
+		return 0, fmt.Errorf("failed to count held notifications: %w", err)
+	}
+	return count, nil
+}
+
+// DeleteHeldNotification removes a held notification once it has been delivered.
+func DeleteHeldNotification(ctx context.Context, db db.Database, id int64) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM held_notifications WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete held notification: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuietHoursActive(t *testing.T) {
+	// January 2, 2026 is a Friday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 1, day, hour, minute, 0, 0, time.Local)
+	}
+	overnight := QuietHours{Enabled: true, Start: "22:00", End: "07:00", Days: "1,2,3,4,5"}
+	daytime := QuietHours{Enabled: true, Start: "09:00", End: "17:00", Days: "1,2,3,4,5"}
+
+	tests := []struct {
+		name   string
+		quiet  QuietHours
+		now    time.Time
+		active bool
+	}{
+		{"Friday before the window", overnight, at(2, 21, 59), false},
+		{"Friday as the window starts", overnight, at(2, 22, 0), true},
+		{"Saturday morning after a Friday start", overnight, at(3, 2, 0), true},
+		{"Saturday as the window ends", overnight, at(3, 7, 0), false},
+		{"Saturday night, not a quiet day", overnight, at(3, 23, 0), false},
+		{"Monday morning after a Sunday night", overnight, at(5, 3, 0), false},
+		{"Monday night", overnight, at(5, 23, 30), true},
+		{"Tuesday morning after a Monday start", overnight, at(6, 6, 59), true},
+		{"weekday within the window", daytime, at(5, 9, 0), true},
+		{"weekday as the window ends", daytime, at(5, 17, 0), false},
+		{"weekend within the window hours", daytime, at(3, 12, 0), false},
+		{"disabled", QuietHours{Start: "22:00", End: "07:00", Days: "5"}, at(2, 23, 0), false},
+		{"invalid start", QuietHours{Enabled: true, Start: "10pm", End: "07:00", Days: "5"}, at(2, 23, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.active, tt.quiet.Active(tt.now))
+		})
+	}
+}
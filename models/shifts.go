@@ -0,0 +1,171 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+)
+
+// Shift is a recurring block of time a team works, such as APAC, EMEA or
+// AMER. When a shift ends, a handoff report is posted to its Slack channel.
+// Start and End are "15:04" times in the server's time zone; an End at or
+// before Start runs past midnight.
+type Shift struct {
+	ID             int64        `db:"id"`
+	TeamID         int          `db:"team_id"`
+	Name           string       `db:"name"`
+	Start          string       `db:"start_time"`
+	End            string       `db:"end_time"`
+	Days           string       `db:"days"` // Comma separated weekdays the shift starts on, Sunday is 0
+	SlackChannelID string       `db:"slack_channel_id"`
+	LastReportAt   sql.NullTime `db:"last_report_at"` // End of the last shift a handoff report was posted for
+	CreatedAt      time.Time    `db:"created_at"`
+}
+
+// HasDay reports whether the shift starts on the given weekday.
+func (s Shift) HasDay(day time.Weekday) bool {
+	return hasWeekday(s.Days, day)
+}
+
+// DayLabels lists the shift's weekdays, starting on Monday.
+func (s Shift) DayLabels() string {
+	var labels []string
+	for i := 1; i <= 7; i++ {
+		if day := time.Weekday(i % 7); s.HasDay(day) {
+			labels = append(labels, day.String()[:3])
+		}
+	}
+	return strings.Join(labels, ", ")
+}
+
+// occurrence returns when the shift starting on the given day begins and ends.
+func (s Shift) occurrence(day time.Time) (time.Time, time.Time, bool) {
+	start, err := time.Parse("15:04", s.Start)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	end, err := time.Parse("15:04", s.End)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+
+	year, month, date := day.Date()
+	startAt := time.Date(year, month, date, start.Hour(), start.Minute(), 0, 0, day.Location())
+	endAt := time.Date(year, month, date, end.Hour(), end.Minute(), 0, 0, day.Location())
+	if !endAt.After(startAt) {
+		endAt = endAt.AddDate(0, 0, 1)
+	}
+	return startAt, endAt, true
+}
+
+// LastEnded returns the start and end of the most recent shift that ended at
+// or before now.
+func (s Shift) LastEnded(now time.Time) (time.Time, time.Time, bool) {
+	for i := 0; i <= 7; i++ {
+		day := now.AddDate(0, 0, -i)
+		if !s.HasDay(day.Weekday()) {
+			continue
+		}
+		start, end, ok := s.occurrence(day)
+		if ok && !end.After(now) {
+			return start, end, true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// NextEnding returns the start and end of the first shift that ends after the
+// given time, which may already be in progress.
+func (s Shift) NextEnding(after time.Time) (time.Time, time.Time, bool) {
+	for i := -1; i <= 7; i++ {
+		day := after.AddDate(0, 0, i)
+		if !s.HasDay(day.Weekday()) {
+			continue
+		}
+		start, end, ok := s.occurrence(day)
+		if ok && end.After(after) {
+			return start, end, true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// hasWeekday reports whether a comma separated list of weekdays includes the day.
+func hasWeekday(days string, day time.Weekday) bool {
+	for _, value := range strings.Split(days, ",") {
+		if d, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && time.Weekday(d) == day {
+			return true
+		}
+	}
+	return false
+}
+
+const shiftColumns = `id, team_id, name, start_time, end_time, days, slack_channel_id, last_report_at, created_at`
+
+// CreateShift adds a shift to a team.
+func CreateShift(db db.Database, shift Shift) error {
+	_, err := db.Exec(`
+		INSERT INTO shifts (team_id, name, start_time, end_time, days, slack_channel_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, shift.TeamID, shift.Name, shift.Start, shift.End, shift.Days, shift.SlackChannelID, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to create shift: %w", err)
+	}
+	return nil
+}
+
+// GetShift returns a shift, or nil when it does not exist.
+func GetShift(db db.Database, shiftID int64) (*Shift, error) {
+	var shift Shift
+	err := db.Get(&shift, `SELECT `+shiftColumns+` FROM shifts WHERE id = ?`, shiftID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shift: %w", err)
+	}
+	return &shift, nil
+}
+
+// GetShiftsByTeam returns a team's shifts in the order they start.
+func GetShiftsByTeam(db db.Database, teamID int) ([]Shift, error) {
+	var shifts []Shift
+	err := db.Select(&shifts, `SELECT `+shiftColumns+` FROM shifts WHERE team_id = ? ORDER BY start_time, name`, teamID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get team shifts: %w", err)
+	}
+	return shifts, nil
+}
+
+// GetAllShifts returns every team's shifts.
+func GetAllShifts(db db.Database) ([]Shift, error) {
+	var shifts []Shift
+	err := db.Select(&shifts, `SELECT `+shiftColumns+` FROM shifts ORDER BY team_id, start_time, name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shifts: %w", err)
+	}
+	return shifts, nil
+}
+
+// DeleteShift removes a shift.
+func DeleteShift(db db.Database, shiftID int64) error {
+	if _, err := db.Exec(`DELETE FROM shifts WHERE id = ?`, shiftID); err != nil {
+		return fmt.Errorf("failed to delete shift: %w", err)
+	}
+	return nil
+}
+
+// MarkShiftReported records that the handoff report for the shift ending at
+// the given time has been posted.
+func MarkShiftReported(ctx context.Context, db db.Database, shiftID int64, endedAt time.Time) error {
+	_, err := db.ExecContext(ctx, `UPDATE shifts SET last_report_at = $1 WHERE id = $2`, endedAt.UTC(), shiftID)
+	if err != nil {
+		return fmt.Errorf("failed to mark shift reported: %w", err)
+	}
+	return nil
+}
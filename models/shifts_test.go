@@ -0,0 +1,80 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShiftLastEnded(t *testing.T) {
+	// January 2, 2026 is a Friday
+	jan := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.January, day, hour, minute, 0, 0, time.Local)
+	}
+	weekendNights := Shift{Start: "22:00", End: "06:00", Days: "0,6"}
+	weekdays := Shift{Start: "09:00", End: "17:00", Days: "1,2,3,4,5"}
+
+	tests := []struct {
+		name  string
+		shift Shift
+		now   time.Time
+		start time.Time
+		end   time.Time
+		found bool
+	}{
+		{"Saturday night shift still running, back to the week before", weekendNights, jan(4, 5, 59), time.Date(2025, time.December, 28, 22, 0, 0, 0, time.Local), time.Date(2025, time.December, 29, 6, 0, 0, 0, time.Local), true},
+		{"Saturday night shift as it ends on Sunday", weekendNights, jan(4, 6, 0), jan(3, 22, 0), jan(4, 6, 0), true},
+		{"Sunday night shift started", weekendNights, jan(4, 23, 0), jan(3, 22, 0), jan(4, 6, 0), true},
+		{"Sunday night shift as it ends on Monday", weekendNights, jan(5, 6, 0), jan(4, 22, 0), jan(5, 6, 0), true},
+		{"later in the week", weekendNights, jan(9, 12, 0), jan(4, 22, 0), jan(5, 6, 0), true},
+		{"Monday morning after a weekday shift", weekdays, jan(5, 8, 0), jan(2, 9, 0), jan(2, 17, 0), true},
+		{"weekday shift as it ends", weekdays, jan(5, 17, 0), jan(5, 9, 0), jan(5, 17, 0), true},
+		{"shift that lasts a whole day", Shift{Start: "09:00", End: "09:00", Days: "5"}, jan(3, 9, 0), jan(2, 9, 0), jan(3, 9, 0), true},
+		{"no days", Shift{Start: "22:00", End: "06:00"}, jan(4, 6, 0), time.Time{}, time.Time{}, false},
+		{"invalid end", Shift{Start: "22:00", End: "6am", Days: "0,6"}, jan(4, 6, 0), time.Time{}, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, found := tt.shift.LastEnded(tt.now)
+			assert.Equal(t, tt.found, found)
+			assert.True(t, tt.start.Equal(start), "start %s, expected %s", start, tt.start)
+			assert.True(t, tt.end.Equal(end), "end %s, expected %s", end, tt.end)
+		})
+	}
+}
+
+func TestShiftNextEnding(t *testing.T) {
+	// January 2, 2026 is a Friday
+	jan := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.January, day, hour, minute, 0, 0, time.Local)
+	}
+	weekendNights := Shift{Start: "22:00", End: "06:00", Days: "0,6"}
+	weekdays := Shift{Start: "09:00", End: "17:00", Days: "1,2,3,4,5"}
+
+	tests := []struct {
+		name  string
+		shift Shift
+		after time.Time
+		start time.Time
+		end   time.Time
+		found bool
+	}{
+		{"Friday before the weekend", weekendNights, jan(2, 23, 0), jan(3, 22, 0), jan(4, 6, 0), true},
+		{"Saturday night shift in progress past midnight", weekendNights, jan(4, 5, 0), jan(3, 22, 0), jan(4, 6, 0), true},
+		{"Saturday night shift as it ends", weekendNights, jan(4, 6, 0), jan(4, 22, 0), jan(5, 6, 0), true},
+		{"Sunday night shift as it ends wraps to the next weekend", weekendNights, jan(5, 6, 0), jan(10, 22, 0), jan(11, 6, 0), true},
+		{"weekday shift in progress", weekdays, jan(5, 12, 0), jan(5, 9, 0), jan(5, 17, 0), true},
+		{"Friday weekday shift as it ends", weekdays, jan(2, 17, 0), jan(5, 9, 0), jan(5, 17, 0), true},
+		{"no days", Shift{Start: "09:00", End: "17:00"}, jan(5, 12, 0), time.Time{}, time.Time{}, false},
+		{"invalid start", Shift{Start: "9am", End: "17:00", Days: "1"}, jan(5, 12, 0), time.Time{}, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, found := tt.shift.NextEnding(tt.after)
+			assert.Equal(t, tt.found, found)
+			assert.True(t, tt.start.Equal(start), "start %s, expected %s", start, tt.start)
+			assert.True(t, tt.end.Equal(end), "end %s, expected %s", end, tt.end)
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+)
+
+// TicketDuration is how long a solved ticket took to get its first reply and
+// to be solved, kept to predict how long similar open tickets will take.
+type TicketDuration struct {
+	TicketID          int64         `db:"ticket_id"`
+	Priority          string        `db:"priority"`
+	GroupID           int64         `db:"group_id"`
+	Tags              string        `db:"tags"` // Space separated ticket tags
+	FirstReplyMinutes sql.NullInt64 `db:"first_reply_minutes"`
+	SolveMinutes      sql.NullInt64 `db:"solve_minutes"`
+	RecordedAt        time.Time     `db:"recorded_at"`
+}
+
+// TagList returns the ticket's tags.
+func (d TicketDuration) TagList() []string {
+	return strings.Fields(d.Tags)
+}
+
+// RecordTicketDuration stores a solved ticket's durations, replacing any
+// earlier record of the ticket.
+func RecordTicketDuration(ctx context.Context, db db.Database, duration TicketDuration) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO ticket_durations (ticket_id, priority, group_id, tags, first_reply_minutes, solve_minutes, recorded_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT(ticket_id) DO UPDATE SET
+			priority = excluded.priority,
+			group_id = excluded.group_id,
+			tags = excluded.tags,
+			first_reply_minutes = excluded.first_reply_minutes,
+			solve_minutes = excluded.solve_minutes,
+			recorded_at = excluded.recorded_at
+	`, duration.TicketID, duration.Priority, duration.GroupID, duration.Tags, duration.FirstReplyMinutes, duration.SolveMinutes, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to record ticket duration: %w", err)
+	}
+	return nil
+}
+
+// GetTicketDurationsSince returns the durations recorded since the given time.
+func GetTicketDurationsSince(db db.Database, since time.Time) ([]TicketDuration, error) {
+	var durations []TicketDuration
+	err := db.Select(&durations, `
+		SELECT ticket_id, priority, group_id, tags, first_reply_minutes, solve_minutes, recorded_at
+		FROM ticket_durations
+		WHERE recorded_at >= $1
+	`, since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ticket durations: %w", err)
+	}
+	return durations, nil
+}
+
+// TicketDurationRecorded reports whether the ticket's durations have already been recorded.
+func TicketDurationRecorded(db db.Database, ticketID int64) (bool, error) {
+	var count int
+	if err := db.Get(&count, `SELECT COUNT(*) FROM ticket_durations WHERE ticket_id = ?`, ticketID); err != nil {
+		return false, fmt.Errorf("failed to check ticket duration: %w", err)
+	}
+	return count > 0, nil
+}
+
+// PruneTicketDurations deletes durations recorded before the given time.
+func PruneTicketDurations(ctx context.Context, db db.Database, before time.Time) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM ticket_durations WHERE recorded_at < $1`, before.UTC()); err != nil {
+		return fmt.Errorf("failed to prune ticket durations: %w", err)
+	}
+	return nil
+}
+
+// SLARiskScore is the predicted chance that an open ticket breaches an SLA
+// metric, as of the latest poll.
+type SLARiskScore struct {
+	TicketID  int64     `db:"ticket_id"`
+	Subject   string    `db:"subject"`
+	Metric    string    `db:"metric"`
+	BreachAt  time.Time `db:"breach_at"`
+	Score     int       `db:"score"` // Percent chance of a breach
+	Samples   int       `db:"samples"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// ReplaceSLARiskScores replaces every stored risk score with the latest poll's.
+func ReplaceSLARiskScores(ctx context.Context, db db.Database, scores []SLARiskScore) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to replace SLA risk scores: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sla_risk_scores`); err != nil {
+		return fmt.Errorf("failed to clear SLA risk scores: %w", err)
+	}
+	now := time.Now().UTC()
+	for _, score := range scores {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO sla_risk_scores (ticket_id, subject, metric, breach_at, score, samples, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`, score.TicketID, score.Subject, score.Metric, score.BreachAt.UTC(), score.Score, score.Samples, now)
+		if err != nil {
+			return fmt.Errorf("failed to save SLA risk score: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetSLARiskScores returns the tickets at or above the given risk that have
+// not breached yet, riskiest first.
+func GetSLARiskScores(db db.Database, minScore int) ([]SLARiskScore, error) {
+	var scores []SLARiskScore
+	err := db.Select(&scores, `
+		SELECT ticket_id, subject, metric, breach_at, score, samples, updated_at
+		FROM sla_risk_scores
+		WHERE score >= $1 AND breach_at > $2
+		ORDER BY score DESC, breach_at
+	`, minScore, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get SLA risk scores: %w", err)
+	}
+	return scores, nil
+}
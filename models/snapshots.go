@@ -0,0 +1,93 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+)
+
+// TicketSnapshot is the last polled state of a ticket's key fields, so the
+// next poll can tell what changed.
+type TicketSnapshot struct {
+	TicketID          int64        `db:"ticket_id"`
+	Status            string       `db:"status"`
+	Priority          string       `db:"priority"`
+	AssigneeID        int64        `db:"assignee_id"`
+	GroupID           int64        `db:"group_id"`
+	Tags              string       `db:"tags"`               // Space separated ticket tags
+	SatisfactionScore string       `db:"satisfaction_score"` // Empty until a survey is offered
+	UpdatedAt         sql.NullTime `db:"updated_at"`
+	ObservedAt        time.Time    `db:"observed_at"`
+}
+
+// TagList returns the ticket's tags when the snapshot was taken.
+func (s TicketSnapshot) TagList() []string {
+	return strings.Fields(s.Tags)
+}
+
+// GetTicketSnapshots returns the snapshots of the given tickets keyed by
+// ticket ID. Tickets that have not been polled before are left out.
+func GetTicketSnapshots(db db.Database, ticketIDs []int64) (map[int64]TicketSnapshot, error) {
+	snapshots := make(map[int64]TicketSnapshot)
+	if len(ticketIDs) == 0 {
+		return snapshots, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ticketIDs)), ", ")
+	args := make([]interface{}, len(ticketIDs))
+	for i, id := range ticketIDs {
+		args[i] = id
+	}
+
+	var rows []TicketSnapshot
+	err := db.Select(&rows, `
+		SELECT ticket_id, status, priority, assignee_id, group_id, tags, satisfaction_score, updated_at, observed_at
+		FROM ticket_snapshots
+		WHERE ticket_id IN (`+placeholders+`)
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ticket snapshots: %w", err)
+	}
+	for _, snapshot := range rows {
+		snapshots[snapshot.TicketID] = snapshot
+	}
+	return snapshots, nil
+}
+
+// SaveTicketSnapshot stores a ticket's current state, replacing its previous snapshot.
+func SaveTicketSnapshot(ctx context.Context, db db.Database, snapshot TicketSnapshot) error {
+	var updatedAt sql.NullTime
+	if snapshot.UpdatedAt.Valid {
+		updatedAt = sql.NullTime{Time: snapshot.UpdatedAt.Time.UTC(), Valid: true}
+	}
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO ticket_snapshots (ticket_id, status, priority, assignee_id, group_id, tags, satisfaction_score, updated_at, observed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT(ticket_id) DO UPDATE SET
+			status = excluded.status,
+			priority = excluded.priority,
+			assignee_id = excluded.assignee_id,
+			group_id = excluded.group_id,
+			tags = excluded.tags,
+			satisfaction_score = excluded.satisfaction_score,
+			updated_at = excluded.updated_at,
+			observed_at = excluded.observed_at
+	`, snapshot.TicketID, snapshot.Status, snapshot.Priority, snapshot.AssigneeID, snapshot.GroupID, snapshot.Tags, snapshot.SatisfactionScore, updatedAt, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save ticket snapshot: %w", err)
+	}
+	return nil
+}
+
+// PruneTicketSnapshots deletes snapshots of tickets that have not been polled
+// since the given time.
+func PruneTicketSnapshots(ctx context.Context, db db.Database, before time.Time) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM ticket_snapshots WHERE observed_at < $1`, before.UTC()); err != nil {
+		return fmt.Errorf("failed to prune ticket snapshots: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+)
+
+// TagAlias maps a Zendesk tag onto a canonical tag, so that tags spelled
+// differently count as one logical tag.
+type TagAlias struct {
+	ID        int       `db:"id"`
+	Alias     string    `db:"alias"`
+	Canonical string    `db:"canonical"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// GetTagAliases returns the tag aliases ordered by canonical tag and alias.
+func GetTagAliases(db db.Database) ([]TagAlias, error) {
+	var aliases []TagAlias
+	err := db.Select(&aliases, `SELECT id, alias, canonical, created_at FROM tag_aliases ORDER BY canonical, alias`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tag aliases: %w", err)
+	}
+	return aliases, nil
+}
+
+// AddTagAlias maps an alias onto a canonical tag, replacing any earlier
+// mapping for the alias. Tags are stored in lower case so they match
+// regardless of how they were entered.
+func AddTagAlias(db db.Database, alias TagAlias) error {
+	name := strings.ToLower(strings.TrimSpace(alias.Alias))
+	canonical := strings.ToLower(strings.TrimSpace(alias.Canonical))
+	if name == canonical {
+		return errors.New("a tag cannot be an alias of itself")
+	}
+	_, err := db.Exec(`
+		INSERT INTO tag_aliases (alias, canonical) VALUES (?, ?)
+		ON CONFLICT(alias) DO UPDATE SET canonical = excluded.canonical
+	`, name, canonical)
+	if err != nil {
+		return fmt.Errorf("failed to add tag alias: %w", err)
+	}
+	return nil
+}
+
+// DeleteTagAlias removes a tag alias.
+func DeleteTagAlias(db db.Database, aliasID int) error {
+	if _, err := db.Exec(`DELETE FROM tag_aliases WHERE id = ?`, aliasID); err != nil {
+		return fmt.Errorf("failed to delete tag alias: %w", err)
+	}
+	return nil
+}
+
+// CatalogTag is a Zendesk tag in the local tag catalog, with how many
+// tickets Zendesk says carry it and when a polled ticket last had it.
+type CatalogTag struct {
+	Name        string       `db:"name"`
+	TicketCount int64        `db:"ticket_count"`
+	LastSeenAt  sql.NullTime `db:"last_seen_at"`
+	SyncedAt    sql.NullTime `db:"synced_at"`
+	CreatedAt   time.Time    `db:"created_at"`
+}
+
+// GetTagCatalog returns the catalog's tags, most used first.
+func GetTagCatalog(db db.Database) ([]CatalogTag, error) {
+	var tags []CatalogTag
+	err := db.Select(&tags, `
+		SELECT name, ticket_count, last_seen_at, synced_at, created_at
+		FROM tag_catalog
+		ORDER BY ticket_count DESC, name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tag catalog: %w", err)
+	}
+	return tags, nil
+}
+
+// SaveCatalogTag records a tag and its usage count from Zendesk's tag list.
+func SaveCatalogTag(ctx context.Context, db db.Database, name string, ticketCount int64) error {
+	now := time.Now().UTC()
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO tag_catalog (name, ticket_count, synced_at, created_at) VALUES ($1, $2, $3, $3)
+		ON CONFLICT(name) DO UPDATE SET
+			ticket_count = excluded.ticket_count,
+			synced_at = excluded.synced_at
+	`, name, ticketCount, now)
+	if err != nil {
+		return fmt.Errorf("failed to save catalog tag: %w", err)
+	}
+	return nil
+}
+
+// RecordTagSeen notes that a polled ticket carried the tag at the given time,
+// adding the tag to the catalog if it is not there yet.
+func RecordTagSeen(ctx context.Context, db db.Database, name string, seenAt time.Time) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO tag_catalog (name, ticket_count, last_seen_at, created_at) VALUES ($1, 0, $2, $3)
+		ON CONFLICT(name) DO UPDATE SET
+			last_seen_at = CASE
+				WHEN last_seen_at IS NULL OR last_seen_at < excluded.last_seen_at THEN excluded.last_seen_at
+				ELSE last_seen_at
+			END
+	`, name, seenAt.UTC(), time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to record tag use: %w", err)
+	}
+	return nil
+}
+
+// LastTagCatalogSync returns when the catalog was last synced from Zendesk,
+// or the zero time if it never has been.
+func LastTagCatalogSync(db db.Database) (time.Time, error) {
+	var syncedAt time.Time
+	err := db.Get(&syncedAt, `SELECT synced_at FROM tag_catalog WHERE synced_at IS NOT NULL ORDER BY synced_at DESC LIMIT 1`)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get last tag catalog sync: %w", err)
+	}
+	return syncedAt, nil
+}
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+)
+
+type TeamRole string
+
+const (
+	TeamLeadRole   TeamRole = "lead"
+	TeamMemberRole TeamRole = "member"
+)
+
+type Team struct {
+	ID        int       `db:"id"`
+	Name      string    `db:"name"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// TeamMember links a user to a team along with their role on that team.
+type TeamMember struct {
+	TeamID   int      `db:"team_id"`
+	TeamName string   `db:"team_name"`
+	UserID   int      `db:"user_id"`
+	Name     string   `db:"name"`
+	Email    string   `db:"email"`
+	Role     TeamRole `db:"role"`
+}
+
+// IsLead reports whether the member leads the team.
+func (m TeamMember) IsLead() bool {
+	return m.Role == TeamLeadRole
+}
+
+// CreateTeam adds a new team
+func CreateTeam(db db.Database, name string) error {
+	_, err := db.Exec(`INSERT INTO teams (name) VALUES (?)`, name)
+	return err
+}
+
+// GetTeamByID retrieves a team by its ID
+func GetTeamByID(db db.Database, teamID int) (Team, error) {
+	var team Team
+	err := db.Get(&team, `SELECT id, name, created_at FROM teams WHERE id = ?`, teamID)
+	return team, err
+}
+
+// GetAllTeams retrieves every team ordered by name
+func GetAllTeams(db db.Database) ([]Team, error) {
+	var teams []Team
+	err := db.Select(&teams, `SELECT id, name, created_at FROM teams ORDER BY name`)
+	return teams, err
+}
+
+// DeleteTeam removes a team along with its memberships, rules and shifts
+func DeleteTeam(db db.Database, teamID int) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		`DELETE FROM user_tag_alerts WHERE team_id = ?`,
+		`UPDATE user_tag_alerts SET assign_team_id = NULL WHERE assign_team_id = ?`,
+		`DELETE FROM shifts WHERE team_id = ?`,
+		`DELETE FROM team_members WHERE team_id = ?`,
+		`DELETE FROM teams WHERE id = ?`,
+	} {
+		if _, err := tx.Exec(stmt, teamID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SetTeamMember adds a user to a team or changes their role if they are already a member
+func SetTeamMember(db db.Database, teamID, userID int, role TeamRole) error {
+	_, err := db.Exec(`
+		INSERT INTO team_members (team_id, user_id, role) VALUES (?, ?, ?)
+		ON CONFLICT(team_id, user_id) DO UPDATE SET role = excluded.role
+	`, teamID, userID, role)
+	return err
+}
+
+// RemoveTeamMember removes a user from a team
+func RemoveTeamMember(db db.Database, teamID, userID int) error {
+	_, err := db.Exec(`DELETE FROM team_members WHERE team_id = ? AND user_id = ?`, teamID, userID)
+	return err
+}
+
+// GetTeamMembers retrieves the members of a team, leads first
+func GetTeamMembers(db db.Database, teamID int) ([]TeamMember, error) {
+	var members []TeamMember
+	err := db.Select(&members, `
+		SELECT tm.team_id, t.name AS team_name, tm.user_id, u.name, u.email, tm.role
+		FROM team_members tm
+		INNER JOIN teams t ON tm.team_id = t.id
+		INNER JOIN users u ON tm.user_id = u.id
+		WHERE tm.team_id = ?
+		ORDER BY tm.role = 'lead' DESC, u.name
+	`, teamID)
+	return members, err
+}
+
+// GetTeamsForUser retrieves the user's memberships across all teams
+func GetTeamsForUser(db db.Database, userID int) ([]TeamMember, error) {
+	var memberships []TeamMember
+	err := db.Select(&memberships, `
+		SELECT tm.team_id, t.name AS team_name, tm.user_id, u.name, u.email, tm.role
+		FROM team_members tm
+		INNER JOIN teams t ON tm.team_id = t.id
+		INNER JOIN users u ON tm.user_id = u.id
+		WHERE tm.user_id = ?
+		ORDER BY t.name
+	`, userID)
+	return memberships, err
+}
+
+// IsTeamLead reports whether the user leads the given team
+func IsTeamLead(db db.Database, teamID, userID int) (bool, error) {
+	var count int
+	err := db.Get(&count, `SELECT COUNT(*) FROM team_members WHERE team_id = ? AND user_id = ? AND role = 'lead'`, teamID, userID)
+	return count > 0, err
+}
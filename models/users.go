@@ -3,7 +3,9 @@ package models
 import (
 	"database/sql"
 	"encoding/gob"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/TylerConlee/TicketPulse/db"
@@ -30,6 +32,7 @@ type User struct {
 	SelectedTags []TagAlert     // New field for storing tag-specific alerts
 	SummaryTime  sql.NullTime   // The preferred time for the daily summary
 	SlackUserID  sql.NullString // The user's Slack ID for direct messages
+	WebhookURL   sql.NullString // Endpoint for webhook notifications
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
 }
@@ -37,12 +40,90 @@ type User struct {
 type TagAlert struct {
 	ID             int
 	UserID         int
+	TeamID         sql.NullInt64 // Set when the rule is owned by a team rather than a user
+	TeamName       string
 	Tag            string
 	SlackChannelID string
 	AlertType      string
+	PagerDuty      bool // Trigger a PagerDuty incident for SLA breaches
+	DigestInterval int  // Minutes between digests of channel posts; zero posts immediately
+	RateLimit      int  // Maximum channel posts per rate limit window; zero is unlimited
+	// OnCallScheduleID routes the rule to whoever is on call for the schedule
+	// instead of the rule's owner and Slack channel
+	OnCallScheduleID   sql.NullInt64
+	OnCallScheduleName string
+	// Organization limits the rule to tickets from a Zendesk organization, by ID or name
+	Organization string
+	VIPOnly      bool // Limit the rule to tickets from VIP customers
+	// GroupID is the Zendesk group a group_changed rule waits for tickets to
+	// move to; zero matches a move to any group
+	GroupID int64
+	// ThresholdMinutes is how long a ticket must be unassigned, waiting on an
+	// agent, or pending before an age rule fires
+	ThresholdMinutes int
+	// Keywords limits the rule to tickets whose subject, description, or new
+	// public comments contain one of these phrases, one per line
+	Keywords             string
+	KeywordRegex         bool // Treat each keyword as a regular expression
+	KeywordCaseSensitive bool
+	KeywordWholeWord     bool // Only match keywords at word boundaries
+	// SpikeFactor is how many times the usual ticket volume a volume_spike
+	// rule waits for; ThresholdMinutes is its window, defaulting to an hour
+	SpikeFactor float64
+	// AssignTeamID assigns the new, unassigned tickets the rule matches to the
+	// team's members in turn
+	AssignTeamID   sql.NullInt64
+	AssignTeamName string
+	AssignMaxOpen  int  // Skip members with this many open tickets; zero is no cap
 	User           User // Add User field to associate with the alert
 }
 
+// KeywordList returns the rule's keywords, skipping blank lines.
+func (a TagAlert) KeywordList() []string {
+	var keywords []string
+	for _, line := range strings.Split(a.Keywords, "\n") {
+		if keyword := strings.TrimSpace(line); keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+	return keywords
+}
+
+// ThresholdLabel describes the rule's age threshold, such as "4 hours".
+func (a TagAlert) ThresholdLabel() string {
+	return DurationLabel(time.Duration(a.ThresholdMinutes) * time.Minute)
+}
+
+// SpikeLabel describes the rule's volume spike threshold, such as
+// "3x usual volume in 1 hour".
+func (a TagAlert) SpikeLabel() string {
+	window := a.ThresholdMinutes
+	if window <= 0 {
+		window = 60
+	}
+	return fmt.Sprintf("%gx usual volume in %s", a.SpikeFactor, DurationLabel(time.Duration(window)*time.Minute))
+}
+
+// DurationLabel describes a duration in the largest whole unit that fits,
+// such as "45 minutes", "4 hours", or "3 days".
+func DurationLabel(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour:
+		return pluralize(int(d/(24*time.Hour)), "day")
+	case d >= 2*time.Hour:
+		return pluralize(int(d/time.Hour), "hour")
+	default:
+		return pluralize(int(d/time.Minute), "minute")
+	}
+}
+
+func pluralize(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", unit)
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
 // CreateUser adds a new user to the database
 func CreateUser(db db.Database, email, name string, role Role, dailySummary bool) error {
 
@@ -71,11 +152,17 @@ func UpdateSlackUserID(db db.Database, email string, slackUserID string) error {
 	return nil
 }
 
+// UpdateWebhookURL sets the endpoint used for the user's webhook notifications.
+func UpdateWebhookURL(db db.Database, userID int, webhookURL string) error {
+	_, err := db.Exec(`UPDATE users SET webhook_url = ? WHERE id = ?`, sql.NullString{String: webhookURL, Valid: webhookURL != ""}, userID)
+	return err
+}
+
 // GetUserByEmail retrieves a user by their email
 func GetUserByEmail(db db.Database, email string) (User, error) {
 	var user User
-	row := db.QueryRow("SELECT id, email, name, role, daily_summary, slack_user_id FROM users WHERE LOWER(email) = LOWER(?)", email)
-	err := row.Scan(&user.ID, &user.Email, &user.Name, &user.Role, &user.DailySummary, &user.SlackUserID)
+	row := db.QueryRow("SELECT id, email, name, role, daily_summary, slack_user_id, webhook_url FROM users WHERE LOWER(email) = LOWER(?)", email)
+	err := row.Scan(&user.ID, &user.Email, &user.Name, &user.Role, &user.DailySummary, &user.SlackUserID, &user.WebhookURL)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// Return a special error to indicate that the user was not found
@@ -86,12 +173,27 @@ func GetUserByEmail(db db.Database, email string) (User, error) {
 	return user, nil
 }
 
+// GetUserBySlackID retrieves the user linked to a Slack user ID. Like
+// GetUserByEmail it returns a zero User when there is no match.
+func GetUserBySlackID(db db.Database, slackUserID string) (User, error) {
+	var user User
+	row := db.QueryRow("SELECT id, email, name, role, daily_summary, slack_user_id, webhook_url FROM users WHERE slack_user_id = ?", slackUserID)
+	err := row.Scan(&user.ID, &user.Email, &user.Name, &user.Role, &user.DailySummary, &user.SlackUserID, &user.WebhookURL)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return user, nil
+		}
+		return user, err
+	}
+	return user, nil
+}
+
 // GetUserByID retrieves a user by their ID
 func GetUserByID(db db.Database, id int) (User, error) {
-	row := db.QueryRow(`SELECT id, email, name, role, daily_summary, summary_time, slack_user_id FROM users WHERE id = ?`, id)
+	row := db.QueryRow(`SELECT id, email, name, role, daily_summary, summary_time, slack_user_id, webhook_url FROM users WHERE id = ?`, id)
 	var user User
 
-	err := row.Scan(&user.ID, &user.Email, &user.Name, &user.Role, &user.DailySummary, &user.SummaryTime, &user.SlackUserID)
+	err := row.Scan(&user.ID, &user.Email, &user.Name, &user.Role, &user.DailySummary, &user.SummaryTime, &user.SlackUserID, &user.WebhookURL)
 	if err != nil {
 		return user, err
 	}
@@ -155,6 +257,9 @@ func GetFirstUserID(db db.Database) (int, error) {
 }
 
 func DeleteUserByID(db db.Database, userID int) error {
+	if _, err := db.Exec("DELETE FROM team_members WHERE user_id = ?", userID); err != nil {
+		return err
+	}
 	_, err := db.Exec("DELETE FROM users WHERE id = ?", userID)
 	return err
 }
@@ -168,16 +273,34 @@ func GetUserCount(db db.Database) (int, error) {
 	return count, nil
 }
 
-// CreateTagAlert adds a new tag alert configuration for a user
-func CreateTagAlert(db db.Database, userID int, tag, slackChannelID, alertType string) error {
-	_, err := db.Exec(`INSERT INTO user_tag_alerts (user_id, tag, slack_channel_id, alert_type) VALUES (?, ?, ?, ?)`,
-		userID, tag, slackChannelID, alertType)
-	return err
+// tagAlertColumns is the column list shared by every query that loads tag alerts
+// joined with their owning user and team.
+const tagAlertColumns = `
+	uta.id, uta.user_id, uta.team_id, uta.tag, uta.slack_channel_id, uta.alert_type, uta.pagerduty, uta.digest_interval, uta.rate_limit,
+	uta.oncall_schedule_id, uta.organization, uta.vip_only, uta.group_id, uta.threshold_minutes,
+	uta.keywords, uta.keyword_regex, uta.keyword_case_sensitive, uta.keyword_whole_word, uta.spike_factor, uta.assign_team_id, uta.assign_max_open,
+	COALESCE(u.id, 0), COALESCE(u.name, ''), COALESCE(u.email, ''), COALESCE(t.name, ''), COALESCE(s.name, ''), COALESCE(at.name, '')`
+
+const tagAlertJoins = `
+	FROM user_tag_alerts uta
+	LEFT JOIN users u ON uta.user_id = u.id
+	LEFT JOIN teams t ON uta.team_id = t.id
+	LEFT JOIN oncall_schedules s ON uta.oncall_schedule_id = s.id
+	LEFT JOIN teams at ON uta.assign_team_id = at.id`
+
+// nullableID stores zero IDs as NULL.
+func nullableID(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
 }
 
-// GetTagAlertsByUser retrieves all tag alerts for a specific user
-func GetTagAlertsByUser(db db.Database, userID int) ([]TagAlert, error) {
-	rows, err := db.Query(`SELECT id, user_id, tag, slack_channel_id, alert_type FROM user_tag_alerts WHERE user_id = ?`, userID)
+// nullableString stores empty strings as NULL.
+func nullableString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// queryTagAlerts runs a tag alert query and scans the results.
+func queryTagAlerts(db db.Database, where string, args ...interface{}) ([]TagAlert, error) {
+	rows, err := db.Query(`SELECT `+tagAlertColumns+tagAlertJoins+` `+where, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -186,13 +309,54 @@ func GetTagAlertsByUser(db db.Database, userID int) ([]TagAlert, error) {
 	var alerts []TagAlert
 	for rows.Next() {
 		var alert TagAlert
-		err = rows.Scan(&alert.ID, &alert.UserID, &alert.Tag, &alert.SlackChannelID, &alert.AlertType)
+		var userID sql.NullInt64
+		err = rows.Scan(&alert.ID, &userID, &alert.TeamID, &alert.Tag, &alert.SlackChannelID, &alert.AlertType, &alert.PagerDuty, &alert.DigestInterval, &alert.RateLimit,
+			&alert.OnCallScheduleID, &alert.Organization, &alert.VIPOnly, &alert.GroupID, &alert.ThresholdMinutes,
+			&alert.Keywords, &alert.KeywordRegex, &alert.KeywordCaseSensitive, &alert.KeywordWholeWord, &alert.SpikeFactor, &alert.AssignTeamID, &alert.AssignMaxOpen,
+			&alert.User.ID, &alert.User.Name, &alert.User.Email, &alert.TeamName, &alert.OnCallScheduleName, &alert.AssignTeamName)
 		if err != nil {
 			return nil, err
 		}
+		alert.UserID = int(userID.Int64)
 		alerts = append(alerts, alert)
 	}
-	return alerts, nil
+	return alerts, rows.Err()
+}
+
+// CreateTagAlert adds a new tag alert configuration for a user or team
+func CreateTagAlert(db db.Database, alert TagAlert) error {
+	userID := nullableID(alert.UserID)
+	if alert.TeamID.Valid {
+		// Team rules are not tied to the user who created them
+		userID = sql.NullInt64{}
+	}
+	_, err := db.Exec(`INSERT INTO user_tag_alerts (user_id, team_id, tag, slack_channel_id, alert_type, pagerduty, digest_interval, rate_limit, oncall_schedule_id, organization, vip_only, group_id, threshold_minutes,
+		keywords, keyword_regex, keyword_case_sensitive, keyword_whole_word, spike_factor, assign_team_id, assign_max_open) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		userID, alert.TeamID, alert.Tag, alert.SlackChannelID, alert.AlertType, alert.PagerDuty, alert.DigestInterval, alert.RateLimit, alert.OnCallScheduleID, alert.Organization, alert.VIPOnly, alert.GroupID, alert.ThresholdMinutes,
+		alert.Keywords, alert.KeywordRegex, alert.KeywordCaseSensitive, alert.KeywordWholeWord, alert.SpikeFactor, alert.AssignTeamID, alert.AssignMaxOpen)
+	return err
+}
+
+// GetTagAlertByID retrieves a single tag alert
+func GetTagAlertByID(db db.Database, alertID int) (TagAlert, error) {
+	alerts, err := queryTagAlerts(db, `WHERE uta.id = ?`, alertID)
+	if err != nil {
+		return TagAlert{}, err
+	}
+	if len(alerts) == 0 {
+		return TagAlert{}, sql.ErrNoRows
+	}
+	return alerts[0], nil
+}
+
+// GetTagAlertsByUser retrieves all personal tag alerts for a specific user
+func GetTagAlertsByUser(db db.Database, userID int) ([]TagAlert, error) {
+	return queryTagAlerts(db, `WHERE uta.user_id = ? AND uta.team_id IS NULL`, userID)
+}
+
+// GetTagAlertsByTeam retrieves all tag alerts owned by a team
+func GetTagAlertsByTeam(db db.Database, teamID int) ([]TagAlert, error) {
+	return queryTagAlerts(db, `WHERE uta.team_id = ?`, teamID)
 }
 
 // DeleteTagAlert removes a specific tag alert configuration
@@ -200,33 +364,22 @@ func DeleteTagAlert(db db.Database, alertID int) error {
 	_, err := db.Exec(`DELETE FROM user_tag_alerts WHERE id = ?`, alertID)
 	return err
 }
-func GetAllTagAlerts(db db.Database) ([]TagAlert, error) {
-	rows, err := db.Query(`
-		SELECT 
-			uta.id, uta.tag, uta.slack_channel_id, uta.alert_type, 
-			u.id, u.name, u.email 
-		FROM 
-			user_tag_alerts uta 
-		INNER JOIN 
-			users u ON uta.user_id = u.id
-	`)
+
+// DeleteUserTagAlert removes one of a user's personal rules. It reports false
+// when the user has no such rule, so rules owned by other users or teams are
+// never deleted.
+func DeleteUserTagAlert(db db.Database, alertID, userID int) (bool, error) {
+	result, err := db.Exec(`DELETE FROM user_tag_alerts WHERE id = ? AND user_id = ? AND team_id IS NULL`, alertID, userID)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	defer rows.Close()
+	deleted, err := result.RowsAffected()
+	return deleted > 0, err
+}
 
-	var alerts []TagAlert
-	for rows.Next() {
-		var alert TagAlert
-		var user User
-		err = rows.Scan(&alert.ID, &alert.Tag, &alert.SlackChannelID, &alert.AlertType, &user.ID, &user.Name, &user.Email)
-		if err != nil {
-			return nil, err
-		}
-		alert.User = user // Now this assignment works
-		alerts = append(alerts, alert)
-	}
-	return alerts, nil
+// GetAllTagAlerts retrieves every rule whose owning user or team still exists
+func GetAllTagAlerts(db db.Database) ([]TagAlert, error) {
+	return queryTagAlerts(db, `WHERE u.id IS NOT NULL OR t.id IS NOT NULL`)
 }
 
 // UpdateDailySummarySettings updates the user's daily summary settings.
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+)
+
+// TicketArrival records when a ticket was created and what it was about, so
+// volume spike rules can count arrivals per tag and organization.
+type TicketArrival struct {
+	TicketID       int64     `db:"ticket_id"`
+	Tags           string    `db:"tags"` // Space separated ticket tags
+	OrganizationID int64     `db:"organization_id"`
+	RequesterID    int64     `db:"requester_id"`
+	CreatedAt      time.Time `db:"created_at"`
+}
+
+// TagList returns the ticket's tags.
+func (a TicketArrival) TagList() []string {
+	return strings.Fields(a.Tags)
+}
+
+// RecordTicketArrival stores a ticket's arrival, updating its tags and
+// organization if they changed after it was created.
+func RecordTicketArrival(ctx context.Context, db db.Database, arrival TicketArrival) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO ticket_arrivals (ticket_id, tags, organization_id, requester_id, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT(ticket_id) DO UPDATE SET
+			tags = excluded.tags,
+			organization_id = excluded.organization_id,
+			requester_id = excluded.requester_id
+	`, arrival.TicketID, arrival.Tags, arrival.OrganizationID, arrival.RequesterID, arrival.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to record ticket arrival: %w", err)
+	}
+	return nil
+}
+
+// GetTicketArrivalsSince returns the tickets created since the given time, oldest first.
+func GetTicketArrivalsSince(db db.Database, since time.Time) ([]TicketArrival, error) {
+	var arrivals []TicketArrival
+	err := db.Select(&arrivals, `
+		SELECT ticket_id, tags, organization_id, requester_id, created_at
+		FROM ticket_arrivals
+		WHERE created_at >= $1
+		ORDER BY created_at
+	`, since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ticket arrivals: %w", err)
+	}
+	return arrivals, nil
+}
+
+// ticketArrivalsSinceKey is the configuration key holding when ticket
+// arrivals started being recorded.
+const ticketArrivalsSinceKey = "ticket_arrivals_since"
+
+// TicketArrivalsRecordedSince returns the time from which every ticket
+// arrival has been recorded, or the zero time if recording has not started.
+func TicketArrivalsRecordedSince(db db.Database) (time.Time, error) {
+	value, err := GetConfiguration(db, ticketArrivalsSinceKey)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get ticket arrival recording start: %w", err)
+	}
+	if value == "" {
+		return time.Time{}, nil
+	}
+	since, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse ticket arrival recording start: %w", err)
+	}
+	return since, nil
+}
+
+// StartRecordingTicketArrivals records that every ticket arrival from the
+// given time on will be recorded. Later calls keep the original time.
+func StartRecordingTicketArrivals(db db.Database, since time.Time) error {
+	_, err := db.Exec(`
+		INSERT INTO configuration (key, value) VALUES (?, ?)
+		ON CONFLICT(key) DO NOTHING
+	`, ticketArrivalsSinceKey, since.UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to start recording ticket arrivals: %w", err)
+	}
+	return nil
+}
+
+// PruneTicketArrivals deletes arrivals of tickets created before the given time.
+func PruneTicketArrivals(ctx context.Context, db db.Database, before time.Time) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM ticket_arrivals WHERE created_at < $1`, before.UTC()); err != nil {
+		return fmt.Errorf("failed to prune ticket arrivals: %w", err)
+	}
+	return nil
+}
+
+// VolumeSpikeRecord is a volume spike a rule alerted on.
+type VolumeSpikeRecord struct {
+	ID          int64     `db:"id"`
+	RuleID      int       `db:"rule_id"`
+	TicketCount int       `db:"ticket_count"`
+	Baseline    float64   `db:"baseline"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// RecordVolumeSpike stores a volume spike a rule alerted on.
+func RecordVolumeSpike(ctx context.Context, db db.Database, spike VolumeSpikeRecord) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO volume_spikes (rule_id, ticket_count, baseline, created_at) VALUES ($1, $2, $3, $4)
+	`, spike.RuleID, spike.TicketCount, spike.Baseline, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to record volume spike: %w", err)
+	}
+	return nil
+}
+
+// LastVolumeSpike returns the rule's most recent volume spike, or nil if it has never fired.
+func LastVolumeSpike(db db.Database, ruleID int) (*VolumeSpikeRecord, error) {
+	var spike VolumeSpikeRecord
+	err := db.Get(&spike, `
+		SELECT id, rule_id, ticket_count, baseline, created_at
+		FROM volume_spikes
+		WHERE rule_id = ?
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, ruleID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last volume spike: %w", err)
+	}
+	return &spike, nil
+}
+
+// PruneVolumeSpikes deletes volume spikes recorded before the given time.
+func PruneVolumeSpikes(ctx context.Context, db db.Database, before time.Time) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM volume_spikes WHERE created_at < $1`, before.UTC()); err != nil {
+		return fmt.Errorf("failed to prune volume spikes: %w", err)
+	}
+	return nil
+}
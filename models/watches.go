@@ -0,0 +1,78 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+)
+
+// TicketWatch subscribes a user to a ticket's alerts until it is solved.
+type TicketWatch struct {
+	UserID    int       `db:"user_id"`
+	TicketID  int64     `db:"ticket_id"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// WatchTicket adds the ticket to the user's watchlist.
+func WatchTicket(ctx context.Context, db db.Database, userID int, ticketID int64) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO ticket_watches (user_id, ticket_id, created_at) VALUES ($1, $2, $3)
+		ON CONFLICT(user_id, ticket_id) DO NOTHING
+	`, userID, ticketID, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to watch ticket: %w", err)
+	}
+	return nil
+}
+
+// UnwatchTicket removes the ticket from the user's watchlist.
+func UnwatchTicket(ctx context.Context, db db.Database, userID int, ticketID int64) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM ticket_watches WHERE user_id = $1 AND ticket_id = $2`, userID, ticketID); err != nil {
+		return fmt.Errorf("failed to unwatch ticket: %w", err)
+	}
+	return nil
+}
+
+// GetWatchedTickets returns the user's watchlist, most recently watched first.
+func GetWatchedTickets(db db.Database, userID int) ([]TicketWatch, error) {
+	var watches []TicketWatch
+	err := db.Select(&watches, `SELECT user_id, ticket_id, created_at FROM ticket_watches WHERE user_id = ? ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get watched tickets: %w", err)
+	}
+	return watches, nil
+}
+
+// IsWatchingTicket reports whether the ticket is on the user's watchlist.
+func IsWatchingTicket(db db.Database, userID int, ticketID int64) (bool, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM ticket_watches WHERE user_id = ? AND ticket_id = ?`, userID, ticketID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check ticket watch: %w", err)
+	}
+	return count > 0, nil
+}
+
+// GetTicketWatchers returns the IDs of the users watching each watched ticket.
+func GetTicketWatchers(db db.Database) (map[int64][]int, error) {
+	var watches []TicketWatch
+	if err := db.Select(&watches, `SELECT user_id, ticket_id, created_at FROM ticket_watches ORDER BY ticket_id, user_id`); err != nil {
+		return nil, fmt.Errorf("failed to get ticket watchers: %w", err)
+	}
+
+	watchers := make(map[int64][]int)
+	for _, watch := range watches {
+		watchers[watch.TicketID] = append(watchers[watch.TicketID], watch.UserID)
+	}
+	return watchers, nil
+}
+
+// DeleteTicketWatches removes the ticket from every watchlist.
+func DeleteTicketWatches(ctx context.Context, db db.Database, ticketID int64) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM ticket_watches WHERE ticket_id = $1`, ticketID); err != nil {
+		return fmt.Errorf("failed to delete ticket watches: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,341 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/nukosuke/go-zendesk/zendesk"
+)
+
+// ageAlertRetention is how long age alerts are remembered. Tickets still
+// stuck in the same state after this long are raised again.
+const ageAlertRetention = 30 * 24 * time.Hour
+
+// ageAlertTypes are the alert types that fire on how long a ticket has been
+// in a state rather than on an event.
+var ageAlertTypes = []string{AlertTypeUnassigned, AlertTypeNoAgentReply, AlertTypePendingTooLong}
+
+// ageSearchLimit is how many results the Zendesk search API returns for a
+// query before it refuses further pages.
+const ageSearchLimit = 1000
+
+// TicketDates holds the metric timestamps Zendesk sideloads with a ticket.
+type TicketDates struct {
+	StatusUpdatedAt    *time.Time `json:"status_updated_at"`
+	RequesterUpdatedAt *time.Time `json:"requester_updated_at"`
+}
+
+// IsAgeAlertType reports whether the alert type fires on how long a ticket has been in a state.
+func IsAgeAlertType(alertType string) bool {
+	for _, age := range ageAlertTypes {
+		if alertType == age {
+			return true
+		}
+	}
+	return false
+}
+
+// ageCondition reports whether the ticket is in the state an age alert type
+// waits on, and when it entered that state. New and open tickets may be
+// waiting on an agent since the requester last updated them; processAgeRules
+// checks their comments for a later public agent reply before alerting.
+func ageCondition(alertType string, ticket zendesk.Ticket, dates TicketDates) (time.Time, bool) {
+	if ticket.CreatedAt == nil {
+		return time.Time{}, false
+	}
+	statusSince := *ticket.CreatedAt
+	if dates.StatusUpdatedAt != nil {
+		statusSince = *dates.StatusUpdatedAt
+	}
+	requesterSince := *ticket.CreatedAt
+	if dates.RequesterUpdatedAt != nil {
+		requesterSince = *dates.RequesterUpdatedAt
+	}
+	waitingOnAgent := ticket.Status == "new" || ticket.Status == "open"
+
+	switch alertType {
+	case AlertTypeUnassigned:
+		return *ticket.CreatedAt, waitingOnAgent && ticket.AssigneeID == 0
+	case AlertTypeNoAgentReply:
+		return requesterSince, waitingOnAgent
+	case AlertTypePendingTooLong:
+		return statusSince, ticket.Status == "pending"
+	}
+	return time.Time{}, false
+}
+
+// ageRuleFires reports whether the ticket has been in an age rule's state for
+// at least the rule's threshold at now, and since when. Rules without a
+// threshold never fire.
+func ageRuleFires(rule models.TagAlert, ticket zendesk.Ticket, dates TicketDates, now time.Time) (time.Time, bool) {
+	if rule.ThresholdMinutes <= 0 {
+		return time.Time{}, false
+	}
+	since, ok := ageCondition(rule.AlertType, ticket, dates)
+	if !ok || now.Sub(since) < time.Duration(rule.ThresholdMinutes)*time.Minute {
+		return time.Time{}, false
+	}
+	return since, true
+}
+
+// ageRuleQuery returns the ticket search for an age rule at now. Each age
+// state begins after a ticket is created, so tickets newer than the rule's
+// threshold are left out along with those outside its tag and organization.
+func ageRuleQuery(rule models.TagAlert, now time.Time) string {
+	query := "type:ticket"
+	switch rule.AlertType {
+	case AlertTypeUnassigned:
+		query += " status<pending assignee:none"
+	case AlertTypeNoAgentReply:
+		query += " status<pending"
+	case AlertTypePendingTooLong:
+		query += " status:pending"
+	}
+	createdBefore := now.Add(-time.Duration(rule.ThresholdMinutes) * time.Minute)
+	query += " created<" + createdBefore.UTC().Format(time.RFC3339)
+	return query + ruleSearchFilters(rule)
+}
+
+// ageLabel describes how long a ticket has been in an age alert type's state,
+// such as "Unassigned for 2 hours".
+func ageLabel(alertType string, elapsed time.Duration) string {
+	switch alertType {
+	case AlertTypeUnassigned:
+		return "Unassigned for " + models.DurationLabel(elapsed)
+	case AlertTypeNoAgentReply:
+		return "Waiting on an agent for " + models.DurationLabel(elapsed)
+	case AlertTypePendingTooLong:
+		return "Pending for " + models.DurationLabel(elapsed)
+	}
+	return ""
+}
+
+// processAgeRules alerts on unsolved tickets that have been unassigned,
+// waiting on an agent, or pending for longer than each age rule's threshold.
+// Stale tickets are not updated, so each rule searches for the tickets it
+// could alert on rather than checking only the polled ones. A rule alerts
+// once each time a ticket enters the state.
+func processAgeRules(ctx context.Context, db db.Database, zc *ZendeskClient, notificationService *NotificationService) {
+	rules, err := models.GetAllTagAlerts(db)
+	if err != nil {
+		fmt.Println("Error fetching user alerts:", err)
+		return
+	}
+	var ageRules []models.TagAlert
+	for _, rule := range rules {
+		if IsAgeAlertType(rule.AlertType) && rule.ThresholdMinutes > 0 {
+			ageRules = append(ageRules, rule)
+		}
+	}
+	if len(ageRules) == 0 {
+		return
+	}
+
+	mutes, err := models.GetActiveTicketMutes(db)
+	if err != nil {
+		fmt.Println("Error fetching ticket mutes:", err)
+	}
+	shadowMode := ShadowModeEnabled(db)
+	customers := NewCustomerResolver(db, zc)
+
+	// Comments are only fetched for tickets about to alert, once per poll
+	agentReplies := make(map[int64]time.Time)
+	agentRepliedSince := func(ticket zendesk.Ticket, since time.Time) (bool, error) {
+		repliedAt, ok := agentReplies[ticket.ID]
+		if !ok {
+			var err error
+			if repliedAt, err = zc.LatestPublicAgentReply(ticket.ID); err != nil {
+				return false, err
+			}
+			agentReplies[ticket.ID] = repliedAt
+		}
+		return repliedAt.After(since), nil
+	}
+
+	for _, rule := range ageRules {
+		tickets, slaData, dates, err := zc.SearchTicketsWithDates(ageRuleQuery(rule, time.Now()))
+		if err != nil {
+			log.Printf("Error searching tickets for age rule %d: %v", rule.ID, err)
+			continue
+		}
+
+		for _, ticket := range tickets {
+			since, ok := ageRuleFires(rule, ticket, dates[ticket.ID], time.Now())
+			if !ok {
+				continue
+			}
+			if !ruleMatches(rule, ticket.Tags, ticket.OrganizationID, ticket.RequesterID, customers) {
+				continue
+			}
+			matches, ok := keywordMatches(rule, ticket, nil)
+			if !ok {
+				continue
+			}
+			if ticketMuted(mutes[ticket.ID], rule) {
+				log.Printf("Ticket #%d is muted for rule %d, skipping alert", ticket.ID, rule.ID)
+				continue
+			}
+
+			sent, err := models.AgeAlertSent(ctx, db, rule.ID, ticket.ID, since)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			if sent {
+				continue
+			}
+			if rule.AlertType == AlertTypeNoAgentReply {
+				replied, err := agentRepliedSince(ticket, since)
+				if err != nil {
+					log.Printf("Failed to retrieve comments for Ticket #%d: %v", ticket.ID, err)
+					continue
+				}
+				if replied {
+					continue
+				}
+			}
+			if err := models.RecordAgeAlert(ctx, db, rule.ID, ticket.ID, since); err != nil {
+				log.Println(err)
+			}
+
+			evaluation := RuleEvaluation{Fires: true, SLALabel: ageLabel(rule.AlertType, time.Since(since)), Matches: matches}
+			sendRuleAlert(ctx, db, rule, ticket, slaData[ticket.ID], evaluation, shadowMode, notificationService)
+		}
+	}
+
+	if err := models.PruneAgeAlertCache(ctx, db, time.Now().Add(-ageAlertRetention)); err != nil {
+		log.Println(err)
+	}
+}
+
+// ticketDatesPage is one page of a ticket search sideloading SLA metrics and dates.
+type ticketDatesPage struct {
+	Results []struct {
+		zendesk.Ticket
+		SLAMetrics struct {
+			PolicyMetrics []SLAPolicyMetric `json:"policy_metrics"`
+		} `json:"slas"`
+		Dates TicketDates `json:"dates"`
+	} `json:"results"`
+	Count    int    `json:"count"`
+	NextPage string `json:"next_page"`
+}
+
+// SearchTicketsWithDates runs a ticket search, sideloading each ticket's SLA
+// metrics and metric dates. Searches matching more tickets than the search
+// API returns are cut off at its limit.
+func (zc *ZendeskClient) SearchTicketsWithDates(query string) ([]zendesk.Ticket, map[int64]SLAInfo, map[int64]TicketDates, error) {
+	var allTickets []zendesk.Ticket
+	slaData := make(map[int64]SLAInfo)
+	dates := make(map[int64]TicketDates)
+
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("include", "tickets(slas,dates)")
+
+	endpoint := fmt.Sprintf("https://%s.zendesk.com/api/v2/search.json?%s", zc.Subdomain, params.Encode())
+
+	for endpoint != "" {
+		page, err := zc.searchTicketsWithDatesPage(endpoint)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		for _, ticketResult := range page.Results {
+			allTickets = append(allTickets, ticketResult.Ticket)
+			if len(ticketResult.SLAMetrics.PolicyMetrics) > 0 {
+				slaData[ticketResult.Ticket.ID] = SLAInfo{PolicyMetrics: ticketResult.SLAMetrics.PolicyMetrics}
+			}
+			dates[ticketResult.Ticket.ID] = ticketResult.Dates
+		}
+
+		endpoint = page.NextPage
+		if endpoint != "" && len(allTickets) >= ageSearchLimit {
+			log.Printf("Ticket search %q matched %d tickets, only the first %d were checked", query, page.Count, len(allTickets))
+			break
+		}
+	}
+
+	return allTickets, slaData, dates, nil
+}
+
+// searchTicketsWithDatesPage retrieves one page of a ticket search.
+func (zc *ZendeskClient) searchTicketsWithDatesPage(endpoint string) (*ticketDatesPage, error) {
+	req, err := http.NewRequestWithContext(context.Background(), "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.SetBasicAuth(zc.Email+"/token", zc.APIToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to perform request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to search tickets: received status %s", resp.Status)
+	}
+
+	var page ticketDatesPage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("failed to parse ticket search response: %w", err)
+	}
+	return &page, nil
+}
+
+// LatestPublicAgentReply returns when an agent last added a public comment to
+// the ticket, or the zero time when no agent has.
+func (zc *ZendeskClient) LatestPublicAgentReply(ticketID int64) (time.Time, error) {
+	endpoint := fmt.Sprintf("https://%s.zendesk.com/api/v2/tickets/%d/comments.json?sort_order=desc&include=users", zc.Subdomain, ticketID)
+
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	req.SetBasicAuth(zc.Email+"/token", zc.APIToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return time.Time{}, fmt.Errorf("zendesk returned status %s", resp.Status)
+	}
+
+	var result struct {
+		Comments []zendesk.TicketComment `json:"comments"`
+		Users    []struct {
+			ID   int64  `json:"id"`
+			Role string `json:"role"`
+		} `json:"users"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return time.Time{}, err
+	}
+
+	agents := make(map[int64]bool)
+	for _, user := range result.Users {
+		agents[user.ID] = user.Role == "agent" || user.Role == "admin"
+	}
+	for _, comment := range result.Comments {
+		public := comment.Public == nil || *comment.Public
+		if public && agents[comment.AuthorID] {
+			return comment.CreatedAt, nil
+		}
+	}
+	return time.Time{}, nil
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/nukosuke/go-zendesk/zendesk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAgeCondition(t *testing.T) {
+	created := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	statusChanged := created.Add(2 * time.Hour)
+	requesterUpdated := created.Add(5 * time.Hour)
+	dates := TicketDates{StatusUpdatedAt: &statusChanged, RequesterUpdatedAt: &requesterUpdated}
+
+	tests := []struct {
+		name      string
+		alertType string
+		ticket    zendesk.Ticket
+		dates     TicketDates
+		since     time.Time
+		ok        bool
+	}{
+		{"unassigned", AlertTypeUnassigned, zendesk.Ticket{Status: "new", CreatedAt: &created}, dates, created, true},
+		{"assigned", AlertTypeUnassigned, zendesk.Ticket{Status: "open", AssigneeID: 5, CreatedAt: &created}, dates, created, false},
+		{"waiting since the requester's update", AlertTypeNoAgentReply, zendesk.Ticket{Status: "open", CreatedAt: &created}, dates, requesterUpdated, true},
+		{"waiting since creation", AlertTypeNoAgentReply, zendesk.Ticket{Status: "new", CreatedAt: &created}, TicketDates{}, created, true},
+		{"pending on the requester", AlertTypeNoAgentReply, zendesk.Ticket{Status: "pending", CreatedAt: &created}, dates, requesterUpdated, false},
+		{"pending", AlertTypePendingTooLong, zendesk.Ticket{Status: "pending", CreatedAt: &created}, dates, statusChanged, true},
+		{"not pending", AlertTypePendingTooLong, zendesk.Ticket{Status: "hold", CreatedAt: &created}, dates, statusChanged, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			since, ok := ageCondition(tt.alertType, tt.ticket, tt.dates)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.since, since)
+		})
+	}
+}
+
+func TestAgeRuleQuery(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	query := ageRuleQuery(models.TagAlert{AlertType: AlertTypeUnassigned, ThresholdMinutes: 90, Tag: "billing"}, now)
+	assert.Equal(t, "type:ticket status<pending assignee:none created<2026-10-01T10:30:00Z tags:billing", query)
+
+	query = ageRuleQuery(models.TagAlert{AlertType: AlertTypePendingTooLong, ThresholdMinutes: 60, Organization: "Acme"}, now)
+	assert.Equal(t, `type:ticket status:pending created<2026-10-01T11:00:00Z organization:"Acme"`, query)
+
+	query = ageRuleQuery(models.TagAlert{AlertType: AlertTypeNoAgentReply, ThresholdMinutes: 30, Tag: "bill*"}, now)
+	assert.Equal(t, "type:ticket status<pending created<2026-10-01T11:30:00Z", query, "Expected tag patterns to be matched locally")
+}
+
+func TestAgeRuleFires(t *testing.T) {
+	created := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	ticket := zendesk.Ticket{Status: "new", CreatedAt: &created}
+
+	tests := []struct {
+		name      string
+		threshold int
+		now       time.Time
+		fires     bool
+	}{
+		{"before the threshold", 60, created.Add(59 * time.Minute), false},
+		{"at the threshold", 60, created.Add(time.Hour), true},
+		{"no threshold", 0, created.Add(24 * time.Hour), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := models.TagAlert{AlertType: AlertTypeUnassigned, ThresholdMinutes: tt.threshold}
+			since, fires := ageRuleFires(rule, ticket, TicketDates{}, tt.now)
+			assert.Equal(t, tt.fires, fires)
+			if tt.fires {
+				assert.Equal(t, created, since)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/nukosuke/go-zendesk/zendesk"
+)
+
+// ticketAssignmentRetention is how long TicketPulse remembers assigning a
+// ticket, so later polls do not assign it again.
+const ticketAssignmentRetention = 30 * 24 * time.Hour
+
+// TicketAssigner assigns new, unassigned tickets to the members of a rule's
+// assignment team in turn. Members who are out of office, in quiet hours, or
+// already at the rule's cap of open tickets are skipped. An assigner is meant
+// to be used for a single poll.
+type TicketAssigner struct {
+	db          db.Database
+	zc          *ZendeskClient
+	agents      map[string]int64 // Zendesk user IDs by email
+	openTickets map[int64]int    // Open ticket counts by Zendesk user ID
+}
+
+// NewTicketAssigner creates an assigner. When zc is nil a Zendesk client is
+// created the first time a ticket is assigned.
+func NewTicketAssigner(db db.Database, zc *ZendeskClient) *TicketAssigner {
+	return &TicketAssigner{db: db, zc: zc, agents: make(map[string]int64), openTickets: make(map[int64]int)}
+}
+
+// Assign assigns the ticket to the next available member of the rule's team
+// after whoever the team was last assigned a ticket to. It returns the
+// member's Zendesk user ID, or false when the ticket was not assigned.
+func (a *TicketAssigner) Assign(ctx context.Context, rule models.TagAlert, ticket zendesk.Ticket) (int64, bool) {
+	if !rule.AssignTeamID.Valid || ticket.AssigneeID != 0 || !isNewTicket(ticket) {
+		return 0, false
+	}
+	if assigned, err := models.TicketAssigned(a.db, ticket.ID); err != nil || assigned {
+		if err != nil {
+			log.Println(err)
+		}
+		return 0, false
+	}
+	if a.zc == nil {
+		zc, err := NewZendeskClient(a.db)
+		if err != nil {
+			return 0, false
+		}
+		a.zc = zc
+	}
+
+	members, err := models.GetTeamMembers(a.db, int(rule.AssignTeamID.Int64))
+	if err != nil {
+		log.Printf("Failed to load members of team %d: %v", rule.AssignTeamID.Int64, err)
+		return 0, false
+	}
+	last, err := models.LastTeamAssignee(a.db, rule.AssignTeamID.Int64)
+	if err != nil {
+		log.Println(err)
+	}
+	start := 0
+	for i, member := range members {
+		if member.UserID == last {
+			start = i + 1
+		}
+	}
+
+	for i := range members {
+		member := members[(start+i)%len(members)]
+		assigneeID, ok := a.availableAgent(member, rule.AssignMaxOpen)
+		if !ok {
+			continue
+		}
+		if err := a.zc.AssignTicket(ctx, ticket, assigneeID); err != nil {
+			log.Printf("Failed to assign Ticket #%d to %s: %v", ticket.ID, member.Email, err)
+			return 0, false
+		}
+		a.openTickets[assigneeID]++
+
+		err := models.RecordTicketAssignment(ctx, a.db, models.TicketAssignment{
+			TicketID:   ticket.ID,
+			RuleID:     rule.ID,
+			TeamID:     rule.AssignTeamID.Int64,
+			UserID:     member.UserID,
+			AssigneeID: assigneeID,
+		})
+		if err != nil {
+			log.Println(err)
+		}
+		log.Printf("Assigned Ticket #%d to %s from team %s for rule %d", ticket.ID, member.Email, rule.AssignTeamName, rule.ID)
+		return assigneeID, true
+	}
+
+	log.Printf("Nobody on team %s is available to be assigned Ticket #%d", rule.AssignTeamName, ticket.ID)
+	return 0, false
+}
+
+// availableAgent returns the member's Zendesk user ID when they can take a
+// ticket: they are not out of office or in quiet hours, and have fewer open
+// tickets than the cap.
+func (a *TicketAssigner) availableAgent(member models.TeamMember, maxOpen int) (int64, bool) {
+	now := time.Now()
+	absence, err := models.GetAbsence(a.db, member.UserID)
+	if err != nil {
+		log.Println(err)
+		return 0, false
+	}
+	if absence != nil && absence.Active(now) {
+		return 0, false
+	}
+	quietHours, err := models.GetQuietHours(a.db, member.UserID)
+	if err != nil {
+		log.Println(err)
+		return 0, false
+	}
+	if quietHours.Active(now) {
+		return 0, false
+	}
+
+	assigneeID, ok := a.agents[member.Email]
+	if !ok {
+		agent, err := a.zc.GetUserByEmail(member.Email)
+		if err != nil {
+			log.Printf("Failed to find Zendesk agent %s: %v", member.Email, err)
+		} else if agent.Role == "agent" || agent.Role == "admin" {
+			assigneeID = agent.ID
+		}
+		a.agents[member.Email] = assigneeID
+	}
+	if assigneeID == 0 {
+		return 0, false
+	}
+
+	if maxOpen > 0 {
+		open, ok := a.openTickets[assigneeID]
+		if !ok {
+			open, err = a.zc.CountOpenTickets(assigneeID)
+			if err != nil {
+				log.Printf("Failed to count open tickets for %s: %v", member.Email, err)
+				return 0, false
+			}
+			a.openTickets[assigneeID] = open
+		}
+		if open >= maxOpen {
+			return 0, false
+		}
+	}
+	return assigneeID, true
+}
+
+// pruneTicketAssignments forgets assignments of tickets old enough that they
+// are no longer new.
+func pruneTicketAssignments(ctx context.Context, db db.Database) {
+	if err := models.PruneTicketAssignments(ctx, db, time.Now().Add(-ticketAssignmentRetention)); err != nil {
+		log.Println(err)
+	}
+}
+
+// AssignTicket assigns a ticket to an agent. The update is rejected when the
+// ticket changed since it was polled, so an agent who picked the ticket up in
+// the meantime keeps it.
+func (zc *ZendeskClient) AssignTicket(ctx context.Context, ticket zendesk.Ticket, assigneeID int64) error {
+	_, err := zc.client.UpdateTicket(ctx, ticket.ID, zendesk.Ticket{
+		AssigneeID:   assigneeID,
+		UpdatedStamp: ticket.UpdatedAt,
+		SafeUpdate:   ticket.UpdatedAt != nil,
+	})
+	return err
+}
+
+// CountOpenTickets returns how many unsolved tickets are assigned to an agent.
+func (zc *ZendeskClient) CountOpenTickets(assigneeID int64) (int, error) {
+	params := url.Values{}
+	params.Set("query", fmt.Sprintf("type:ticket assignee_id:%d status<solved", assigneeID))
+	endpoint := fmt.Sprintf("https://%s.zendesk.com/api/v2/search/count.json?%s", zc.Subdomain, params.Encode())
+
+	req, err := http.NewRequestWithContext(context.Background(), "GET", endpoint, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.SetBasicAuth(zc.Email+"/token", zc.APIToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("zendesk returned status %s", resp.Status)
+	}
+
+	var result struct {
+		Count int `json:"count"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+	return result.Count, nil
+}
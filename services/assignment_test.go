@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/nukosuke/go-zendesk/zendesk"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestAssigner sets up a team of Ana (Zendesk user 101), Ben (102) and
+// Cal (103) and an assigner whose Zendesk updates are recorded in assigned.
+func newTestAssigner(t *testing.T, database db.Database, assigned *[]int64) *TicketAssigner {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Ticket zendesk.Ticket `json:"ticket"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		*assigned = append(*assigned, body.Ticket.AssigneeID)
+		assert.NoError(t, json.NewEncoder(w).Encode(body))
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := zendesk.NewClient(nil)
+	assert.NoError(t, err)
+	assert.NoError(t, client.SetEndpointURL(server.URL))
+
+	assert.NoError(t, models.CreateTeam(database, "Support"))
+	assigner := NewTicketAssigner(database, &ZendeskClient{client: client, DB: database})
+	for i, name := range []string{"Ana", "Ben", "Cal"} {
+		email := fmt.Sprintf("%s@example.com", name)
+		assert.NoError(t, models.CreateUser(database, email, name, models.AgentRole, false))
+		assert.NoError(t, models.SetTeamMember(database, 1, i+1, models.TeamMemberRole))
+		assigner.agents[email] = int64(101 + i)
+		assigner.openTickets[int64(101+i)] = 0
+	}
+	return assigner
+}
+
+func TestAssign(t *testing.T) {
+	now := time.Now()
+	quiet := models.QuietHours{
+		Enabled: true,
+		Start:   now.Add(-time.Hour).Format("15:04"),
+		End:     now.Add(time.Hour).Format("15:04"),
+		Days:    "0,1,2,3,4,5,6",
+		Action:  models.QuietHoursHold,
+	}
+
+	tests := []struct {
+		name       string
+		last       int // Team member last assigned a ticket, zero for none
+		absent     int
+		quiet      int
+		open       map[int64]int
+		maxOpen    int
+		assigneeID int64
+		assigned   bool
+	}{
+		{name: "first member without history", assigneeID: 101, assigned: true},
+		{name: "next member after the last assignee", last: 1, assigneeID: 102, assigned: true},
+		{name: "wraps around to the first member", last: 3, assigneeID: 101, assigned: true},
+		{name: "skips absent members", last: 1, absent: 2, assigneeID: 103, assigned: true},
+		{name: "skips members in quiet hours", last: 1, quiet: 2, assigneeID: 103, assigned: true},
+		{name: "skips members at the cap", last: 1, maxOpen: 2, open: map[int64]int{102: 2}, assigneeID: 103, assigned: true},
+		{name: "members under the cap are assigned", last: 1, maxOpen: 2, open: map[int64]int{102: 1}, assigneeID: 102, assigned: true},
+		{name: "no cap", last: 1, open: map[int64]int{102: 50}, assigneeID: 102, assigned: true},
+		{name: "nobody available", maxOpen: 1, open: map[int64]int{101: 1, 102: 1, 103: 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := db.InitDB(":memory:")
+			defer database.Close()
+			var updates []int64
+			assigner := newTestAssigner(t, database, &updates)
+			ctx := context.Background()
+
+			if tt.last != 0 {
+				assert.NoError(t, models.RecordTicketAssignment(ctx, database, models.TicketAssignment{TicketID: 1, RuleID: 1, TeamID: 1, UserID: tt.last, AssigneeID: int64(100 + tt.last)}))
+			}
+			if tt.absent != 0 {
+				assert.NoError(t, models.SetAbsence(database, models.Absence{UserID: tt.absent, AwayFrom: now.Add(-time.Hour), AwayUntil: now.Add(time.Hour)}))
+			}
+			if tt.quiet != 0 {
+				q := quiet
+				q.UserID = tt.quiet
+				assert.NoError(t, models.SaveQuietHours(database, q))
+			}
+			for assigneeID, open := range tt.open {
+				assigner.openTickets[assigneeID] = open
+			}
+
+			rule := models.TagAlert{ID: 1, AssignTeamID: sql.NullInt64{Int64: 1, Valid: true}, AssignTeamName: "Support", AssignMaxOpen: tt.maxOpen}
+			created := time.Now()
+			assigneeID, ok := assigner.Assign(ctx, rule, zendesk.Ticket{ID: 42, CreatedAt: &created})
+			assert.Equal(t, tt.assigned, ok)
+			assert.Equal(t, tt.assigneeID, assigneeID)
+			if tt.assigned {
+				assert.Equal(t, []int64{tt.assigneeID}, updates)
+			} else {
+				assert.Empty(t, updates)
+			}
+		})
+	}
+}
+
+func TestAssign_CountsTicketsAssignedDuringThePoll(t *testing.T) {
+	database := db.InitDB(":memory:")
+	defer database.Close()
+	var updates []int64
+	assigner := newTestAssigner(t, database, &updates)
+	assigner.openTickets[102] = 1
+	assigner.openTickets[103] = 1
+
+	rule := models.TagAlert{ID: 1, AssignTeamID: sql.NullInt64{Int64: 1, Valid: true}, AssignTeamName: "Support", AssignMaxOpen: 1}
+	created := time.Now()
+	ctx := context.Background()
+
+	assigneeID, ok := assigner.Assign(ctx, rule, zendesk.Ticket{ID: 42, CreatedAt: &created})
+	assert.True(t, ok)
+	assert.Equal(t, int64(101), assigneeID)
+
+	// Ana reached the cap with the first ticket, and everyone else already had
+	_, ok = assigner.Assign(ctx, rule, zendesk.Ticket{ID: 43, CreatedAt: &created})
+	assert.False(t, ok, "Expected the ticket assigned earlier in the poll to count towards the cap")
+	assert.Equal(t, []int64{101}, updates)
+
+	// A ticket that was already assigned is not assigned again
+	_, ok = assigner.Assign(ctx, models.TagAlert{ID: 2, AssignTeamID: sql.NullInt64{Int64: 1, Valid: true}}, zendesk.Ticket{ID: 42, CreatedAt: &created})
+	assert.False(t, ok)
+	assert.Equal(t, []int64{101}, updates)
+}
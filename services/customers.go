@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+	"github.com/TylerConlee/TicketPulse/models"
+)
+
+// zendeskRecordTTL is how long cached organization and requester details are
+// trusted before they are fetched from Zendesk again.
+const zendeskRecordTTL = 24 * time.Hour
+
+// Customer is who a ticket came from.
+type Customer struct {
+	OrganizationID   int64
+	OrganizationName string
+	RequesterName    string
+	RequesterEmail   string
+	VIP              bool
+}
+
+// CustomerResolver looks up ticket organizations and requesters, caching them
+// in the database so rules can match on them without calling Zendesk for
+// every ticket. A resolver is meant to be used for a single poll or message.
+type CustomerResolver struct {
+	db        db.Database
+	zc        *ZendeskClient
+	vip       []models.VIPEntry
+	vipLoaded bool
+	customers map[[2]int64]Customer
+}
+
+// NewCustomerResolver creates a resolver. When zc is nil a Zendesk client is
+// created the first time a lookup misses the cache.
+func NewCustomerResolver(db db.Database, zc *ZendeskClient) *CustomerResolver {
+	return &CustomerResolver{db: db, zc: zc, customers: make(map[[2]int64]Customer)}
+}
+
+// Lookup returns the customer for a ticket's organization and requester.
+// Details that cannot be fetched are left empty.
+func (r *CustomerResolver) Lookup(organizationID, requesterID int64) Customer {
+	key := [2]int64{organizationID, requesterID}
+	if customer, ok := r.customers[key]; ok {
+		return customer
+	}
+
+	customer := Customer{OrganizationID: organizationID}
+	if organizationID > 0 {
+		if record := r.record(models.ZendeskOrganizationRecord, organizationID); record != nil {
+			customer.OrganizationName = record.Name
+		}
+	}
+	if requesterID > 0 {
+		if record := r.record(models.ZendeskUserRecord, requesterID); record != nil {
+			customer.RequesterName = record.Name
+			customer.RequesterEmail = record.Email
+		}
+	}
+	customer.VIP = vipMatches(r.vipEntries(), customer)
+
+	r.customers[key] = customer
+	return customer
+}
+
+// UserName returns the name of a Zendesk user, such as a ticket's assignee,
+// or an empty string when it cannot be fetched.
+func (r *CustomerResolver) UserName(userID int64) string {
+	if userID <= 0 {
+		return ""
+	}
+	if record := r.record(models.ZendeskUserRecord, userID); record != nil {
+		return record.Name
+	}
+	return ""
+}
+
+// record returns a cached organization or user, refreshing it from Zendesk
+// when it is missing or stale. A stale copy is used if the refresh fails.
+func (r *CustomerResolver) record(kind string, id int64) *models.ZendeskRecord {
+	cached, err := models.GetZendeskRecord(r.db, kind, id)
+	if err != nil {
+		log.Println("Error reading Zendesk cache:", err)
+	}
+	if cached != nil && time.Since(cached.FetchedAt) < zendeskRecordTTL {
+		return cached
+	}
+
+	if r.zc == nil {
+		zc, err := NewZendeskClient(r.db)
+		if err != nil {
+			return cached
+		}
+		r.zc = zc
+	}
+
+	record := models.ZendeskRecord{Kind: kind, ID: id}
+	switch kind {
+	case models.ZendeskOrganizationRecord:
+		org, err := r.zc.GetOrganizationByID(id)
+		if err != nil {
+			log.Printf("Failed to retrieve organization %d: %v", id, err)
+			return cached
+		}
+		record.Name = org.Name
+	case models.ZendeskUserRecord:
+		user, err := r.zc.GetRequesterByID(id)
+		if err != nil {
+			log.Printf("Failed to retrieve requester %d: %v", id, err)
+			return cached
+		}
+		record.Name = user.Name
+		record.Email = user.Email
+	}
+	if err := models.SaveZendeskRecord(context.Background(), r.db, record); err != nil {
+		log.Println("Error caching Zendesk record:", err)
+	}
+	return &record
+}
+
+func (r *CustomerResolver) vipEntries() []models.VIPEntry {
+	if !r.vipLoaded {
+		entries, err := models.GetVIPEntries(r.db)
+		if err != nil {
+			log.Println("Error loading VIP list:", err)
+		}
+		r.vip = entries
+		r.vipLoaded = true
+	}
+	return r.vip
+}
+
+// vipMatches reports whether the customer is on the VIP list, by organization
+// ID or name or by the requester's email domain or a parent of it.
+func vipMatches(entries []models.VIPEntry, customer Customer) bool {
+	_, domain, _ := strings.Cut(strings.ToLower(customer.RequesterEmail), "@")
+	for _, entry := range entries {
+		switch entry.Kind {
+		case models.VIPOrganization:
+			if organizationMatches(entry.Value, customer) {
+				return true
+			}
+		case models.VIPDomain:
+			if domain != "" && (domain == entry.Value || strings.HasSuffix(domain, "."+entry.Value)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// organizationMatches reports whether the customer's organization is the one
+// named by value, which is either an organization ID or a name.
+func organizationMatches(value string, customer Customer) bool {
+	value = strings.TrimSpace(value)
+	if id, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return id == customer.OrganizationID
+	}
+	return customer.OrganizationName != "" && strings.EqualFold(value, customer.OrganizationName)
+}
+
+// customerMatches reports whether a ticket from the organization and requester
+// passes the rule's organization and VIP filters. Customers are only looked up
+// when the rule needs them.
+func customerMatches(alert models.TagAlert, organizationID, requesterID int64, customers *CustomerResolver) bool {
+	organization := strings.TrimSpace(alert.Organization)
+	if organization != "" {
+		customer := Customer{OrganizationID: organizationID}
+		if _, err := strconv.ParseInt(organization, 10, 64); err != nil {
+			customer = customers.Lookup(organizationID, requesterID)
+		}
+		if !organizationMatches(organization, customer) {
+			return false
+		}
+	}
+	return !alert.VIPOnly || customers.Lookup(organizationID, requesterID).VIP
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/models"
+)
+
+// digestCheckInterval is how often the delivery scheduler checks for digests, rollups, and held alerts that are due.
+const digestCheckInterval = time.Minute
+
+// DigestNotifier is implemented by shared notifiers that can post several
+// tickets as a single digest message.
+type DigestNotifier interface {
+	NotifyDigest(ctx context.Context, destination string, groups []DigestGroup) error
+}
+
+// DigestGroup is the tickets in a digest that matched a single alert type.
+type DigestGroup struct {
+	AlertType string
+	Label     string
+	Entries   []models.DigestEntry
+}
+
+// digestRule reports whether the notification should be queued for a digest
+// rather than sent over the notifier immediately. Only shared channels are
+// batched; personal channels follow the recipient's own preferences.
+func digestRule(notifier Notifier, n Notification) (DigestNotifier, bool) {
+	if n.Rule == nil || n.Rule.DigestInterval <= 0 || n.Ticket == nil || notifier.Personal() {
+		return nil, false
+	}
+	digestNotifier, ok := notifier.(DigestNotifier)
+	return digestNotifier, ok
+}
+
+// enqueueDigest queues the notification's ticket for the next digest to the notifier's destination.
+func (s *NotificationService) enqueueDigest(ctx context.Context, notifier Notifier, n Notification) error {
+	return models.EnqueueDigestEntry(ctx, s.DB, models.DigestEntry{
+		Channel:         notifier.Channel(),
+		Destination:     notifier.Destination(n),
+		IntervalMinutes: n.Rule.DigestInterval,
+		AlertType:       n.AlertType,
+		TicketID:        n.Ticket.ID,
+		Subject:         n.Ticket.Subject,
+		Status:          n.Ticket.Status,
+		Priority:        n.Ticket.Priority,
+		Tag:             n.Rule.Tag,
+		SLALabel:        n.SLALabel,
+	})
+}
+
+// StartDeliveryScheduler periodically sends digests and rate limit rollups whose
+// window has elapsed, and releases alerts held for quiet hours.
+func (s *NotificationService) StartDeliveryScheduler(ctx context.Context) {
+	ticker := time.NewTicker(digestCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.FlushDigests(ctx, false); err != nil {
+				log.Printf("Failed to send digests: %v", err)
+			}
+			if err := s.FlushRollups(ctx); err != nil {
+				log.Printf("Failed to send rate limit rollups: %v", err)
+			}
+			if err := s.ReleaseHeldNotifications(ctx); err != nil {
+				log.Printf("Failed to release notifications held for quiet hours: %v", err)
+			}
+		}
+	}
+}
+
+// FlushDigests sends one digest to every destination with a queued ticket
+// whose window has elapsed, or to every destination when force is set.
+func (s *NotificationService) FlushDigests(ctx context.Context, force bool) error {
+	entries, err := models.GetDigestEntries(s.DB)
+	if err != nil {
+		return err
+	}
+
+	type destinationKey struct{ channel, destination string }
+	var order []destinationKey
+	queued := make(map[destinationKey][]models.DigestEntry)
+	for _, entry := range entries {
+		key := destinationKey{entry.Channel, entry.Destination}
+		if _, ok := queued[key]; !ok {
+			order = append(order, key)
+		}
+		queued[key] = append(queued[key], entry)
+	}
+
+	now := time.Now()
+	shadow := ShadowModeEnabled(s.DB)
+	var errs []error
+	for _, key := range order {
+		destinationEntries := queued[key]
+		due := force
+		var maxID int64
+		for _, entry := range destinationEntries {
+			due = due || entry.Due(now)
+			if entry.ID > maxID {
+				maxID = entry.ID
+			}
+		}
+		if !due {
+			continue
+		}
+
+		if err := s.sendDigest(ctx, key.channel, key.destination, destinationEntries, shadow); err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", key.channel, key.destination, err))
+			continue
+		}
+		if err := models.DeleteDigestEntries(ctx, s.DB, key.channel, key.destination, maxID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *NotificationService) sendDigest(ctx context.Context, channel, destination string, entries []models.DigestEntry, shadow bool) error {
+	if shadow {
+		return models.CreateShadowAlertLog(ctx, s.DB, models.ShadowAlertLog{
+			AlertType:   "digest",
+			Channel:     channel,
+			Destination: destination,
+			Subject:     fmt.Sprintf("Digest of %d tickets", len(entries)),
+		})
+	}
+
+	for _, notifier := range s.registry.Notifiers() {
+		if notifier.Channel() != channel {
+			continue
+		}
+		digestNotifier, ok := notifier.(DigestNotifier)
+		if !ok {
+			return fmt.Errorf("notifier does not support digests")
+		}
+		return digestNotifier.NotifyDigest(ctx, destination, groupDigestEntries(entries))
+	}
+	return fmt.Errorf("no notifier is registered for the channel")
+}
+
+// groupDigestEntries groups entries by alert type, in the order alert types are listed in AlertTypeOptions.
+func groupDigestEntries(entries []models.DigestEntry) []DigestGroup {
+	var groups []DigestGroup
+	index := make(map[string]int)
+	for _, option := range AlertTypeOptions {
+		index[option.Value] = len(groups)
+		groups = append(groups, DigestGroup{AlertType: option.Value, Label: option.Label})
+	}
+	for _, entry := range entries {
+		i, ok := index[entry.AlertType]
+		if !ok {
+			i = len(groups)
+			index[entry.AlertType] = i
+			groups = append(groups, DigestGroup{AlertType: entry.AlertType, Label: entry.AlertType})
+		}
+		groups[i].Entries = append(groups[i].Entries, entry)
+	}
+
+	var nonEmpty []DigestGroup
+	for _, group := range groups {
+		if len(group.Entries) > 0 {
+			nonEmpty = append(nonEmpty, group)
+		}
+	}
+	return nonEmpty
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/TylerConlee/TicketPulse/db"
+	"github.com/nukosuke/go-zendesk/zendesk"
+)
+
+// Duplicate detection settings.
+const (
+	duplicateLookback   = 14 * 24 * time.Hour // Only tickets created this recently are compared
+	duplicateThreshold  = 0.5                 // Minimum similarity for a likely duplicate
+	maxDuplicateTickets = 3
+)
+
+// duplicateStopWords are common words left out when comparing ticket text.
+var duplicateStopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "this": true, "that": true, "from": true,
+	"are": true, "was": true, "but": true, "not": true, "can": true, "you": true, "your": true,
+	"our": true, "have": true, "has": true, "any": true, "please": true, "thanks": true, "thank": true,
+	"hello": true, "there": true, "when": true, "what": true, "how": true, "issue": true, "help": true,
+}
+
+// DuplicateTicket is a recent open ticket that is likely about the same issue.
+type DuplicateTicket struct {
+	ID         int64
+	Subject    string
+	Status     string
+	Similarity float64 // From 0 to 1
+}
+
+// Percent returns the similarity as a whole percentage.
+func (d DuplicateTicket) Percent() int {
+	return int(d.Similarity*100 + 0.5)
+}
+
+// ticketTokens returns the distinct significant words in the text.
+func ticketTokens(text string) map[string]bool {
+	tokens := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(word)) >= 3 && !duplicateStopWords[word] {
+			tokens[word] = true
+		}
+	}
+	return tokens
+}
+
+// jaccard returns the share of words two token sets have in common.
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for token := range a {
+		if b[token] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// ticketSimilarity compares two tickets' subjects and their full text and
+// returns the closer of the two. Subjects of a single word are too vague to
+// compare on their own.
+func ticketSimilarity(a, b zendesk.Ticket) float64 {
+	similarity := jaccard(ticketTokens(a.Subject+" "+a.Description), ticketTokens(b.Subject+" "+b.Description))
+	subjectA, subjectB := ticketTokens(a.Subject), ticketTokens(b.Subject)
+	if len(subjectA) >= 2 && len(subjectB) >= 2 {
+		if subject := jaccard(subjectA, subjectB); subject > similarity {
+			similarity = subject
+		}
+	}
+	return similarity
+}
+
+// findDuplicates returns the candidates most similar to the ticket, most
+// similar first. Solved tickets, old tickets and the ticket itself are skipped.
+func findDuplicates(ticket zendesk.Ticket, candidates []zendesk.Ticket) []DuplicateTicket {
+	cutoff := time.Now().Add(-duplicateLookback)
+	seen := make(map[int64]bool)
+	var duplicates []DuplicateTicket
+	for _, candidate := range candidates {
+		if candidate.ID == ticket.ID || seen[candidate.ID] {
+			continue
+		}
+		seen[candidate.ID] = true
+		if candidate.Status == "solved" || candidate.Status == "closed" || candidate.CreatedAt == nil || candidate.CreatedAt.Before(cutoff) {
+			continue
+		}
+		if similarity := ticketSimilarity(ticket, candidate); similarity >= duplicateThreshold {
+			duplicates = append(duplicates, DuplicateTicket{ID: candidate.ID, Subject: candidate.Subject, Status: candidate.Status, Similarity: similarity})
+		}
+	}
+	sort.SliceStable(duplicates, func(i, j int) bool {
+		return duplicates[i].Similarity > duplicates[j].Similarity
+	})
+	if len(duplicates) > maxDuplicateTickets {
+		duplicates = duplicates[:maxDuplicateTickets]
+	}
+	return duplicates
+}
+
+// DuplicateResolver finds likely duplicates of new tickets among the recent
+// tickets from the same organization or requester. Each ticket is checked at
+// most once, the first time an alert needs it.
+type DuplicateResolver struct {
+	db         db.Database
+	zc         *ZendeskClient
+	duplicates map[int64][]DuplicateTicket
+}
+
+// NewDuplicateResolver creates a duplicate resolver. When zc is nil a Zendesk
+// client is created the first time tickets need to be compared.
+func NewDuplicateResolver(db db.Database, zc *ZendeskClient) *DuplicateResolver {
+	return &DuplicateResolver{db: db, zc: zc, duplicates: make(map[int64][]DuplicateTicket)}
+}
+
+// For returns the likely duplicates of the ticket.
+func (r *DuplicateResolver) For(ticket zendesk.Ticket) []DuplicateTicket {
+	if duplicates, ok := r.duplicates[ticket.ID]; ok {
+		return duplicates
+	}
+	if r.zc == nil {
+		zc, err := NewZendeskClient(r.db)
+		if err != nil {
+			return nil
+		}
+		r.zc = zc
+	}
+
+	var candidates []zendesk.Ticket
+	if ticket.OrganizationID != 0 {
+		tickets, err := r.zc.listRecentTickets(fmt.Sprintf("organizations/%d/tickets.json", ticket.OrganizationID))
+		if err != nil {
+			log.Printf("Failed to retrieve organization tickets for Ticket #%d: %v", ticket.ID, err)
+		}
+		candidates = append(candidates, tickets...)
+	}
+	if ticket.RequesterID != 0 {
+		tickets, err := r.zc.listRecentTickets(fmt.Sprintf("users/%d/tickets/requested.json", ticket.RequesterID))
+		if err != nil {
+			log.Printf("Failed to retrieve requester tickets for Ticket #%d: %v", ticket.ID, err)
+		}
+		candidates = append(candidates, tickets...)
+	}
+
+	duplicates := findDuplicates(ticket, candidates)
+	r.duplicates[ticket.ID] = duplicates
+	return duplicates
+}
+
+// listRecentTickets retrieves the first page of a ticket list endpoint, newest first.
+func (zc *ZendeskClient) listRecentTickets(path string) ([]zendesk.Ticket, error) {
+	url := fmt.Sprintf("https://%s.zendesk.com/api/v2/%s?sort_by=created_at&sort_order=desc&per_page=100", zc.Subdomain, path)
+	req, err := http.NewRequestWithContext(context.Background(), "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(zc.Email+"/token", zc.APIToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("zendesk returned status %s", resp.Status)
+	}
+
+	var result struct {
+		Tickets []zendesk.Ticket `json:"tickets"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.Tickets, nil
+}
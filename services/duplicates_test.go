@@ -0,0 +1,65 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nukosuke/go-zendesk/zendesk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJaccard(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want float64
+	}{
+		{"identical", "checkout page timeout", "Checkout page timeout!", 1},
+		{"half shared", "checkout page timeout", "checkout page error", 0.5},
+		{"nothing shared", "checkout page timeout", "invoice missing", 0},
+		{"stop words ignored", "the checkout is down", "checkout down please", 1},
+		{"empty", "", "checkout", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, jaccard(ticketTokens(tt.a), ticketTokens(tt.b)), 0.001)
+		})
+	}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	recent := time.Now().Add(-time.Hour)
+	old := time.Now().Add(-duplicateLookback - time.Hour)
+	ticket := zendesk.Ticket{ID: 1, Subject: "Checkout page timeout", Description: "Customers see a timeout on the checkout page"}
+	candidate := func(id int64, subject, status string, created *time.Time) zendesk.Ticket {
+		return zendesk.Ticket{ID: id, Subject: subject, Status: status, CreatedAt: created}
+	}
+
+	duplicates := findDuplicates(ticket, []zendesk.Ticket{
+		ticket,
+		candidate(2, "Checkout page error", "open", &recent),
+		candidate(3, "Checkout page timeout", "new", &recent),
+		candidate(3, "Checkout page timeout", "new", &recent),
+		candidate(4, "Checkout page timeout", "solved", &recent),
+		candidate(5, "Checkout page timeout", "open", &old),
+		candidate(6, "Invoice missing", "open", &recent),
+		candidate(7, "Checkout page timeout", "open", nil),
+	})
+
+	var ids []int64
+	for _, duplicate := range duplicates {
+		ids = append(ids, duplicate.ID)
+	}
+	assert.Equal(t, []int64{3, 2}, ids, "Expected open recent duplicates, most similar first")
+	assert.Equal(t, 100, duplicates[0].Percent())
+}
+
+func TestFindDuplicates_LimitsResults(t *testing.T) {
+	recent := time.Now().Add(-time.Hour)
+	ticket := zendesk.Ticket{ID: 1, Subject: "Checkout page timeout"}
+	var candidates []zendesk.Ticket
+	for id := int64(2); id < 2+maxDuplicateTickets+2; id++ {
+		candidates = append(candidates, zendesk.Ticket{ID: id, Subject: "Checkout page timeout", Status: "open", CreatedAt: &recent})
+	}
+	assert.Len(t, findDuplicates(ticket, candidates), maxDuplicateTickets)
+}
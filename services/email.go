@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+
+	"github.com/TylerConlee/TicketPulse/db"
+	"github.com/TylerConlee/TicketPulse/models"
+)
+
+// EmailNotifier delivers notifications to the user's registered email address over SMTP.
+type EmailNotifier struct {
+	DB db.Database
+}
+
+func NewEmailNotifier(db db.Database) *EmailNotifier {
+	return &EmailNotifier{DB: db}
+}
+
+func (e *EmailNotifier) Channel() string { return ChannelEmail }
+
+func (e *EmailNotifier) Label() string { return "Email" }
+
+func (e *EmailNotifier) Personal() bool { return true }
+
+func (e *EmailNotifier) Destination(n Notification) string { return n.Recipient.Email }
+
+func (e *EmailNotifier) Supports(alertType string) bool { return true }
+
+func (e *EmailNotifier) Notify(ctx context.Context, n Notification) error {
+	if n.Recipient.Email == "" {
+		return fmt.Errorf("user %d has no email address", n.Recipient.ID)
+	}
+
+	configs, err := models.GetAllConfigurations(e.DB)
+	if err != nil {
+		return fmt.Errorf("failed to read SMTP configuration: %w", err)
+	}
+	host := configs["smtp_host"]
+	port := configs["smtp_port"]
+	from := configs["smtp_from"]
+	if host == "" || from == "" {
+		return fmt.Errorf("SMTP is not configured")
+	}
+	if port == "" {
+		port = "587"
+	}
+
+	var auth smtp.Auth
+	if configs["smtp_username"] != "" {
+		auth = smtp.PlainAuth("", configs["smtp_username"], configs["smtp_password"], host)
+	}
+
+	return smtp.SendMail(net.JoinHostPort(host, port), auth, from, []string{n.Recipient.Email}, buildEmail(from, n.Recipient.Email, notificationSubject(n), notificationBody(e.DB, n)))
+}
+
+// buildEmail assembles a plain text RFC 5322 message.
+func buildEmail(from, to, subject, body string) []byte {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("From: %s\r\n", from))
+	sb.WriteString(fmt.Sprintf("To: %s\r\n", to))
+	sb.WriteString(fmt.Sprintf("Subject: %s\r\n", strings.ReplaceAll(subject, "\n", " ")))
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n\r\n")
+	sb.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return []byte(sb.String())
+}
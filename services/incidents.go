@@ -0,0 +1,490 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/TylerConlee/TicketPulse/db"
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/nukosuke/go-zendesk/zendesk"
+)
+
+// AlertTypeIncident is the alert type used when shadow mode logs incident
+// thread messages instead of posting them.
+const AlertTypeIncident = "incident"
+
+// maxIncidentReplyTickets is how many newly grouped tickets an incident
+// thread reply lists before summarizing the rest.
+const maxIncidentReplyTickets = 10
+
+// IncidentService groups tickets into declared incidents and keeps each
+// subscribed Slack channel's incident thread up to date.
+type IncidentService struct {
+	DB    db.Database
+	slack *SlackService
+}
+
+func NewIncidentService(db db.Database, slackService *SlackService) *IncidentService {
+	return &IncidentService{DB: db, slack: slackService}
+}
+
+// IncidentSummary is an incident with the tickets grouped into it so far.
+type IncidentSummary struct {
+	models.Incident
+	Tickets       []models.IncidentTicket
+	Organizations []string // Names of the organizations with grouped tickets
+}
+
+// Summary returns the incident with its grouped tickets, or nil when it does not exist.
+func (s *IncidentService) Summary(incidentID int64) (*IncidentSummary, error) {
+	incident, err := models.GetIncident(s.DB, incidentID)
+	if err != nil || incident == nil {
+		return nil, err
+	}
+	tickets, err := models.GetIncidentTickets(s.DB, incidentID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &IncidentSummary{Incident: *incident, Tickets: tickets}
+	seen := make(map[string]bool)
+	for _, ticket := range tickets {
+		if ticket.OrganizationName != "" && !seen[ticket.OrganizationName] {
+			seen[ticket.OrganizationName] = true
+			summary.Organizations = append(summary.Organizations, ticket.OrganizationName)
+		}
+	}
+	sort.Strings(summary.Organizations)
+	return summary, nil
+}
+
+// Declare creates an incident and starts its thread in each channel.
+func (s *IncidentService) Declare(ctx context.Context, incident models.Incident, channelIDs []string) (int64, error) {
+	incidentID, err := models.CreateIncident(ctx, s.DB, incident)
+	if err != nil {
+		return 0, err
+	}
+	log.Printf("Incident %d (%s) declared by %s, grouping tickets matching %s", incidentID, incident.Name, incident.DeclaredBy, incident.Criteria())
+
+	var errs []error
+	for _, channelID := range channelIDs {
+		if err := s.Subscribe(ctx, incidentID, channelID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return incidentID, errors.Join(errs...)
+}
+
+// Subscribe starts the incident's thread in a channel. Channels that already
+// have the thread are left alone.
+func (s *IncidentService) Subscribe(ctx context.Context, incidentID int64, channelID string) error {
+	channels, err := models.GetIncidentChannels(s.DB, incidentID)
+	if err != nil {
+		return err
+	}
+	for _, channel := range channels {
+		if channel.ChannelID == channelID && channel.ThreadTS != "" {
+			return nil
+		}
+	}
+
+	summary, err := s.Summary(incidentID)
+	if err != nil {
+		return err
+	}
+	if summary == nil {
+		return fmt.Errorf("incident %d does not exist", incidentID)
+	}
+	// In shadow mode the channel is subscribed without a thread, so the
+	// thread is started for real once shadow mode is turned off
+	if ShadowModeEnabled(s.DB) {
+		if err := s.recordShadow(ctx, *summary, channelID, "Incident declared: "+summary.Name); err != nil {
+			return err
+		}
+		return models.SaveIncidentChannel(ctx, s.DB, models.IncidentChannel{IncidentID: incidentID, ChannelID: channelID})
+	}
+	if s.slack == nil {
+		return errors.New("slack is not configured")
+	}
+	threadTS, err := s.slack.PostIncident(channelID, *summary)
+	if err != nil {
+		return err
+	}
+	return models.SaveIncidentChannel(ctx, s.DB, models.IncidentChannel{IncidentID: incidentID, ChannelID: channelID, ThreadTS: threadTS})
+}
+
+// PostUpdate records a status update and broadcasts it to every channel
+// subscribed to the incident.
+func (s *IncidentService) PostUpdate(ctx context.Context, incidentID int64, message, postedBy string) error {
+	err := models.AddIncidentUpdate(ctx, s.DB, models.IncidentUpdate{IncidentID: incidentID, Message: message, PostedBy: postedBy})
+	if err != nil {
+		return err
+	}
+
+	text := fmt.Sprintf("*Status update* from %s:\n%s", postedBy, message)
+	return s.reply(ctx, incidentID, text, true)
+}
+
+// Resolve ends the incident so matching tickets alert individually again,
+// and tells the subscribed channels.
+func (s *IncidentService) Resolve(ctx context.Context, incidentID int64, message, postedBy string) error {
+	if err := models.ResolveIncident(ctx, s.DB, incidentID); err != nil {
+		return err
+	}
+	log.Printf("Incident %d resolved by %s", incidentID, postedBy)
+
+	if message == "" {
+		message = "The incident has been resolved."
+	}
+	var errs []error
+	if err := s.PostUpdate(ctx, incidentID, message, postedBy); err != nil {
+		errs = append(errs, err)
+	}
+	if err := s.refresh(ctx, incidentID); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// GroupTickets adds tickets matching an active incident to it, replying in
+// the incident's threads with the newly grouped tickets. It returns the IDs of
+// every ticket that belongs to an active incident, so their individual alerts
+// can be skipped.
+func (s *IncidentService) GroupTickets(ctx context.Context, tickets []zendesk.Ticket, customers *CustomerResolver) map[int64]bool {
+	grouped := make(map[int64]bool)
+	incidents, err := models.GetUnresolvedIncidents(s.DB)
+	if err != nil {
+		log.Println("Error fetching incidents:", err)
+		return grouped
+	}
+	var active []models.Incident
+	for _, incident := range incidents {
+		if incident.Active(time.Now()) {
+			active = append(active, incident)
+		}
+	}
+	if len(active) == 0 {
+		return grouped
+	}
+
+	added := make(map[int64][]models.IncidentTicket)
+	for _, ticket := range tickets {
+		incident, ok := matchingIncident(active, ticket)
+		if !ok {
+			continue
+		}
+		grouped[ticket.ID] = true
+
+		customer := customers.Lookup(ticket.OrganizationID, ticket.RequesterID)
+		incidentTicket := models.IncidentTicket{
+			IncidentID:       incident.ID,
+			TicketID:         ticket.ID,
+			Subject:          ticket.Subject,
+			OrganizationID:   ticket.OrganizationID,
+			OrganizationName: customer.OrganizationName,
+		}
+		isNew, err := models.AddIncidentTicket(ctx, s.DB, incidentTicket)
+		if err != nil {
+			log.Printf("Failed to group Ticket #%d into incident %d: %v", ticket.ID, incident.ID, err)
+			continue
+		}
+		if isNew {
+			log.Printf("Ticket #%d grouped into incident %d (%s)", ticket.ID, incident.ID, incident.Name)
+			added[incident.ID] = append(added[incident.ID], incidentTicket)
+		}
+	}
+
+	for incidentID, incidentTickets := range added {
+		if err := s.reply(ctx, incidentID, incidentTicketsText(s.DB, incidentTickets), false); err != nil {
+			log.Printf("Failed to post new tickets to incident %d: %v", incidentID, err)
+		}
+		if err := s.refresh(ctx, incidentID); err != nil {
+			log.Printf("Failed to update incident %d: %v", incidentID, err)
+		}
+	}
+	return grouped
+}
+
+// matchingIncident returns the first active incident whose tag or keyword
+// matches the ticket. Only tickets updated since the incident started are
+// grouped, so older tickets that happen to match keep alerting.
+func matchingIncident(incidents []models.Incident, ticket zendesk.Ticket) (models.Incident, bool) {
+	for _, incident := range incidents {
+		if ticket.UpdatedAt == nil || ticket.UpdatedAt.Before(incident.StartedAt) {
+			continue
+		}
+		if incident.Tag != "" {
+			if _, ok := matchingTag(incident.Tag, ticket.Tags); ok {
+				return incident, true
+			}
+		}
+		if incident.Keyword != "" {
+			if _, ok := keywordMatches(models.TagAlert{Keywords: incident.Keyword}, ticket, nil); ok {
+				return incident, true
+			}
+		}
+	}
+	return models.Incident{}, false
+}
+
+// incidentTicketsText lists tickets newly grouped into an incident.
+func incidentTicketsText(db db.Database, tickets []models.IncidentTicket) string {
+	var sb strings.Builder
+	if len(tickets) == 1 {
+		sb.WriteString("1 new ticket:\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("%d new tickets:\n", len(tickets)))
+	}
+	for i, ticket := range tickets {
+		if i == maxIncidentReplyTickets {
+			sb.WriteString(fmt.Sprintf("…and %d more\n", len(tickets)-i))
+			break
+		}
+		line := fmt.Sprintf("• %s %s", slackTicketLink(db, ticket.TicketID), ticket.Subject)
+		if ticket.OrganizationName != "" {
+			line += fmt.Sprintf(" _(%s)_", ticket.OrganizationName)
+		}
+		sb.WriteString(line + "\n")
+	}
+	return sb.String()
+}
+
+// reply posts to the incident's thread in every subscribed channel, or logs
+// the reply in shadow mode.
+func (s *IncidentService) reply(ctx context.Context, incidentID int64, text string, broadcast bool) error {
+	channels, err := models.GetIncidentChannels(s.DB, incidentID)
+	if err != nil || len(channels) == 0 {
+		return err
+	}
+	if ShadowModeEnabled(s.DB) {
+		return s.recordShadowAll(ctx, incidentID, channels, text)
+	}
+	if s.slack == nil {
+		return nil
+	}
+
+	var errs []error
+	for _, channel := range channels {
+		if channel.ThreadTS == "" {
+			continue
+		}
+		if err := s.slack.PostIncidentReply(channel.ChannelID, channel.ThreadTS, text, broadcast); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", channel.ChannelID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// refresh updates the message at the top of the incident's thread in every
+// subscribed channel. Shadow mode logs the replies that prompt a refresh, so
+// refreshes themselves are not logged.
+func (s *IncidentService) refresh(ctx context.Context, incidentID int64) error {
+	if ShadowModeEnabled(s.DB) {
+		return nil
+	}
+	channels, err := models.GetIncidentChannels(s.DB, incidentID)
+	if err != nil || len(channels) == 0 || s.slack == nil {
+		return err
+	}
+	summary, err := s.Summary(incidentID)
+	if err != nil || summary == nil {
+		return err
+	}
+
+	var errs []error
+	for _, channel := range channels {
+		if channel.ThreadTS == "" {
+			continue
+		}
+		if err := s.slack.UpdateIncident(channel.ChannelID, channel.ThreadTS, *summary); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", channel.ChannelID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// recordShadowAll logs a message that would have been posted to the
+// incident's thread in each channel.
+func (s *IncidentService) recordShadowAll(ctx context.Context, incidentID int64, channels []models.IncidentChannel, text string) error {
+	summary, err := s.Summary(incidentID)
+	if err != nil || summary == nil {
+		return err
+	}
+	var errs []error
+	for _, channel := range channels {
+		if err := s.recordShadow(ctx, *summary, channel.ChannelID, text); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// recordShadow logs an incident message that would have been posted to a
+// channel while shadow mode is enabled.
+func (s *IncidentService) recordShadow(ctx context.Context, summary IncidentSummary, channelID, text string) error {
+	return models.CreateShadowAlertLog(ctx, s.DB, models.ShadowAlertLog{
+		AlertType:   AlertTypeIncident,
+		Channel:     ChannelSlackChannel,
+		Destination: channelID,
+		Tag:         summary.Tag,
+		Subject:     fmt.Sprintf("%s: %s", summary.Name, text),
+	})
+}
+
+const incidentUsage = "Usage: `/pulse incidents`, `/pulse incident start tag:<tag> <name>`, `/pulse incident start keyword:\"<phrase>\" <name>`, " +
+	"`/pulse incident update <ID> <message>`, `/pulse incident resolve <ID> [message]` or `/pulse incident subscribe <ID>`"
+
+// incidentCommand runs a /pulse incident command. Anyone can list incidents or
+// subscribe a channel to one; only admins can start, update or resolve them.
+// Incidents started from Slack begin now and post their thread to the channel
+// the command was run in.
+func incidentCommand(ctx context.Context, incidents *IncidentService, user models.User, channelID, text string) string {
+	fields := strings.Fields(text)
+	if strings.EqualFold(fields[0], "incidents") || len(fields) == 1 || strings.EqualFold(fields[1], "list") {
+		return incidentList(incidents.DB)
+	}
+
+	action := strings.ToLower(fields[1])
+	if action != "subscribe" && user.Role != models.AdminRole {
+		return "Only TicketPulse admins can " + action + " incidents."
+	}
+	if action == "start" {
+		incident, err := parseIncident(restOfCommand(text, 2))
+		if err != nil {
+			return "Couldn't start the incident: " + err.Error() + ".\n" + incidentUsage
+		}
+		incident.DeclaredBy = user.Email
+		incidentID, err := incidents.Declare(ctx, incident, []string{channelID})
+		if incidentID == 0 {
+			log.Println("Error declaring incident:", err)
+			return "Something went wrong declaring the incident."
+		}
+		if err != nil {
+			log.Printf("Incident %d declared, but its thread could not be posted: %v", incidentID, err)
+			return fmt.Sprintf("Incident %d declared, but its thread couldn't be posted here. Is TicketPulse in this channel?", incidentID)
+		}
+		return fmt.Sprintf("Incident %d declared. Tickets matching %s are grouped in its thread until it's resolved.", incidentID, incident.Criteria())
+	}
+
+	if len(fields) < 3 {
+		return incidentUsage
+	}
+	incidentID, err := strconv.ParseInt(strings.TrimPrefix(fields[2], "#"), 10, 64)
+	if err != nil || incidentID <= 0 {
+		return fmt.Sprintf("%q isn't an incident ID.", fields[2])
+	}
+	incident, err := models.GetIncident(incidents.DB, incidentID)
+	if err != nil {
+		log.Println("Error fetching incident:", err)
+		return "Something went wrong fetching the incident."
+	}
+	if incident == nil {
+		return fmt.Sprintf("There's no incident %d.", incidentID)
+	}
+
+	message := restOfCommand(text, 3)
+	switch action {
+	case "update":
+		if message == "" {
+			return incidentUsage
+		}
+		if err := incidents.PostUpdate(ctx, incidentID, message, user.Email); err != nil {
+			log.Printf("Error posting update to incident %d: %v", incidentID, err)
+			return "Something went wrong posting the update."
+		}
+		return fmt.Sprintf("Update posted to incident %d.", incidentID)
+	case "resolve":
+		if incident.ResolvedAt.Valid {
+			return fmt.Sprintf("Incident %d is already resolved.", incidentID)
+		}
+		if err := incidents.Resolve(ctx, incidentID, message, user.Email); err != nil {
+			log.Printf("Error resolving incident %d: %v", incidentID, err)
+			return "The incident was resolved, but not every channel could be told."
+		}
+		return fmt.Sprintf("Incident %d resolved.", incidentID)
+	case "subscribe":
+		if err := incidents.Subscribe(ctx, incidentID, channelID); err != nil {
+			log.Printf("Error subscribing channel %s to incident %d: %v", channelID, incidentID, err)
+			return "Something went wrong posting the incident here. Is TicketPulse in this channel?"
+		}
+		return fmt.Sprintf("This channel now follows incident %d.", incidentID)
+	}
+	return incidentUsage
+}
+
+// incidentList lists the incidents that have not been resolved.
+func incidentList(db db.Database) string {
+	incidents, err := models.GetUnresolvedIncidents(db)
+	if err != nil {
+		log.Println("Error fetching incidents:", err)
+		return "Something went wrong fetching incidents."
+	}
+	if len(incidents) == 0 {
+		return "There are no open incidents."
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Open incidents:\n")
+	for _, incident := range incidents {
+		tickets, err := models.GetIncidentTickets(db, incident.ID)
+		if err != nil {
+			log.Println("Error fetching incident tickets:", err)
+		}
+		sb.WriteString(fmt.Sprintf("• %d: *%s* matching %s, %d tickets since %s\n", incident.ID, incident.Name, incident.Criteria(), len(tickets), incident.StartedAt.Local().Format("Jan 2 15:04")))
+	}
+	return sb.String()
+}
+
+// parseIncident reads an incident's matcher and name from the text after
+// "start". Keywords with spaces are quoted.
+func parseIncident(text string) (models.Incident, error) {
+	incident := models.Incident{StartedAt: time.Now()}
+	matcher, rest, _ := strings.Cut(text, ":")
+	switch strings.ToLower(matcher) {
+	case "tag":
+		incident.Tag, rest, _ = strings.Cut(rest, " ")
+		if err := ValidateTagPattern(incident.Tag); err != nil {
+			return incident, err
+		}
+	case "keyword":
+		if quoted, ok := strings.CutPrefix(rest, `"`); ok {
+			incident.Keyword, rest, ok = strings.Cut(quoted, `"`)
+			if !ok {
+				return incident, errors.New("the keyword is missing its closing quote")
+			}
+		} else {
+			incident.Keyword, rest, _ = strings.Cut(rest, " ")
+		}
+	default:
+		return incident, errors.New("say which tickets to group with `tag:` or `keyword:`")
+	}
+
+	incident.Name = strings.TrimSpace(rest)
+	if incident.Tag == "" && incident.Keyword == "" {
+		return incident, errors.New("the tag or keyword is empty")
+	}
+	if incident.Name == "" {
+		return incident, errors.New("the incident needs a name")
+	}
+	return incident, nil
+}
+
+// restOfCommand returns the command text after its first n words.
+func restOfCommand(text string, n int) string {
+	rest := strings.TrimSpace(text)
+	for i := 0; i < n && rest != ""; i++ {
+		if end := strings.IndexFunc(rest, unicode.IsSpace); end >= 0 {
+			rest = strings.TrimSpace(rest[end:])
+		} else {
+			rest = ""
+		}
+	}
+	return rest
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/nukosuke/go-zendesk/zendesk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupTickets_ShadowModeLogsInsteadOfPosting(t *testing.T) {
+	database := db.InitDB(":memory:")
+	defer database.Close()
+	ctx := context.Background()
+	assert.NoError(t, models.SetConfiguration(database, "shadow_mode", "on"))
+
+	// Without a Slack service, anything not logged would have to be posted
+	incidents := NewIncidentService(database, nil)
+	incidentID, err := incidents.Declare(ctx, models.Incident{Name: "Checkout outage", Tag: "checkout", StartedAt: time.Now().Add(-time.Hour)}, []string{"C1", "C2"})
+	assert.NoError(t, err)
+
+	updatedAt := time.Now()
+	tickets := []zendesk.Ticket{
+		{ID: 1, Subject: "Can't pay", Tags: []string{"checkout"}, UpdatedAt: &updatedAt},
+		{ID: 2, Subject: "Password reset", Tags: []string{"login"}, UpdatedAt: &updatedAt},
+	}
+	grouped := incidents.GroupTickets(ctx, tickets, NewCustomerResolver(database, nil))
+	assert.Equal(t, map[int64]bool{1: true}, grouped)
+
+	logs, err := models.GetRecentShadowAlertLogs(database, 10)
+	assert.NoError(t, err)
+	assert.Len(t, logs, 4, "Expected the declaration and the new ticket to be logged for both channels")
+	for _, entry := range logs {
+		assert.Equal(t, AlertTypeIncident, entry.AlertType)
+	}
+
+	channels, err := models.GetIncidentChannels(database, incidentID)
+	assert.NoError(t, err)
+	assert.Len(t, channels, 2)
+	for _, channel := range channels {
+		assert.Empty(t, channel.ThreadTS, "Expected no thread to be started in shadow mode")
+	}
+}
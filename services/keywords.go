@@ -0,0 +1,242 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/TylerConlee/TicketPulse/db"
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/nukosuke/go-zendesk/zendesk"
+)
+
+// Keyword match limits, so a long ticket cannot flood an alert.
+const (
+	maxKeywordMatches  = 5
+	keywordSnippetSize = 60 // Characters of context on each side of a match
+)
+
+// KeywordMatch is a phrase a keyword rule found in a ticket, with the text around it.
+type KeywordMatch struct {
+	Before string
+	Phrase string
+	After  string
+}
+
+// Snippet returns the phrase in its surrounding text.
+func (m KeywordMatch) Snippet() string {
+	return m.Before + m.Phrase + m.After
+}
+
+// keywordPatterns caches compiled keyword patterns by their source.
+var keywordPatterns sync.Map
+
+// KeywordPattern compiles a rule's keywords into a single pattern. Keywords
+// are matched literally unless the rule treats them as regular expressions.
+// It returns nil when the rule has no keywords.
+func KeywordPattern(alert models.TagAlert) (*regexp.Regexp, error) {
+	keywords := alert.KeywordList()
+	if len(keywords) == 0 {
+		return nil, nil
+	}
+
+	alternatives := make([]string, len(keywords))
+	for i, keyword := range keywords {
+		if !alert.KeywordRegex {
+			keyword = regexp.QuoteMeta(keyword)
+		}
+		alternatives[i] = "(?:" + keyword + ")"
+	}
+	source := strings.Join(alternatives, "|")
+	if alert.KeywordWholeWord {
+		source = `\b(?:` + source + `)\b`
+	}
+	if !alert.KeywordCaseSensitive {
+		source = "(?i)" + source
+	}
+
+	if pattern, ok := keywordPatterns.Load(source); ok {
+		return pattern.(*regexp.Regexp), nil
+	}
+	pattern, err := regexp.Compile(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid keyword pattern: %w", err)
+	}
+	keywordPatterns.Store(source, pattern)
+	return pattern, nil
+}
+
+// keywordMatches reports whether the ticket's subject, description, or new
+// public comments match the rule's keywords, and what they matched. Rules
+// without keywords match every ticket. Comments are only searched when a
+// resolver is given.
+func keywordMatches(alert models.TagAlert, ticket zendesk.Ticket, comments *CommentResolver) ([]KeywordMatch, bool) {
+	pattern, err := KeywordPattern(alert)
+	if err != nil {
+		log.Printf("Skipping keywords for rule %d: %v", alert.ID, err)
+		return nil, false
+	}
+	if pattern == nil {
+		return nil, true
+	}
+
+	texts := []string{ticket.Subject, ticket.Description}
+	if comments != nil {
+		texts = append(texts, comments.NewPublicComments(ticket)...)
+	}
+
+	var matches []KeywordMatch
+	seen := make(map[string]bool)
+	for _, text := range texts {
+		for _, loc := range pattern.FindAllStringIndex(text, -1) {
+			phrase := text[loc[0]:loc[1]]
+			if seen[strings.ToLower(phrase)] {
+				continue
+			}
+			seen[strings.ToLower(phrase)] = true
+			before, after := keywordContext(text, loc[0], loc[1])
+			matches = append(matches, KeywordMatch{Before: before, Phrase: phrase, After: after})
+			if len(matches) == maxKeywordMatches {
+				return matches, true
+			}
+		}
+	}
+	return matches, len(matches) > 0
+}
+
+// keywordContext returns the text before and after a match on a single line,
+// trimmed to whole words where possible.
+func keywordContext(text string, start, end int) (string, string) {
+	from, to := start-keywordSnippetSize, end+keywordSnippetSize
+	prefix, suffix := "…", "…"
+	if from <= 0 {
+		from, prefix = 0, ""
+	} else if i := strings.IndexAny(text[from:start], " \n"); i >= 0 {
+		from += i + 1
+	}
+	if to >= len(text) {
+		to, suffix = len(text), ""
+	} else if i := strings.LastIndexAny(text[end:to], " \n"); i >= 0 {
+		to = end + i
+	}
+	for from > 0 && !utf8.RuneStart(text[from]) {
+		from--
+	}
+	for to < len(text) && !utf8.RuneStart(text[to]) {
+		to++
+	}
+	return prefix + singleLine(text[from:start]), singleLine(text[end:to]) + suffix
+}
+
+// singleLine collapses runs of whitespace, including newlines, into single
+// spaces while keeping a space at either end.
+func singleLine(text string) string {
+	collapsed := strings.Join(strings.Fields(text), " ")
+	if collapsed == "" {
+		if text != "" {
+			return " "
+		}
+		return ""
+	}
+	if strings.TrimLeftFunc(text, unicode.IsSpace) != text {
+		collapsed = " " + collapsed
+	}
+	if strings.TrimRightFunc(text, unicode.IsSpace) != text {
+		collapsed += " "
+	}
+	return collapsed
+}
+
+// CommentResolver fetches the public comments added to tickets since the
+// previous poll, so keyword rules can match them. Each ticket's comments are
+// fetched at most once, the first time a keyword rule needs them.
+type CommentResolver struct {
+	db       db.Database
+	zc       *ZendeskClient
+	since    time.Time
+	comments map[int64][]string
+}
+
+// NewCommentResolver creates a resolver for comments added after since. When
+// zc is nil a Zendesk client is created the first time comments are needed.
+func NewCommentResolver(db db.Database, zc *ZendeskClient, since time.Time) *CommentResolver {
+	return &CommentResolver{db: db, zc: zc, since: since, comments: make(map[int64][]string)}
+}
+
+// NewPublicComments returns the bodies of the ticket's public comments added
+// since the previous poll.
+func (r *CommentResolver) NewPublicComments(ticket zendesk.Ticket) []string {
+	if ticket.UpdatedAt == nil || ticket.UpdatedAt.Before(r.since) {
+		return nil
+	}
+	if comments, ok := r.comments[ticket.ID]; ok {
+		return comments
+	}
+
+	if r.zc == nil {
+		zc, err := NewZendeskClient(r.db)
+		if err != nil {
+			return nil
+		}
+		r.zc = zc
+	}
+	comments, err := r.zc.GetPublicCommentsSince(ticket.ID, r.since)
+	if err != nil {
+		log.Printf("Failed to retrieve comments for Ticket #%d: %v", ticket.ID, err)
+	}
+	r.comments[ticket.ID] = comments
+	return comments
+}
+
+// GetPublicCommentsSince retrieves the bodies of a ticket's public comments
+// created after the given time, newest first.
+func (zc *ZendeskClient) GetPublicCommentsSince(ticketID int64, since time.Time) ([]string, error) {
+	url := fmt.Sprintf("https://%s.zendesk.com/api/v2/tickets/%d/comments.json?sort_order=desc", zc.Subdomain, ticketID)
+	req, err := http.NewRequestWithContext(context.Background(), "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(zc.Email+"/token", zc.APIToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("zendesk returned status %s", resp.Status)
+	}
+
+	var result struct {
+		Comments []struct {
+			Body      string    `json:"body"`
+			Public    bool      `json:"public"`
+			CreatedAt time.Time `json:"created_at"`
+		} `json:"comments"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	var comments []string
+	for _, comment := range result.Comments {
+		if !comment.CreatedAt.After(since) {
+			break
+		}
+		if comment.Public {
+			comments = append(comments, comment.Body)
+		}
+	}
+	return comments, nil
+}
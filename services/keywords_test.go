@@ -0,0 +1,123 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/nukosuke/go-zendesk/zendesk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeywordPattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		alert   models.TagAlert
+		text    string
+		matches bool
+	}{
+		{"literal", models.TagAlert{Keywords: "outage"}, "Partial outage in EU", true},
+		{"any of several", models.TagAlert{Keywords: "refund\noutage"}, "Please refund me", true},
+		{"ignores case", models.TagAlert{Keywords: "outage"}, "OUTAGE", true},
+		{"case sensitive", models.TagAlert{Keywords: "SSO", KeywordCaseSensitive: true}, "sso login fails", false},
+		{"substring", models.TagAlert{Keywords: "down"}, "download stuck", true},
+		{"whole word", models.TagAlert{Keywords: "down", KeywordWholeWord: true}, "download stuck", false},
+		{"whole word at the end", models.TagAlert{Keywords: "down", KeywordWholeWord: true}, "site is down.", true},
+		{"special characters are literal", models.TagAlert{Keywords: "c++"}, "c programming", false},
+		{"regular expression", models.TagAlert{Keywords: `error \d{3}`, KeywordRegex: true}, "got error 503", true},
+		{"regular expression not matching", models.TagAlert{Keywords: `error \d{3}`, KeywordRegex: true}, "got error 5", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pattern, err := KeywordPattern(tt.alert)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.matches, pattern.MatchString(tt.text))
+		})
+	}
+
+	pattern, err := KeywordPattern(models.TagAlert{})
+	assert.NoError(t, err)
+	assert.Nil(t, pattern, "Expected no pattern for a rule without keywords")
+
+	_, err = KeywordPattern(models.TagAlert{Keywords: "error (", KeywordRegex: true})
+	assert.Error(t, err)
+}
+
+func TestKeywordMatches(t *testing.T) {
+	ticket := zendesk.Ticket{
+		Subject:     "Outage on checkout",
+		Description: "Since the outage our refund queue is stuck.\nAnother Outage hit at noon.",
+	}
+	tests := []struct {
+		name    string
+		alert   models.TagAlert
+		phrases []string
+		ok      bool
+	}{
+		{"no keywords match every ticket", models.TagAlert{}, nil, true},
+		{"subject and description", models.TagAlert{Keywords: "outage\nrefund"}, []string{"Outage", "refund"}, true},
+		{"no match", models.TagAlert{Keywords: "invoice"}, nil, false},
+		{"invalid pattern", models.TagAlert{Keywords: "(", KeywordRegex: true}, nil, false},
+		{"matches are limited", models.TagAlert{Keywords: `\w+`, KeywordRegex: true}, []string{"Outage", "on", "checkout", "Since", "the"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, ok := keywordMatches(tt.alert, ticket, nil)
+			assert.Equal(t, tt.ok, ok)
+			var phrases []string
+			for _, match := range matches {
+				phrases = append(phrases, match.Phrase)
+			}
+			assert.Equal(t, tt.phrases, phrases)
+		})
+	}
+}
+
+func TestKeywordContext(t *testing.T) {
+	long := strings.Repeat("lorem ipsum ", 10) + "OUTAGE" + strings.Repeat(" dolor sit", 10)
+	start := strings.Index(long, "OUTAGE")
+
+	tests := []struct {
+		name          string
+		text          string
+		start, end    int
+		before, after string
+	}{
+		{"short text is kept whole", "the outage began", 4, 10, "the ", " began"},
+		{"newlines become spaces", "line one\n\noutage\nline two", 10, 16, "line one ", " line two"},
+		{"long text is trimmed to whole words", long, start, start + 6, "…ipsum lorem ipsum lorem ipsum lorem ipsum lorem ipsum ", " dolor sit dolor sit dolor sit dolor sit dolor sit dolor…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, after := keywordContext(tt.text, tt.start, tt.end)
+			assert.Equal(t, tt.before, before)
+			assert.Equal(t, tt.after, after)
+		})
+	}
+
+	// Without spaces to trim to, the snippet is cut mid-word but never mid-rune
+	text := strings.Repeat("é", 80) + "x" + strings.Repeat("ü", 80)
+	start = strings.Index(text, "x")
+	before, after := keywordContext(text, start, start+1)
+	assert.True(t, utf8.ValidString(before), "before %q", before)
+	assert.True(t, utf8.ValidString(after), "after %q", after)
+	assert.True(t, strings.HasPrefix(before, "…") && strings.HasSuffix(after, "…"))
+}
+
+func TestSingleLine(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"", ""},
+		{"   ", " "},
+		{"one two", "one two"},
+		{"one\n\ttwo", "one two"},
+		{" one ", " one "},
+		{"\n\none\n", " one "},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, singleLine(tt.text), "singleLine(%q)", tt.text)
+	}
+}
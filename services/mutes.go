@@ -0,0 +1,269 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/nukosuke/go-zendesk/zendesk"
+)
+
+// maxConditionalMute is how long a mute waiting on a customer reply or an SLA
+// stage change lasts if neither happens.
+const maxConditionalMute = 7 * 24 * time.Hour
+
+// MuteOption is one of the durations a ticket can be snoozed for.
+type MuteOption struct {
+	Value string
+	Label string
+}
+
+// MuteOptions lists the snooze choices offered in Slack and the web UI.
+var MuteOptions = []MuteOption{
+	{Value: "1h", Label: "1 hour"},
+	{Value: "4h", Label: "4 hours"},
+	{Value: "24h", Label: "24 hours"},
+	{Value: string(models.MuteUntilCustomerReply), Label: "Until the customer replies"},
+	{Value: string(models.MuteUntilSLAStageChange), Label: "Until the SLA stage changes"},
+}
+
+// NewTicketMute builds a mute for the ticket from one of the MuteOptions values.
+// The caller sets whether it applies to a user or a Slack channel.
+func NewTicketMute(ticketID int64, option, mutedBy string) (models.TicketMute, error) {
+	mute := models.TicketMute{TicketID: ticketID, MutedBy: mutedBy}
+	now := time.Now()
+	switch option {
+	case string(models.MuteUntilCustomerReply), string(models.MuteUntilSLAStageChange):
+		mute.Condition = models.MuteCondition(option)
+		mute.Until = now.Add(maxConditionalMute)
+	default:
+		duration, err := time.ParseDuration(option)
+		if err != nil || duration <= 0 {
+			return mute, fmt.Errorf("invalid snooze duration %q", option)
+		}
+		mute.Condition = models.MuteUntilTime
+		mute.Until = now.Add(duration)
+	}
+	return mute, nil
+}
+
+// RecordMuteSLAStage sets the SLA stage a mute waiting on a stage change
+// starts from, using the ticket's current SLA data, so a change before the
+// next poll still ends the mute. When Zendesk cannot be reached the stage is
+// left for the next poll to record.
+func RecordMuteSLAStage(db db.Database, mute *models.TicketMute) {
+	if mute.Condition != models.MuteUntilSLAStageChange {
+		return
+	}
+	zc, err := NewZendeskClient(db)
+	if err != nil {
+		log.Printf("Failed to create Zendesk client to read the SLA stage of Ticket #%d: %v", mute.TicketID, err)
+		return
+	}
+	ticket, slaInfo, err := zc.GetTicketWithSLA(mute.TicketID)
+	if err != nil {
+		log.Printf("Failed to read the SLA stage of Ticket #%d: %v", mute.TicketID, err)
+		return
+	}
+	mute.SLAStage = slaStage(*ticket, map[int64]SLAInfo{ticket.ID: slaInfo})
+}
+
+// ticketMuted reports whether any of the ticket's mutes silence the rule.
+func ticketMuted(mutes []models.TicketMute, rule models.TagAlert) bool {
+	for _, mute := range mutes {
+		if mute.Applies(rule) {
+			return true
+		}
+	}
+	return false
+}
+
+// slaStage names the SLA alert threshold the ticket has reached, so mutes can
+// end when the ticket moves to the next one.
+func slaStage(ticket zendesk.Ticket, slaData map[int64]SLAInfo) string {
+	if metric, label, ok := matchingSLAMetric(slaData[ticket.ID].PolicyMetrics); ok {
+		return metric.Metric + ": " + label
+	}
+	return "No SLA alert"
+}
+
+// releaseTicketMutes ends the mutes whose condition has been met by the polled
+// tickets and clears expired mutes. Mutes waiting on an SLA stage change whose
+// stage could not be read when they were created take it from the first poll
+// that sees the ticket.
+func releaseTicketMutes(ctx context.Context, db db.Database, zc *ZendeskClient, tickets []zendesk.Ticket, slaData map[int64]SLAInfo) {
+	if err := models.DeleteExpiredTicketMutes(ctx, db); err != nil {
+		log.Println("Error clearing expired ticket mutes:", err)
+	}
+
+	mutes, err := models.GetActiveTicketMutes(db)
+	if err != nil || len(mutes) == 0 {
+		if err != nil {
+			log.Println("Error fetching ticket mutes:", err)
+		}
+		return
+	}
+
+	seen := make(map[int64]bool)
+	for _, ticket := range tickets {
+		if seen[ticket.ID] {
+			continue
+		}
+		seen[ticket.ID] = true
+
+		var replied *bool
+		for _, mute := range mutes[ticket.ID] {
+			switch mute.Condition {
+			case models.MuteUntilSLAStageChange:
+				if _, ok := slaData[ticket.ID]; !ok {
+					continue
+				}
+				stage := slaStage(ticket, slaData)
+				if mute.SLAStage == "" {
+					if err := models.SetTicketMuteSLAStage(ctx, db, mute.ID, stage); err != nil {
+						log.Println("Error recording ticket mute SLA stage:", err)
+					}
+				} else if stage != mute.SLAStage {
+					log.Printf("SLA stage of Ticket #%d changed to %q, ending mute", ticket.ID, stage)
+					endTicketMute(ctx, db, mute)
+				}
+			case models.MuteUntilCustomerReply:
+				if !ticket.UpdatedAt.After(mute.CreatedAt) {
+					continue
+				}
+				if replied == nil {
+					found, err := zc.RequesterRepliedSince(ticket, mute.CreatedAt)
+					if err != nil {
+						log.Printf("Error checking replies on Ticket #%d: %v", ticket.ID, err)
+						continue
+					}
+					replied = &found
+				}
+				if *replied {
+					log.Printf("Customer replied on Ticket #%d, ending mute", ticket.ID)
+					endTicketMute(ctx, db, mute)
+				}
+			}
+		}
+	}
+}
+
+func endTicketMute(ctx context.Context, db db.Database, mute models.TicketMute) {
+	if err := models.DeleteTicketMute(ctx, db, mute.TicketID, mute.ID); err != nil {
+		log.Println("Error ending ticket mute:", err)
+	}
+}
+
+// GetTicketByID retrieves a single ticket from Zendesk.
+func (zc *ZendeskClient) GetTicketByID(ticketID int64) (*zendesk.Ticket, error) {
+	endpoint := fmt.Sprintf("https://%s.zendesk.com/api/v2/tickets/%d.json", zc.Subdomain, ticketID)
+
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(zc.Email+"/token", zc.APIToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("zendesk returned status %s", resp.Status)
+	}
+
+	var result struct {
+		Ticket zendesk.Ticket `json:"ticket"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return &result.Ticket, nil
+}
+
+// GetTicketWithSLA retrieves a single ticket from Zendesk along with its SLA metrics.
+func (zc *ZendeskClient) GetTicketWithSLA(ticketID int64) (*zendesk.Ticket, SLAInfo, error) {
+	endpoint := fmt.Sprintf("https://%s.zendesk.com/api/v2/tickets/%d.json?include=slas", zc.Subdomain, ticketID)
+
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, SLAInfo{}, err
+	}
+	req.SetBasicAuth(zc.Email+"/token", zc.APIToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, SLAInfo{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, SLAInfo{}, fmt.Errorf("zendesk returned status %s", resp.Status)
+	}
+
+	var result struct {
+		Ticket struct {
+			zendesk.Ticket
+			SLAMetrics SLAInfo `json:"slas"`
+		} `json:"ticket"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, SLAInfo{}, err
+	}
+
+	return &result.Ticket.Ticket, result.Ticket.SLAMetrics, nil
+}
+
+// RequesterRepliedSince reports whether the ticket's requester has added a
+// public comment after since.
+func (zc *ZendeskClient) RequesterRepliedSince(ticket zendesk.Ticket, since time.Time) (bool, error) {
+	endpoint := fmt.Sprintf("https://%s.zendesk.com/api/v2/tickets/%d/comments.json?sort_order=desc", zc.Subdomain, ticket.ID)
+
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return false, err
+	}
+	req.SetBasicAuth(zc.Email+"/token", zc.APIToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("zendesk returned status %s", resp.Status)
+	}
+
+	var result struct {
+		Comments []zendesk.TicketComment `json:"comments"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, err
+	}
+
+	for _, comment := range result.Comments {
+		if !comment.CreatedAt.After(since) {
+			continue
+		}
+		public := comment.Public == nil || *comment.Public
+		if public && comment.AuthorID == ticket.RequesterID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
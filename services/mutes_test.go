@@ -0,0 +1,41 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nukosuke/go-zendesk/zendesk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSLAStage(t *testing.T) {
+	ticket := zendesk.Ticket{ID: 42}
+	stageAt := func(remaining time.Duration) string {
+		return slaStage(ticket, map[int64]SLAInfo{ticket.ID: {PolicyMetrics: []SLAPolicyMetric{
+			{Metric: "first_reply_time", Stage: "active", BreachAt: time.Now().Add(remaining)},
+		}}})
+	}
+
+	assert.Equal(t, "No SLA alert", slaStage(ticket, nil))
+	assert.Equal(t, "No SLA alert", stageAt(5*time.Hour))
+	assert.Equal(t, "first_reply_time: Less than 3 hours remaining", stageAt(2*time.Hour+30*time.Minute))
+	assert.Equal(t, stageAt(2*time.Hour+30*time.Minute), stageAt(2*time.Hour+10*time.Minute), "Expected the stage to hold within a threshold")
+	assert.NotEqual(t, stageAt(2*time.Hour+30*time.Minute), stageAt(90*time.Minute), "Expected crossing a threshold to change the stage")
+	assert.Equal(t, "first_reply_time: "+slaLabelBreached, stageAt(-time.Minute))
+}
+
+func TestNewTicketMute(t *testing.T) {
+	mute, err := NewTicketMute(42, "4h", "Ana")
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(4*time.Hour), mute.Until, time.Minute)
+
+	mute, err = NewTicketMute(42, "sla_stage", "Ana")
+	assert.NoError(t, err)
+	assert.Equal(t, "sla_stage", string(mute.Condition))
+	assert.WithinDuration(t, time.Now().Add(maxConditionalMute), mute.Until, time.Minute)
+
+	for _, option := range []string{"", "forever", "-1h"} {
+		_, err := NewTicketMute(42, option, "Ana")
+		assert.Error(t, err, option)
+	}
+}
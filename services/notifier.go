@@ -0,0 +1,535 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+	"github.com/TylerConlee/TicketPulse/middlewares"
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/nukosuke/go-zendesk/zendesk"
+)
+
+// Notification channels a user can choose between for each alert type.
+const (
+	ChannelSlackChannel = "slack_channel"
+	ChannelSlackDM      = "slack_dm"
+	ChannelEmail        = "email"
+	ChannelWebhook      = "webhook"
+)
+
+// AlertTypeDailySummary is the alert type used when delivering daily summaries.
+const AlertTypeDailySummary = "daily_summary"
+
+// AlertTypeOption describes an alert type shown in the preferences matrix.
+type AlertTypeOption struct {
+	Value string
+	Label string
+}
+
+// AlertTypeOptions lists every alert type a user can set delivery preferences for, in display order.
+var AlertTypeOptions = []AlertTypeOption{
+	{AlertTypeNewTicket, "New Ticket"},
+	{AlertTypeTicketUpdate, "Ticket Update"},
+	{AlertTypeSLABreach, "SLA Breach"},
+	{AlertTypeSLARisk, "Likely SLA Breach"},
+	{AlertTypePriorityRaised, "Priority Raised"},
+	{AlertTypeReassigned, "Reassigned"},
+	{AlertTypeReopened, "Reopened"},
+	{AlertTypeGroupChanged, "Moved to Group"},
+	{AlertTypeTagAdded, "Tag Added"},
+	{AlertTypeCSATNegative, "Negative CSAT"},
+	{AlertTypeUnassigned, "Unassigned Too Long"},
+	{AlertTypeNoAgentReply, "No Agent Reply"},
+	{AlertTypePendingTooLong, "Pending Too Long"},
+	{AlertTypeVolumeSpike, "Volume Spike"},
+	{AlertTypePossibleDuplicate, "Possible Duplicate"},
+	{AlertTypeDailySummary, "Daily Summary"},
+}
+
+// Notification is a single alert or summary to be delivered to a user.
+type Notification struct {
+	AlertType string
+	Recipient models.User
+	Rule      *models.TagAlert
+	Ticket    *zendesk.Ticket
+	SLA       *SLAInfo
+	SLALabel  string
+	Matches   []KeywordMatch // Phrases matched by the rule's keywords
+	// Duplicates are recent open tickets the ticket is likely a duplicate of
+	Duplicates []DuplicateTicket
+	Risk       *SLARisk // Predicted chance of breaching the SLA, when known
+	Summary    *DailySummary
+	Spike      *VolumeSpike
+}
+
+// Notifier delivers notifications over a single channel.
+type Notifier interface {
+	// Channel is the identifier stored in the user's preferences.
+	Channel() string
+	// Label is the human readable channel name.
+	Label() string
+	// Supports reports whether the channel can deliver the given alert type.
+	Supports(alertType string) bool
+	// Personal reports whether the channel delivers to the recipient rather than a shared destination.
+	Personal() bool
+	// Destination describes where Notify would deliver the notification, for logs.
+	Destination(n Notification) string
+	Notify(ctx context.Context, n Notification) error
+}
+
+// NotifierRegistry holds the notifiers available to the application, in registration order.
+type NotifierRegistry struct {
+	notifiers []Notifier
+}
+
+func NewNotifierRegistry(notifiers ...Notifier) *NotifierRegistry {
+	r := &NotifierRegistry{}
+	for _, n := range notifiers {
+		r.Register(n)
+	}
+	return r
+}
+
+// Register adds a notifier, replacing any notifier already registered for the same channel.
+func (r *NotifierRegistry) Register(notifier Notifier) {
+	for i, existing := range r.notifiers {
+		if existing.Channel() == notifier.Channel() {
+			r.notifiers[i] = notifier
+			return
+		}
+	}
+	r.notifiers = append(r.notifiers, notifier)
+}
+
+// Notifiers returns the registered notifiers.
+func (r *NotifierRegistry) Notifiers() []Notifier {
+	return r.notifiers
+}
+
+type NotificationService struct {
+	DB        db.Database
+	registry  *NotifierRegistry
+	sseServer *middlewares.SSEServer
+	limiter   *RateLimiter
+	rollupsMu sync.Mutex
+	rollups   map[string]*rollup
+}
+
+func NewNotificationService(db db.Database, registry *NotifierRegistry, sseServer *middlewares.SSEServer) *NotificationService {
+	return &NotificationService{
+		DB:        db,
+		registry:  registry,
+		sseServer: sseServer,
+		limiter:   NewRateLimiter(),
+		rollups:   make(map[string]*rollup),
+	}
+}
+
+// Dispatch delivers the notification over every channel the recipient has enabled for its alert type.
+// Notifications for team-owned rules are posted once to shared channels and then
+// delivered to each team member over the personal channels they have enabled.
+// Notifications for on-call rules only reach the on-call recipient personally.
+func (s *NotificationService) Dispatch(ctx context.Context, n Notification) error {
+	if n.Rule != nil && n.Rule.OnCallScheduleID.Valid {
+		return s.dispatchDirect(ctx, n)
+	}
+	if n.Rule != nil && n.Rule.TeamID.Valid {
+		return s.dispatchTeam(ctx, n)
+	}
+
+	recipient, err := models.GetUserByID(s.DB, n.Recipient.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load recipient %d: %w", n.Recipient.ID, err)
+	}
+	n.Recipient = recipient
+
+	prefs, err := models.GetNotificationPreferences(s.DB, recipient.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load notification preferences for user %d: %w", recipient.ID, err)
+	}
+
+	shadow := ShadowModeEnabled(s.DB)
+	var errs []error
+	for _, notifier := range s.registry.Notifiers() {
+		if notifier.Personal() || !notifier.Supports(n.AlertType) || !channelEnabled(prefs, n.AlertType, notifier.Channel()) {
+			continue
+		}
+		if err := s.deliver(ctx, notifier, n, shadow); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", notifier.Channel(), err))
+		}
+	}
+	if err := s.dispatchPersonal(ctx, n, prefs, shadow); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// dispatchTeam delivers a team rule's notification. Shared channels are always
+// used; personal channels follow each member's own preferences.
+func (s *NotificationService) dispatchTeam(ctx context.Context, n Notification) error {
+	members, err := models.GetTeamMembers(s.DB, int(n.Rule.TeamID.Int64))
+	if err != nil {
+		return fmt.Errorf("failed to load members of team %d: %w", n.Rule.TeamID.Int64, err)
+	}
+
+	shadow := ShadowModeEnabled(s.DB)
+	var errs []error
+	for _, notifier := range s.registry.Notifiers() {
+		if notifier.Personal() || !notifier.Supports(n.AlertType) {
+			continue
+		}
+		if err := s.deliver(ctx, notifier, n, shadow); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", notifier.Channel(), err))
+		}
+	}
+
+	for _, member := range members {
+		recipient, err := models.GetUserByID(s.DB, member.UserID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to load team member %d: %w", member.UserID, err))
+			continue
+		}
+		prefs, err := models.GetNotificationPreferences(s.DB, recipient.ID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to load notification preferences for user %d: %w", recipient.ID, err))
+			continue
+		}
+
+		memberNotification := n
+		memberNotification.Recipient = recipient
+		if err := s.dispatchPersonal(ctx, memberNotification, prefs, shadow); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// dispatchDirect delivers a notification meant for one person, such as an
+// on-call rule's alert or a watched ticket's alert, over their personal
+// channels. Recipients who have no personal channel enabled for the alert type
+// are sent a Slack DM so the alert is not lost.
+func (s *NotificationService) dispatchDirect(ctx context.Context, n Notification) error {
+	recipient, err := models.GetUserByID(s.DB, n.Recipient.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load recipient %d: %w", n.Recipient.ID, err)
+	}
+	n.Recipient = recipient
+
+	prefs, err := models.GetNotificationPreferences(s.DB, recipient.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load notification preferences for user %d: %w", recipient.ID, err)
+	}
+	personal := false
+	for _, notifier := range s.registry.Notifiers() {
+		if notifier.Personal() && notifier.Supports(n.AlertType) && channelEnabled(prefs, n.AlertType, notifier.Channel()) {
+			personal = true
+		}
+	}
+	if !personal {
+		if prefs[n.AlertType] == nil {
+			prefs[n.AlertType] = make(map[string]bool)
+		}
+		prefs[n.AlertType][ChannelSlackDM] = true
+	}
+
+	return s.dispatchPersonal(ctx, n, prefs, ShadowModeEnabled(s.DB))
+}
+
+// dispatchPersonal delivers the notification over the personal channels the
+// recipient has enabled. While the recipient is out of office, ticket alerts
+// go to their delegate's personal channels or are posted to the delegate
+// Slack channel instead.
+func (s *NotificationService) dispatchPersonal(ctx context.Context, n Notification, prefs map[string]map[string]bool, shadow bool) error {
+	if n.Ticket != nil {
+		absence, err := models.GetAbsence(s.DB, n.Recipient.ID)
+		if err != nil {
+			return err
+		}
+		if absence != nil && absence.Active(time.Now()) {
+			return s.dispatchDelegate(ctx, n, prefs, *absence, shadow)
+		}
+	}
+
+	var errs []error
+	for _, notifier := range s.registry.Notifiers() {
+		if !notifier.Personal() || !notifier.Supports(n.AlertType) || !channelEnabled(prefs, n.AlertType, notifier.Channel()) {
+			continue
+		}
+		if err := s.deliver(ctx, notifier, n, shadow); err != nil {
+			errs = append(errs, fmt.Errorf("%s to %s: %w", notifier.Channel(), n.Recipient.Email, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// dispatchDelegate delivers an absent recipient's personal notification to
+// their delegate over the personal channels the absent recipient has enabled.
+// Recipients with none enabled still have their own rules' alerts delegated,
+// as a Slack DM to the delegate user or a post to the delegate channel; team
+// alerts already reach the team's channels and are not.
+func (s *NotificationService) dispatchDelegate(ctx context.Context, n Notification, prefs map[string]map[string]bool, absence models.Absence, shadow bool) error {
+	channels := make(map[string]bool)
+	for _, notifier := range s.registry.Notifiers() {
+		if notifier.Personal() && notifier.Supports(n.AlertType) && channelEnabled(prefs, n.AlertType, notifier.Channel()) {
+			channels[notifier.Channel()] = true
+		}
+	}
+	if len(channels) == 0 {
+		if n.Rule != nil && n.Rule.TeamID.Valid {
+			return nil
+		}
+		channels[ChannelSlackDM] = true
+	}
+
+	if absence.DelegateUserID.Valid {
+		delegate, err := models.GetUserByID(s.DB, int(absence.DelegateUserID.Int64))
+		if err != nil {
+			return fmt.Errorf("failed to load delegate %d: %w", absence.DelegateUserID.Int64, err)
+		}
+
+		// The delegate's own absence is not followed, so alerts cannot loop between delegates.
+		delegated := n
+		delegated.Recipient = delegate
+		var errs []error
+		for _, notifier := range s.registry.Notifiers() {
+			if !notifier.Personal() || !notifier.Supports(n.AlertType) || !channels[notifier.Channel()] {
+				continue
+			}
+			if err := s.deliver(ctx, notifier, delegated, shadow); err != nil {
+				errs = append(errs, fmt.Errorf("%s to delegate %s: %w", notifier.Channel(), delegate.Email, err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+
+	if absence.DelegateChannel == "" || n.Rule == nil {
+		return nil
+	}
+	rule := *n.Rule
+	rule.SlackChannelID = absence.DelegateChannel
+	delegated := n
+	delegated.Rule = &rule
+	for _, notifier := range s.registry.Notifiers() {
+		if notifier.Channel() == ChannelSlackChannel {
+			return s.deliver(ctx, notifier, delegated, shadow)
+		}
+	}
+	return nil
+}
+
+// deliver sends the notification, records it to the shadow log when shadow
+// mode is enabled, queues it when the rule delivers digests, or holds it for a
+// rollup when a rate limit has been reached. Personal notifications first
+// follow the recipient's quiet hours.
+func (s *NotificationService) deliver(ctx context.Context, notifier Notifier, n Notification, shadow bool) error {
+	n, send, err := s.applyQuietHours(ctx, notifier, n)
+	if err != nil || !send {
+		return err
+	}
+	if shadow {
+		return recordShadowAlert(ctx, s.DB, notifier.Channel(), notifier.Destination(n), n)
+	}
+	if _, ok := digestRule(notifier, n); ok {
+		return s.enqueueDigest(ctx, notifier, n)
+	}
+	if !s.allowDelivery(notifier, n) {
+		return nil
+	}
+	return notifier.Notify(ctx, n)
+}
+
+// channelEnabled applies the user's saved preference, falling back to the
+// behaviour from before preferences existed: rule alerts go to the rule's
+// Slack channel and summaries go to a Slack DM.
+func channelEnabled(prefs map[string]map[string]bool, alertType, channel string) bool {
+	if enabled, ok := prefs[alertType][channel]; ok {
+		return enabled
+	}
+	if alertType == AlertTypeDailySummary {
+		return channel == ChannelSlackDM
+	}
+	return channel == ChannelSlackChannel
+}
+
+// PreferenceCell is one checkbox in the preferences matrix.
+type PreferenceCell struct {
+	Channel   string
+	Supported bool
+	Enabled   bool
+}
+
+// PreferenceRow is one alert type in the preferences matrix.
+type PreferenceRow struct {
+	AlertType string
+	Label     string
+	Cells     []PreferenceCell
+}
+
+// Channels returns the value and label of every registered channel, for table headers.
+func (s *NotificationService) Channels() []AlertTypeOption {
+	var channels []AlertTypeOption
+	for _, notifier := range s.registry.Notifiers() {
+		channels = append(channels, AlertTypeOption{Value: notifier.Channel(), Label: notifier.Label()})
+	}
+	return channels
+}
+
+// PreferenceMatrix builds the alert type by channel matrix shown on the profile page.
+func (s *NotificationService) PreferenceMatrix(userID int) ([]PreferenceRow, error) {
+	prefs, err := models.GetNotificationPreferences(s.DB, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []PreferenceRow
+	for _, alertType := range AlertTypeOptions {
+		row := PreferenceRow{AlertType: alertType.Value, Label: alertType.Label}
+		for _, notifier := range s.registry.Notifiers() {
+			supported := notifier.Supports(alertType.Value)
+			row.Cells = append(row.Cells, PreferenceCell{
+				Channel:   notifier.Channel(),
+				Supported: supported,
+				Enabled:   supported && channelEnabled(prefs, alertType.Value, notifier.Channel()),
+			})
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// SavePreferences stores a preference for every supported alert type and channel combination.
+func (s *NotificationService) SavePreferences(userID int, enabled func(alertType, channel string) bool) error {
+	for _, alertType := range AlertTypeOptions {
+		for _, notifier := range s.registry.Notifiers() {
+			if !notifier.Supports(alertType.Value) {
+				continue
+			}
+			err := models.SetNotificationPreference(s.DB, models.NotificationPreference{
+				UserID:    userID,
+				AlertType: alertType.Value,
+				Channel:   notifier.Channel(),
+				Enabled:   enabled(alertType.Value, notifier.Channel()),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ticketURL returns the agent URL for a ticket, or an empty string if Zendesk is not configured.
+func ticketURL(db db.Database, ticketID int64) string {
+	subdomain, err := models.GetConfiguration(db, "zendesk_subdomain")
+	if err != nil || subdomain == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://%s.zendesk.com/agent/tickets/%d", subdomain, ticketID)
+}
+
+// notificationSubject returns a one line description of the notification.
+func notificationSubject(n Notification) string {
+	if n.Summary != nil {
+		return "Your TicketPulse daily summary"
+	}
+	if n.Spike != nil {
+		return "Ticket volume spike: " + n.Spike.Label()
+	}
+	if n.Ticket == nil {
+		return "TicketPulse alert"
+	}
+
+	switch n.AlertType {
+	case AlertTypeNewTicket:
+		return fmt.Sprintf("New ticket #%d: %s", n.Ticket.ID, n.Ticket.Subject)
+	case AlertTypeTicketUpdate:
+		return fmt.Sprintf("Ticket #%d updated: %s", n.Ticket.ID, n.Ticket.Subject)
+	case AlertTypeSLABreach:
+		return fmt.Sprintf("%s on ticket #%d: %s", n.SLALabel, n.Ticket.ID, n.Ticket.Subject)
+	case AlertTypePriorityRaised:
+		return fmt.Sprintf("Ticket #%d raised to %s priority: %s", n.Ticket.ID, n.Ticket.Priority, n.Ticket.Subject)
+	case AlertTypeReassigned:
+		return fmt.Sprintf("Ticket #%d reassigned: %s", n.Ticket.ID, n.Ticket.Subject)
+	case AlertTypeReopened:
+		return fmt.Sprintf("Ticket #%d reopened: %s", n.Ticket.ID, n.Ticket.Subject)
+	case AlertTypeGroupChanged:
+		return fmt.Sprintf("Ticket #%d moved to a new group: %s", n.Ticket.ID, n.Ticket.Subject)
+	case AlertTypeTagAdded:
+		return fmt.Sprintf("Ticket #%d tagged: %s", n.Ticket.ID, n.Ticket.Subject)
+	case AlertTypeCSATNegative:
+		return fmt.Sprintf("Negative satisfaction rating on ticket #%d: %s", n.Ticket.ID, n.Ticket.Subject)
+	case AlertTypeUnassigned, AlertTypeNoAgentReply, AlertTypePendingTooLong:
+		return fmt.Sprintf("Ticket #%d %s: %s", n.Ticket.ID, strings.ToLower(n.SLALabel), n.Ticket.Subject)
+	case AlertTypeSLARisk:
+		return fmt.Sprintf("Ticket #%d is %s: %s", n.Ticket.ID, n.SLALabel, n.Ticket.Subject)
+	case AlertTypePossibleDuplicate:
+		return fmt.Sprintf("Possible duplicate ticket #%d: %s", n.Ticket.ID, n.Ticket.Subject)
+	default:
+		return fmt.Sprintf("Alert for ticket #%d: %s", n.Ticket.ID, n.Ticket.Subject)
+	}
+}
+
+// notificationBody returns a plain text rendering of the notification.
+func notificationBody(db db.Database, n Notification) string {
+	if n.Summary != nil {
+		return n.Summary.Message
+	}
+	if n.Spike != nil {
+		var sb strings.Builder
+		sb.WriteString(notificationSubject(n) + "\n\n")
+		for _, ticketID := range n.Spike.TicketIDs {
+			if url := ticketURL(db, ticketID); url != "" {
+				sb.WriteString(url + "\n")
+			} else {
+				sb.WriteString(fmt.Sprintf("Ticket #%d\n", ticketID))
+			}
+		}
+		return sb.String()
+	}
+	if n.Ticket == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(notificationSubject(n) + "\n\n")
+	if url := ticketURL(db, n.Ticket.ID); url != "" {
+		sb.WriteString(fmt.Sprintf("Ticket: %s\n", url))
+	}
+	sb.WriteString(fmt.Sprintf("Status: %s\n", n.Ticket.Status))
+	sb.WriteString(fmt.Sprintf("Priority: %s\n", n.Ticket.Priority))
+	if n.Rule != nil {
+		sb.WriteString(fmt.Sprintf("Tag: %s\n", n.Rule.Tag))
+	}
+	if n.SLA != nil && len(n.SLA.PolicyMetrics) > 0 {
+		sb.WriteString(fmt.Sprintf("SLA Expiration: %s\n", n.SLA.PolicyMetrics[0].BreachAt.Format("2006-01-02 15:04")))
+	}
+	for _, match := range n.Matches {
+		sb.WriteString(fmt.Sprintf("Matched %q: %s\n", match.Phrase, match.Snippet()))
+	}
+	if n.Risk != nil {
+		sb.WriteString(fmt.Sprintf("Breach Risk: %d%% (based on %d similar tickets)\n", n.Risk.Score, n.Risk.Samples))
+	}
+	for _, duplicate := range n.Duplicates {
+		sb.WriteString(fmt.Sprintf("Possible duplicate of #%d (%d%% similar): %s\n", duplicate.ID, duplicate.Percent(), duplicate.Subject))
+	}
+	if n.AlertType == AlertTypeCSATNegative && n.Ticket.SatisfactionRating != nil {
+		customers := NewCustomerResolver(db, nil)
+		if organization := customers.Lookup(n.Ticket.OrganizationID, n.Ticket.RequesterID).OrganizationName; organization != "" {
+			sb.WriteString(fmt.Sprintf("Organization: %s\n", organization))
+		}
+		if assignee := customers.UserName(n.Ticket.AssigneeID); assignee != "" {
+			sb.WriteString(fmt.Sprintf("Assignee: %s\n", assignee))
+		}
+		sb.WriteString(fmt.Sprintf("Rating: %s\n", n.Ticket.SatisfactionRating.Score))
+		if comment := n.Ticket.SatisfactionRating.Comment; comment != "" {
+			sb.WriteString(fmt.Sprintf("Comment: %s\n", comment))
+		}
+	}
+	sb.WriteString("\n" + truncateDescription(n.Ticket.Description, 60) + "\n")
+	return sb.String()
+}
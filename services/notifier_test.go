@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/nukosuke/go-zendesk/zendesk"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingNotifier records where each notification would have been delivered.
+type recordingNotifier struct {
+	channel  string
+	personal bool
+	sent     *[]string
+}
+
+func (r recordingNotifier) Channel() string                { return r.channel }
+func (r recordingNotifier) Label() string                  { return r.channel }
+func (r recordingNotifier) Supports(alertType string) bool { return true }
+func (r recordingNotifier) Personal() bool                 { return r.personal }
+
+func (r recordingNotifier) Destination(n Notification) string {
+	if r.personal {
+		return n.Recipient.Email
+	}
+	return n.Rule.SlackChannelID
+}
+
+func (r recordingNotifier) Notify(ctx context.Context, n Notification) error {
+	*r.sent = append(*r.sent, r.channel+" "+r.Destination(n))
+	return nil
+}
+
+func TestDispatchDelegate(t *testing.T) {
+	tests := []struct {
+		name     string
+		absence  models.Absence
+		prefs    []models.NotificationPreference
+		expected []string
+	}{
+		{
+			name:     "delegate user with default preferences",
+			absence:  models.Absence{DelegateUserID: sql.NullInt64{Int64: 2, Valid: true}},
+			expected: []string{"slack_channel C-RULE", "slack_dm delegate@example.com"},
+		},
+		{
+			name:     "delegate channel with default preferences",
+			absence:  models.Absence{DelegateChannel: "C-COVER"},
+			expected: []string{"slack_channel C-RULE", "slack_channel C-COVER"},
+		},
+		{
+			name:    "delegate user gets the absent user's channels",
+			absence: models.Absence{DelegateUserID: sql.NullInt64{Int64: 2, Valid: true}},
+			prefs: []models.NotificationPreference{
+				{UserID: 1, AlertType: AlertTypeNewTicket, Channel: ChannelEmail, Enabled: true},
+				{UserID: 2, AlertType: AlertTypeNewTicket, Channel: ChannelSlackDM, Enabled: false},
+			},
+			expected: []string{"slack_channel C-RULE", "email delegate@example.com"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := db.InitDB(":memory:")
+			defer database.Close()
+			assert.NoError(t, models.CreateUser(database, "away@example.com", "Away", models.AgentRole, false))
+			assert.NoError(t, models.CreateUser(database, "delegate@example.com", "Delegate", models.AgentRole, false))
+			for _, pref := range tt.prefs {
+				assert.NoError(t, models.SetNotificationPreference(database, pref))
+			}
+			absence := tt.absence
+			absence.UserID = 1
+			absence.AwayFrom = time.Now().Add(-time.Hour)
+			absence.AwayUntil = time.Now().Add(time.Hour)
+			assert.NoError(t, models.SetAbsence(database, absence))
+
+			var sent []string
+			registry := NewNotifierRegistry(
+				recordingNotifier{channel: ChannelSlackChannel, sent: &sent},
+				recordingNotifier{channel: ChannelSlackDM, personal: true, sent: &sent},
+				recordingNotifier{channel: ChannelEmail, personal: true, sent: &sent},
+			)
+			service := NewNotificationService(database, registry, nil)
+
+			err := service.Dispatch(context.Background(), Notification{
+				AlertType: AlertTypeNewTicket,
+				Recipient: models.User{ID: 1},
+				Rule:      &models.TagAlert{ID: 1, UserID: 1, SlackChannelID: "C-RULE", AlertType: AlertTypeNewTicket},
+				Ticket:    &zendesk.Ticket{ID: 42},
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, sent)
+		})
+	}
+}
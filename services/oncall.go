@@ -0,0 +1,250 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+	"github.com/TylerConlee/TicketPulse/models"
+)
+
+// OnCallShift is who is on call for a schedule and until when.
+type OnCallShift struct {
+	Schedule models.OnCallSchedule
+	UserID   int
+	Name     string
+	Email    string
+	Until    time.Time
+	Override bool // Set when an override rather than the rotation applies
+}
+
+// CurrentOnCall returns who is on call for the schedule at now. Overrides
+// take precedence over the rotation. It returns nil when the schedule has no
+// members and no override applies.
+func CurrentOnCall(db db.Database, scheduleID int, now time.Time) (*OnCallShift, error) {
+	schedule, err := models.GetOnCallScheduleByID(db, scheduleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load on-call schedule %d: %w", scheduleID, err)
+	}
+
+	override, err := models.GetActiveOnCallOverride(db, scheduleID, now)
+	if err != nil {
+		return nil, err
+	}
+	if override != nil {
+		return &OnCallShift{Schedule: schedule, UserID: override.UserID, Name: override.Name, Email: override.Email, Until: override.EndsAt, Override: true}, nil
+	}
+
+	members, err := models.GetOnCallMembers(db, scheduleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load members of on-call schedule %d: %w", scheduleID, err)
+	}
+	if len(members) == 0 {
+		return nil, nil
+	}
+
+	shiftLength := time.Duration(schedule.RotationDays) * 24 * time.Hour
+	if shiftLength <= 0 {
+		shiftLength = 7 * 24 * time.Hour
+	}
+	shift := 0
+	if elapsed := now.Sub(schedule.RotationStart); elapsed > 0 {
+		shift = int(elapsed / shiftLength)
+	}
+	member := members[shift%len(members)]
+	return &OnCallShift{
+		Schedule: schedule,
+		UserID:   member.UserID,
+		Name:     member.Name,
+		Email:    member.Email,
+		Until:    schedule.RotationStart.Add(time.Duration(shift+1) * shiftLength),
+	}, nil
+}
+
+// resolveOnCall points a notification for an on-call rule at whoever is on
+// call now. It reports false when nobody is on call.
+func resolveOnCall(db db.Database, n *Notification) (bool, error) {
+	if n.Rule == nil || !n.Rule.OnCallScheduleID.Valid {
+		return true, nil
+	}
+	shift, err := CurrentOnCall(db, int(n.Rule.OnCallScheduleID.Int64), time.Now())
+	if err != nil || shift == nil {
+		return false, err
+	}
+	n.Recipient = models.User{ID: shift.UserID, Name: shift.Name, Email: shift.Email}
+	return true, nil
+}
+
+// ICalEvent is the part of a calendar event used to import on-call overrides.
+type ICalEvent struct {
+	UID       string
+	Summary   string
+	Attendees []string // Email addresses of the event's attendees
+	Start     time.Time
+	End       time.Time
+}
+
+// ParseICalEvents reads the events from an iCalendar file.
+func ParseICalEvents(r io.Reader) ([]ICalEvent, error) {
+	lines, err := unfoldICalLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []ICalEvent
+	var event *ICalEvent
+	for _, line := range lines {
+		name, params, value := splitICalLine(line)
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			event = &ICalEvent{}
+		case name == "END" && value == "VEVENT":
+			if event == nil {
+				continue
+			}
+			if event.Start.IsZero() {
+				return nil, fmt.Errorf("event %q has no start time", event.Summary)
+			}
+			if event.End.IsZero() {
+				event.End = event.Start.Add(24 * time.Hour)
+			}
+			events = append(events, *event)
+			event = nil
+		case event == nil:
+			continue
+		case name == "UID":
+			event.UID = value
+		case name == "SUMMARY":
+			event.Summary = unescapeICalText(value)
+		case name == "ATTENDEE":
+			if email, ok := strings.CutPrefix(strings.ToLower(value), "mailto:"); ok {
+				event.Attendees = append(event.Attendees, email)
+			}
+		case name == "DTSTART" || name == "DTEND":
+			t, err := parseICalTime(value, params)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q: %w", name, value, err)
+			}
+			if name == "DTSTART" {
+				event.Start = t
+			} else {
+				event.End = t
+			}
+		}
+	}
+	return events, nil
+}
+
+// unfoldICalLines joins continuation lines, which start with a space or tab.
+func unfoldICalLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// splitICalLine splits "NAME;PARAM=VALUE:value" into its name, parameters and value.
+func splitICalLine(line string) (string, map[string]string, string) {
+	head, value, _ := strings.Cut(line, ":")
+	parts := strings.Split(head, ";")
+	params := make(map[string]string)
+	for _, param := range parts[1:] {
+		if key, val, ok := strings.Cut(param, "="); ok {
+			params[strings.ToUpper(key)] = strings.Trim(val, `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, value
+}
+
+func parseICalTime(value string, params map[string]string) (time.Time, error) {
+	if params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		return time.ParseInLocation("20060102", value, time.Local)
+	}
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse("20060102T150405Z", value)
+	}
+	loc := time.Local
+	if tzid := params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	return time.ParseInLocation("20060102T150405", value, loc)
+}
+
+func unescapeICalText(value string) string {
+	return strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(value)
+}
+
+// ImportOnCallOverrides adds an override for every event in the calendar that
+// names a TicketPulse user, either as an attendee or in its summary. Events
+// that have already ended or name nobody are skipped.
+func ImportOnCallOverrides(ctx context.Context, db db.Database, scheduleID int, r io.Reader) (int, int, error) {
+	events, err := ParseICalEvents(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	users, err := models.GetAllUsers(db)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	now := time.Now()
+	imported, skipped := 0, 0
+	var errs []error
+	for _, event := range events {
+		userID, ok := icalEventUser(event, users)
+		if !ok || !event.End.After(now) {
+			skipped++
+			continue
+		}
+		err := models.SaveOnCallOverride(ctx, db, models.OnCallOverride{
+			ScheduleID: scheduleID,
+			UserID:     userID,
+			StartsAt:   event.Start,
+			EndsAt:     event.End,
+			UID:        event.UID,
+		})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		imported++
+	}
+	return imported, skipped, errors.Join(errs...)
+}
+
+// icalEventUser finds the user an event puts on call.
+func icalEventUser(event ICalEvent, users []models.User) (int, bool) {
+	for _, email := range event.Attendees {
+		for _, user := range users {
+			if strings.EqualFold(user.Email, email) {
+				return user.ID, true
+			}
+		}
+	}
+	summary := strings.ToLower(event.Summary)
+	for _, user := range users {
+		if user.Email != "" && strings.Contains(summary, strings.ToLower(user.Email)) {
+			return user.ID, true
+		}
+	}
+	for _, user := range users {
+		if user.Name != "" && strings.Contains(summary, strings.ToLower(user.Name)) {
+			return user.ID, true
+		}
+	}
+	return 0, false
+}
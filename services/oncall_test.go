@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCurrentOnCall(t *testing.T) {
+	database := db.InitDB(":memory:")
+	defer database.Close()
+
+	start := time.Date(2026, 10, 5, 9, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	for _, email := range []string{"ana@example.com", "ben@example.com", "cal@example.com"} {
+		assert.NoError(t, models.CreateUser(database, email, strings.TrimSuffix(email, "@example.com"), models.AgentRole, false))
+	}
+	assert.NoError(t, models.CreateOnCallSchedule(database, "Weekly", start, 7))
+	for userID := 1; userID <= 3; userID++ {
+		assert.NoError(t, models.AddOnCallMember(database, 1, userID))
+	}
+	ctx := context.Background()
+	assert.NoError(t, models.SaveOnCallOverride(ctx, database, models.OnCallOverride{ScheduleID: 1, UserID: 3, StartsAt: start.Add(2 * day), EndsAt: start.Add(4 * day)}))
+	assert.NoError(t, models.SaveOnCallOverride(ctx, database, models.OnCallOverride{ScheduleID: 1, UserID: 2, StartsAt: start.Add(3 * day), EndsAt: start.Add(3*day + 12*time.Hour)}))
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		userID   int
+		until    time.Time
+		override bool
+	}{
+		{"before the rotation starts", start.Add(-day), 1, start.Add(7 * day), false},
+		{"first shift", start, 1, start.Add(7 * day), false},
+		{"override", start.Add(2 * day), 3, start.Add(4 * day), true},
+		{"later override wins where they overlap", start.Add(3*day + time.Hour), 2, start.Add(3*day + 12*time.Hour), true},
+		{"back to the rotation once the override ends", start.Add(4 * day), 1, start.Add(7 * day), false},
+		{"last moment of the first shift", start.Add(7*day - time.Second), 1, start.Add(7 * day), false},
+		{"second shift", start.Add(7 * day), 2, start.Add(14 * day), false},
+		{"third shift", start.Add(20 * day), 3, start.Add(21 * day), false},
+		{"rotation wraps around", start.Add(21 * day), 1, start.Add(28 * day), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shift, err := CurrentOnCall(database, 1, tt.now)
+			assert.NoError(t, err)
+			if assert.NotNil(t, shift) {
+				assert.Equal(t, tt.userID, shift.UserID)
+				assert.True(t, tt.until.Equal(shift.Until), "until %s, expected %s", shift.Until, tt.until)
+				assert.Equal(t, tt.override, shift.Override)
+			}
+		})
+	}
+}
+
+func TestCurrentOnCall_DefaultsAndEmptySchedules(t *testing.T) {
+	database := db.InitDB(":memory:")
+	defer database.Close()
+
+	start := time.Date(2026, 10, 5, 9, 0, 0, 0, time.UTC)
+	assert.NoError(t, models.CreateUser(database, "ana@example.com", "ana", models.AgentRole, false))
+	assert.NoError(t, models.CreateUser(database, "ben@example.com", "ben", models.AgentRole, false))
+	assert.NoError(t, models.CreateOnCallSchedule(database, "Empty", start, 1))
+	assert.NoError(t, models.CreateOnCallSchedule(database, "No length", start, 0))
+	assert.NoError(t, models.AddOnCallMember(database, 2, 1))
+	assert.NoError(t, models.AddOnCallMember(database, 2, 2))
+
+	shift, err := CurrentOnCall(database, 1, start)
+	assert.NoError(t, err)
+	assert.Nil(t, shift, "Expected nobody on call for a schedule without members")
+
+	shift, err = CurrentOnCall(database, 2, start.Add(6*24*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, shift.UserID, "Expected shifts to last a week without a rotation length")
+	shift, err = CurrentOnCall(database, 2, start.Add(7*24*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, shift.UserID)
+}
+
+func TestParseICalEvents(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone data is not available")
+	}
+	calendar := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"UID:utc-1",
+		"SUMMARY:On call\\, primary",
+		"ATTENDEE;CN=Ana:MAILTO:Ana@Example.com",
+		"DTSTART:20261005T090000Z",
+		"DTEND:20261012T090000Z",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:zoned-1",
+		"SUMMARY:Weekend cover for ben@exa",
+		" mple.com",
+		"DTSTART;TZID=America/New_York:20261010T080000",
+		"DTEND;TZID=\"America/New_York\":20261012T080000",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:all-day-1",
+		"SUMMARY:Holiday",
+		"DTSTART;VALUE=DATE:20261224",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	events, err := ParseICalEvents(strings.NewReader(calendar))
+	assert.NoError(t, err)
+	if !assert.Len(t, events, 3) {
+		return
+	}
+
+	assert.Equal(t, "utc-1", events[0].UID)
+	assert.Equal(t, "On call, primary", events[0].Summary)
+	assert.Equal(t, []string{"ana@example.com"}, events[0].Attendees)
+	assert.True(t, time.Date(2026, 10, 5, 9, 0, 0, 0, time.UTC).Equal(events[0].Start))
+	assert.True(t, time.Date(2026, 10, 12, 9, 0, 0, 0, time.UTC).Equal(events[0].End))
+
+	assert.Equal(t, "Weekend cover for ben@example.com", events[1].Summary, "Expected folded lines to be joined")
+	assert.True(t, time.Date(2026, 10, 10, 8, 0, 0, 0, newYork).Equal(events[1].Start))
+	assert.True(t, time.Date(2026, 10, 12, 8, 0, 0, 0, newYork).Equal(events[1].End))
+
+	assert.True(t, time.Date(2026, 12, 24, 0, 0, 0, 0, time.Local).Equal(events[2].Start))
+	assert.True(t, events[2].Start.Add(24*time.Hour).Equal(events[2].End), "Expected an all-day event without an end to last a day")
+}
+
+func TestParseICalEvents_Errors(t *testing.T) {
+	for _, calendar := range []string{
+		"BEGIN:VEVENT\nSUMMARY:No start\nEND:VEVENT",
+		"BEGIN:VEVENT\nDTSTART:2026-10-05\nEND:VEVENT",
+	} {
+		_, err := ParseICalEvents(strings.NewReader(calendar))
+		assert.Error(t, err, calendar)
+	}
+}
+
+func TestICalEventUser(t *testing.T) {
+	users := []models.User{
+		{ID: 1, Email: "ana@example.com", Name: "Ana Lee"},
+		{ID: 2, Email: "ben@example.com", Name: "Ben"},
+	}
+	tests := []struct {
+		name   string
+		event  ICalEvent
+		userID int
+		found  bool
+	}{
+		{"attendee", ICalEvent{Attendees: []string{"other@example.com", "BEN@example.com"}, Summary: "Ana Lee"}, 2, true},
+		{"email in the summary", ICalEvent{Summary: "Cover: ana@example.com"}, 1, true},
+		{"name in the summary", ICalEvent{Summary: "ana lee on call"}, 1, true},
+		{"nobody", ICalEvent{Summary: "Team offsite"}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID, found := icalEventUser(tt.event, users)
+			assert.Equal(t, tt.found, found)
+			assert.Equal(t, tt.userID, userID)
+		})
+	}
+}
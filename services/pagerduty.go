@@ -0,0 +1,316 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/nukosuke/go-zendesk/zendesk"
+)
+
+// DefaultPagerDutyEventsURL is the PagerDuty Events API v2 endpoint used when
+// no override is configured.
+const DefaultPagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+const (
+	slaLabelBreached       = "SLA Breached"
+	slaLabelFifteenMinutes = "Less than 15 minutes remaining"
+)
+
+type PagerDutyService struct {
+	DB     db.Database
+	client *http.Client
+}
+
+// PagerDutyEvent is the body of a PagerDuty Events API v2 request.
+type PagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *PagerDutyPayload `json:"payload,omitempty"`
+	Links       []PagerDutyLink   `json:"links,omitempty"`
+}
+
+// PagerDutyPayload describes the incident shown to responders.
+type PagerDutyPayload struct {
+	Summary       string                 `json:"summary"`
+	Source        string                 `json:"source"`
+	Severity      string                 `json:"severity"`
+	Timestamp     string                 `json:"timestamp,omitempty"`
+	CustomDetails map[string]interface{} `json:"custom_details,omitempty"`
+}
+
+// PagerDutyLink attaches a URL to the incident.
+type PagerDutyLink struct {
+	Href string `json:"href"`
+	Text string `json:"text"`
+}
+
+func NewPagerDutyService(db db.Database) *PagerDutyService {
+	return &PagerDutyService{
+		DB:     db,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// shouldPage reports whether an SLA alert at the given stage escalates to PagerDuty.
+func shouldPage(slaLabel string) bool {
+	return slaLabel == slaLabelBreached || slaLabel == slaLabelFifteenMinutes
+}
+
+// pagerDutyDedupKey builds the dedup key shared by the trigger and resolve
+// events for a single ticket and SLA metric.
+func pagerDutyDedupKey(ticketID int64, metric string) string {
+	return fmt.Sprintf("ticketpulse-%d-%s", ticketID, metric)
+}
+
+// config returns the routing key and events URL. An empty routing key means
+// the integration is disabled.
+func (p *PagerDutyService) config() (string, string, error) {
+	routingKey, err := models.GetConfiguration(p.DB, "pagerduty_routing_key")
+	if err != nil {
+		return "", "", err
+	}
+	eventsURL, err := models.GetConfiguration(p.DB, "pagerduty_events_url")
+	if err != nil {
+		return "", "", err
+	}
+	if eventsURL == "" {
+		eventsURL = DefaultPagerDutyEventsURL
+	}
+	return routingKey, eventsURL, nil
+}
+
+// pageSLABreach triggers a PagerDuty incident when an SLA breach rule with
+// paging enabled fires at a paging stage. A ticket's SLA metric is paged once,
+// however many rules and polls see it, until its incident is resolved.
+func (p *PagerDutyService) pageSLABreach(ctx context.Context, alert models.TagAlert, ticket zendesk.Ticket, evaluation RuleEvaluation) error {
+	if !alert.PagerDuty || alert.AlertType != AlertTypeSLABreach || !shouldPage(evaluation.SLALabel) {
+		return nil
+	}
+	open, err := models.PagerDutyIncidentOpen(ctx, p.DB, pagerDutyDedupKey(ticket.ID, evaluation.SLAMetric.Metric), ShadowModeEnabled(p.DB))
+	if err != nil || open {
+		return err
+	}
+
+	// Responders are told who is on call when the rule routes to a schedule
+	onCall := ""
+	if alert.OnCallScheduleID.Valid {
+		notification := Notification{Rule: &alert}
+		if found, err := resolveOnCall(p.DB, &notification); err == nil && found {
+			onCall = notification.Recipient.Email
+		}
+	}
+	return p.TriggerSLABreach(ctx, ticket, evaluation.SLAMetric, evaluation.SLALabel, onCall)
+}
+
+// TriggerSLABreach opens (or re-triggers) a PagerDuty incident for the ticket's SLA metric.
+// onCall is the email of the TicketPulse on-call user the rule resolved to, if any.
+func (p *PagerDutyService) TriggerSLABreach(ctx context.Context, ticket zendesk.Ticket, metric SLAPolicyMetric, slaLabel, onCall string) error {
+	severity := "error"
+	if slaLabel == slaLabelBreached {
+		severity = "critical"
+	}
+
+	dedupKey := pagerDutyDedupKey(ticket.ID, metric.Metric)
+	event := PagerDutyEvent{
+		EventAction: "trigger",
+		DedupKey:    dedupKey,
+		Payload: &PagerDutyPayload{
+			Summary:   fmt.Sprintf("%s: %s on ticket #%d (%s)", slaLabel, metric.Metric, ticket.ID, ticket.Subject),
+			Source:    "TicketPulse",
+			Severity:  severity,
+			Timestamp: time.Now().Format(time.RFC3339),
+			CustomDetails: map[string]interface{}{
+				"ticket_id": ticket.ID,
+				"subject":   ticket.Subject,
+				"priority":  ticket.Priority,
+				"status":    ticket.Status,
+				"metric":    metric.Metric,
+				"breach_at": metric.BreachAt.Format(time.RFC3339),
+				"stage":     slaLabel,
+			},
+		},
+	}
+	if onCall != "" {
+		event.Payload.CustomDetails["on_call"] = onCall
+	}
+
+	incident := models.PagerDutyIncident{TicketID: ticket.ID, Metric: metric.Metric, DedupKey: dedupKey}
+	if ShadowModeEnabled(p.DB) {
+		err := models.CreateShadowAlertLog(ctx, p.DB, models.ShadowAlertLog{
+			AlertType:   AlertTypeSLABreach,
+			Channel:     ChannelPagerDuty,
+			Destination: dedupKey,
+			TicketID:    ticket.ID,
+			SLALabel:    slaLabel,
+			Subject:     event.Payload.Summary,
+		})
+		if err != nil {
+			return err
+		}
+		// The incident is recorded so the page is logged once, not every poll
+		incident.Shadow = true
+		return models.CreatePagerDutyIncident(ctx, p.DB, incident)
+	}
+
+	routingKey, eventsURL, err := p.config()
+	if err != nil {
+		return fmt.Errorf("failed to read PagerDuty configuration: %w", err)
+	}
+	if routingKey == "" {
+		return fmt.Errorf("PagerDuty routing key is not configured")
+	}
+	event.RoutingKey = routingKey
+
+	subdomain, err := models.GetConfiguration(p.DB, "zendesk_subdomain")
+	if err == nil && subdomain != "" {
+		event.Links = []PagerDutyLink{{
+			Href: fmt.Sprintf("https://%s.zendesk.com/agent/tickets/%d", subdomain, ticket.ID),
+			Text: fmt.Sprintf("Zendesk ticket #%d", ticket.ID),
+		}}
+	}
+
+	if err := p.send(ctx, eventsURL, event); err != nil {
+		return err
+	}
+
+	return models.CreatePagerDutyIncident(ctx, p.DB, incident)
+}
+
+// Resolve closes the PagerDuty incident and forgets it locally. Incidents
+// recorded in shadow mode, and any incident while shadow mode is on, are
+// resolved in the shadow log instead.
+func (p *PagerDutyService) Resolve(ctx context.Context, incident models.PagerDutyIncident) error {
+	if incident.Shadow || ShadowModeEnabled(p.DB) {
+		err := models.CreateShadowAlertLog(ctx, p.DB, models.ShadowAlertLog{
+			AlertType:   AlertTypeSLABreach,
+			Channel:     ChannelPagerDuty,
+			Destination: incident.DedupKey,
+			TicketID:    incident.TicketID,
+			Subject:     fmt.Sprintf("Resolved: %s on ticket #%d", incident.Metric, incident.TicketID),
+		})
+		if err != nil {
+			return err
+		}
+		return models.DeletePagerDutyIncident(ctx, p.DB, incident.ID)
+	}
+
+	routingKey, eventsURL, err := p.config()
+	if err != nil {
+		return fmt.Errorf("failed to read PagerDuty configuration: %w", err)
+	}
+	if routingKey == "" {
+		return fmt.Errorf("PagerDuty routing key is not configured")
+	}
+
+	event := PagerDutyEvent{
+		RoutingKey:  routingKey,
+		EventAction: "resolve",
+		DedupKey:    incident.DedupKey,
+	}
+	if err := p.send(ctx, eventsURL, event); err != nil {
+		return err
+	}
+
+	return models.DeletePagerDutyIncident(ctx, p.DB, incident.ID)
+}
+
+// ResolveFinishedIncidents resolves incidents whose ticket has been solved or
+// whose SLA metric is no longer active. Tickets that were not part of this
+// poll are left alone. Polled tickets missing from the SLA search, such as
+// those set to pending once the SLA was met, have their SLA metrics fetched.
+func (p *PagerDutyService) ResolveFinishedIncidents(ctx context.Context, zc *ZendeskClient, tickets []zendesk.Ticket, slaData map[int64]SLAInfo) {
+	incidents, err := models.GetOpenPagerDutyIncidents(ctx, p.DB)
+	if err != nil {
+		log.Printf("Failed to load PagerDuty incidents: %v", err)
+		return
+	}
+	if len(incidents) == 0 {
+		return
+	}
+
+	ticketsByID := make(map[int64]zendesk.Ticket, len(tickets))
+	for _, ticket := range tickets {
+		ticketsByID[ticket.ID] = ticket
+	}
+
+	fetched := make(map[int64]SLAInfo)
+	for _, incident := range incidents {
+		ticket, seen := ticketsByID[incident.TicketID]
+		if !seen {
+			continue
+		}
+		if _, ok := slaData[ticket.ID]; !ok && !ticketSolved(ticket) {
+			if _, ok := fetched[ticket.ID]; !ok {
+				_, slaInfo, err := zc.GetTicketWithSLA(ticket.ID)
+				if err != nil {
+					log.Printf("Failed to retrieve SLA metrics for Ticket #%d: %v", ticket.ID, err)
+					continue
+				}
+				fetched[ticket.ID] = slaInfo
+			}
+		}
+		if !incidentFinished(ticket, incident.Metric, slaData, fetched) {
+			continue
+		}
+		if err := p.Resolve(ctx, incident); err != nil {
+			log.Printf("Failed to resolve PagerDuty incident for Ticket #%d: %v", incident.TicketID, err)
+			continue
+		}
+		log.Printf("Resolved PagerDuty incident for Ticket #%d (%s)", incident.TicketID, incident.Metric)
+	}
+}
+
+// incidentFinished reports whether the ticket is solved or the SLA metric has
+// been met, going by the SLA search results or else the metrics fetched for
+// the ticket.
+func incidentFinished(ticket zendesk.Ticket, metricName string, slaData, fetched map[int64]SLAInfo) bool {
+	if ticketSolved(ticket) {
+		return true
+	}
+
+	slaInfo, ok := slaData[ticket.ID]
+	if !ok {
+		if slaInfo, ok = fetched[ticket.ID]; !ok {
+			return false
+		}
+	}
+	for _, metric := range slaInfo.PolicyMetrics {
+		if metric.Metric == metricName {
+			return metric.Stage == "achieved"
+		}
+	}
+	// The ticket's SLA metrics no longer include this one, so it was fulfilled.
+	return true
+}
+
+func (p *PagerDutyService) send(ctx context.Context, eventsURL string, event PagerDutyEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode PagerDuty event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", eventsURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create PagerDuty request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send PagerDuty event: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("PagerDuty returned status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/nukosuke/go-zendesk/zendesk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchingSLAMetric(t *testing.T) {
+	tests := []struct {
+		name      string
+		remaining time.Duration
+		stage     string
+		label     string
+		matches   bool
+	}{
+		{"well ahead", 5 * time.Hour, "active", "", false},
+		{"three hours", 2*time.Hour + 50*time.Minute, "active", "Less than 3 hours remaining", true},
+		{"two hours", 90 * time.Minute, "active", "Less than 2 hours remaining", true},
+		{"one hour", 45 * time.Minute, "active", "Less than 1 hour remaining", true},
+		{"thirty minutes", 20 * time.Minute, "active", "Less than 30 minutes remaining", true},
+		{"fifteen minutes", 10 * time.Minute, "active", slaLabelFifteenMinutes, true},
+		{"breached", -5 * time.Minute, "active", slaLabelBreached, true},
+		{"achieved", -5 * time.Minute, "achieved", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := []SLAPolicyMetric{{Metric: "first_reply_time", Stage: tt.stage, BreachAt: time.Now().Add(tt.remaining)}}
+			_, label, matches := matchingSLAMetric(metrics)
+			assert.Equal(t, tt.matches, matches)
+			assert.Equal(t, tt.label, label)
+		})
+	}
+}
+
+func TestShouldPage(t *testing.T) {
+	tests := []struct {
+		label string
+		page  bool
+	}{
+		{"Less than 3 hours remaining", false},
+		{"Less than 30 minutes remaining", false},
+		{slaLabelFifteenMinutes, true},
+		{slaLabelBreached, true},
+		{"", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.page, shouldPage(tt.label), "shouldPage(%q)", tt.label)
+	}
+}
+
+func TestPageSLABreach_PagesOnceAcrossStages(t *testing.T) {
+	database := db.InitDB(":memory:")
+	defer database.Close()
+
+	var triggers int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event PagerDutyEvent
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		if event.EventAction == "trigger" {
+			triggers++
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	assert.NoError(t, models.SetConfiguration(database, "pagerduty_routing_key", "routing-key"))
+	assert.NoError(t, models.SetConfiguration(database, "pagerduty_events_url", server.URL))
+
+	pagerDutyService := NewPagerDutyService(database)
+	rule := models.TagAlert{ID: 1, AlertType: AlertTypeSLABreach, PagerDuty: true, TeamID: sql.NullInt64{Int64: 1, Valid: true}}
+	ticket := zendesk.Ticket{ID: 42, Subject: "Checkout is down"}
+
+	// The same ticket is polled as its SLA approaches and then passes its breach time
+	for _, remaining := range []time.Duration{2*time.Hour + 50*time.Minute, 10 * time.Minute, 5 * time.Minute, -5 * time.Minute} {
+		slaData := map[int64]SLAInfo{ticket.ID: {PolicyMetrics: []SLAPolicyMetric{
+			{Metric: "first_reply_time", Stage: "active", BreachAt: time.Now().Add(remaining)},
+		}}}
+		evaluation := evaluateAlertType(rule.AlertType, ticket, slaData)
+		assert.True(t, evaluation.Fires)
+		assert.NoError(t, pagerDutyService.pageSLABreach(context.Background(), rule, ticket, evaluation))
+	}
+
+	assert.Equal(t, 1, triggers, "Expected exactly one PagerDuty trigger")
+	open, err := models.PagerDutyIncidentOpen(context.Background(), database, pagerDutyDedupKey(ticket.ID, "first_reply_time"), false)
+	assert.NoError(t, err)
+	assert.True(t, open)
+}
+
+func TestPageSLABreach_SkipsRulesWithoutPaging(t *testing.T) {
+	database := db.InitDB(":memory:")
+	defer database.Close()
+
+	var triggers int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		triggers++
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	assert.NoError(t, models.SetConfiguration(database, "pagerduty_routing_key", "routing-key"))
+	assert.NoError(t, models.SetConfiguration(database, "pagerduty_events_url", server.URL))
+
+	pagerDutyService := NewPagerDutyService(database)
+	ticket := zendesk.Ticket{ID: 42}
+	slaData := map[int64]SLAInfo{ticket.ID: {PolicyMetrics: []SLAPolicyMetric{
+		{Metric: "first_reply_time", Stage: "active", BreachAt: time.Now().Add(-time.Minute)},
+	}}}
+	evaluation := evaluateAlertType(AlertTypeSLABreach, ticket, slaData)
+
+	for _, rule := range []models.TagAlert{
+		{ID: 1, AlertType: AlertTypeSLABreach},
+		{ID: 2, AlertType: AlertTypeSLARisk, PagerDuty: true},
+	} {
+		assert.NoError(t, pagerDutyService.pageSLABreach(context.Background(), rule, ticket, evaluation))
+	}
+	assert.Equal(t, 0, triggers)
+}
+
+func TestPageSLABreach_ShadowModeLogsOnce(t *testing.T) {
+	database := db.InitDB(":memory:")
+	defer database.Close()
+
+	var events []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event PagerDutyEvent
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		events = append(events, event.EventAction)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	assert.NoError(t, models.SetConfiguration(database, "pagerduty_routing_key", "routing-key"))
+	assert.NoError(t, models.SetConfiguration(database, "pagerduty_events_url", server.URL))
+	assert.NoError(t, models.SetConfiguration(database, "shadow_mode", "on"))
+
+	ctx := context.Background()
+	pagerDutyService := NewPagerDutyService(database)
+	rule := models.TagAlert{ID: 1, AlertType: AlertTypeSLABreach, PagerDuty: true}
+	ticket := zendesk.Ticket{ID: 42, Status: "open"}
+	slaData := map[int64]SLAInfo{ticket.ID: {PolicyMetrics: []SLAPolicyMetric{
+		{Metric: "first_reply_time", Stage: "active", BreachAt: time.Now().Add(-time.Minute)},
+	}}}
+	evaluation := evaluateAlertType(rule.AlertType, ticket, slaData)
+
+	// Three polls see the breach, then the SLA is met
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, pagerDutyService.pageSLABreach(ctx, rule, ticket, evaluation))
+	}
+	slaData[ticket.ID].PolicyMetrics[0].Stage = "achieved"
+	pagerDutyService.ResolveFinishedIncidents(ctx, nil, []zendesk.Ticket{ticket}, slaData)
+
+	assert.Empty(t, events, "Expected nothing to be sent to PagerDuty in shadow mode")
+	logs, err := models.GetRecentShadowAlertLogs(database, 10)
+	assert.NoError(t, err)
+	assert.Len(t, logs, 2, "Expected one logged trigger and one logged resolve")
+
+	// Once shadow mode is off the breach pages for real
+	assert.NoError(t, models.SetConfiguration(database, "shadow_mode", "off"))
+	slaData[ticket.ID].PolicyMetrics[0].Stage = "active"
+	assert.NoError(t, pagerDutyService.pageSLABreach(ctx, rule, ticket, evaluation))
+	assert.Equal(t, []string{"trigger"}, events)
+}
+
+func TestPageSLABreach_RealTriggerReplacesShadowIncident(t *testing.T) {
+	database := db.InitDB(":memory:")
+	defer database.Close()
+
+	ctx := context.Background()
+	dedupKey := pagerDutyDedupKey(42, "first_reply_time")
+	assert.NoError(t, models.CreatePagerDutyIncident(ctx, database, models.PagerDutyIncident{TicketID: 42, Metric: "first_reply_time", DedupKey: dedupKey, Shadow: true}))
+
+	open, err := models.PagerDutyIncidentOpen(ctx, database, dedupKey, false)
+	assert.NoError(t, err)
+	assert.False(t, open, "Expected a shadow incident not to block a real page")
+
+	assert.NoError(t, models.CreatePagerDutyIncident(ctx, database, models.PagerDutyIncident{TicketID: 42, Metric: "first_reply_time", DedupKey: dedupKey}))
+	open, err = models.PagerDutyIncidentOpen(ctx, database, dedupKey, false)
+	assert.NoError(t, err)
+	assert.True(t, open)
+}
+
+func TestIncidentFinished(t *testing.T) {
+	metrics := func(stage string) map[int64]SLAInfo {
+		return map[int64]SLAInfo{42: {PolicyMetrics: []SLAPolicyMetric{{Metric: "first_reply_time", Stage: stage}}}}
+	}
+	tests := []struct {
+		name     string
+		status   string
+		slaData  map[int64]SLAInfo
+		fetched  map[int64]SLAInfo
+		finished bool
+	}{
+		{"solved", "solved", nil, nil, true},
+		{"closed", "closed", nil, nil, true},
+		{"still breaching", "open", metrics("active"), nil, false},
+		{"met while open", "open", metrics("achieved"), nil, true},
+		{"metric no longer listed", "open", map[int64]SLAInfo{42: {PolicyMetrics: []SLAPolicyMetric{{Metric: "next_reply_time", Stage: "active"}}}}, nil, true},
+		{"pending after the reply", "pending", nil, metrics("achieved"), true},
+		{"on hold, still breaching", "hold", nil, metrics("active"), false},
+		{"SLA metrics unknown", "pending", nil, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ticket := zendesk.Ticket{ID: 42, Status: tt.status}
+			assert.Equal(t, tt.finished, incidentFinished(ticket, "first_reply_time", tt.slaData, tt.fetched))
+		})
+	}
+}
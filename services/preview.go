@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/nukosuke/go-zendesk/zendesk"
+)
+
+// previewHistoryWindow is how far back rule previews replay ticket activity.
+const previewHistoryWindow = 7 * 24 * time.Hour
+
+// PreviewTicket is an open ticket matched by a previewed rule.
+type PreviewTicket struct {
+	ID       int64    `json:"id"`
+	Subject  string   `json:"subject"`
+	Status   string   `json:"status"`
+	Priority string   `json:"priority"`
+	URL      string   `json:"url,omitempty"`
+	Firing   []string `json:"firing"` // Alert types whose condition holds right now
+	SLALabel string   `json:"sla_label,omitempty"`
+}
+
+// RulePreview describes what a rule would do if it were saved, without sending anything.
+type RulePreview struct {
+	Tag       string          `json:"tag"`
+	Criteria  string          `json:"criteria"` // Describes the tickets the rule is about
+	AlertType string          `json:"alert_type"`
+	Tickets   []PreviewTicket `json:"tickets"`
+	// FiringNow counts open tickets the rule's own alert type would alert on right now.
+	FiringNow int `json:"firing_now"`
+	// HistoryCount is how many alerts the rule would have sent over the history window.
+	HistoryCount  int            `json:"history_count"`
+	HistoryByType map[string]int `json:"history_by_type"`
+	HistoryDays   int            `json:"history_days"`
+	// HistoryUnavailable is set for alert types that ticket activity does not
+	// record, such as age and SLA risk rules, whose history cannot be replayed.
+	HistoryUnavailable bool `json:"history_unavailable"`
+}
+
+// PreviewRule evaluates a rule against the currently open tickets carrying its
+// tag and replays it against recent ticket activity.
+func (zc *ZendeskClient) PreviewRule(alert models.TagAlert) (RulePreview, error) {
+	preview := RulePreview{
+		Tag:           alert.Tag,
+		Criteria:      ruleCriteria(alert),
+		AlertType:     alert.AlertType,
+		Tickets:       []PreviewTicket{},
+		HistoryByType: make(map[string]int),
+		HistoryDays:   int(previewHistoryWindow.Hours() / 24),
+	}
+
+	if err := LoadTagAliases(zc.DB); err != nil {
+		log.Println("Error loading tag aliases:", err)
+	}
+
+	// Age rules need each ticket's metric dates to tell how long it has been in their state
+	var tickets []zendesk.Ticket
+	var slaData map[int64]SLAInfo
+	var dates map[int64]TicketDates
+	var err error
+	if IsAgeAlertType(alert.AlertType) {
+		preview.HistoryUnavailable = true
+		tickets, slaData, dates, err = zc.SearchTicketsWithDates("type:ticket status<solved" + ruleSearchFilters(alert))
+	} else {
+		tickets, slaData, err = zc.SearchOpenTicketsForRule(alert)
+	}
+	if err != nil {
+		return preview, fmt.Errorf("failed to search open tickets: %w", err)
+	}
+
+	// SLA risk rules are previewed against the same history polling predicts from
+	var risks *SLARiskPredictor
+	if alert.AlertType == AlertTypeSLARisk {
+		preview.HistoryUnavailable = true
+		risks = NewSLARiskPredictor(zc.DB)
+	}
+
+	customers := NewCustomerResolver(zc.DB, zc)
+	for _, ticket := range tickets {
+		if !ruleMatches(alert, ticket.Tags, ticket.OrganizationID, ticket.RequesterID, customers) {
+			continue
+		}
+		if _, ok := keywordMatches(alert, ticket, nil); !ok {
+			continue
+		}
+		previewTicket := PreviewTicket{
+			ID:       ticket.ID,
+			Subject:  ticket.Subject,
+			Status:   ticket.Status,
+			Priority: ticket.Priority,
+			URL:      ticketURL(zc.DB, ticket.ID),
+			Firing:   []string{},
+		}
+		for _, alertType := range []string{AlertTypeNewTicket, AlertTypeTicketUpdate, AlertTypeSLABreach} {
+			evaluation := evaluateAlertType(alertType, ticket, slaData)
+			if !evaluation.Fires {
+				continue
+			}
+			previewTicket.Firing = append(previewTicket.Firing, alertType)
+			if alertType == AlertTypeSLABreach {
+				previewTicket.SLALabel = evaluation.SLALabel
+			}
+			if alertType == alert.AlertType {
+				preview.FiringNow++
+			}
+		}
+		if risks != nil {
+			if evaluation := evaluateSLARisk(ticket, slaData[ticket.ID], risks); evaluation.Fires {
+				previewTicket.Firing = append(previewTicket.Firing, AlertTypeSLARisk)
+				previewTicket.SLALabel = evaluation.SLALabel
+				preview.FiringNow++
+			}
+		}
+		if IsAgeAlertType(alert.AlertType) && zc.agePreviewFires(alert, ticket, dates[ticket.ID]) {
+			previewTicket.Firing = append(previewTicket.Firing, alert.AlertType)
+			preview.FiringNow++
+		}
+		preview.Tickets = append(preview.Tickets, previewTicket)
+	}
+
+	// Ticket activity does not keep ticket content, so history ignores keywords
+	activity, err := models.GetTicketActivitySince(zc.DB, time.Now().Add(-previewHistoryWindow))
+	if err != nil {
+		return preview, fmt.Errorf("failed to load ticket activity: %w", err)
+	}
+	for _, event := range activity {
+		if !ruleMatches(alert, event.TagList(), event.OrganizationID, event.RequesterID, customers) {
+			continue
+		}
+		preview.HistoryByType[event.AlertType]++
+		if event.AlertType == alert.AlertType {
+			preview.HistoryCount++
+		}
+	}
+
+	return preview, nil
+}
+
+// agePreviewFires reports whether an age rule would alert on the ticket right
+// now, checking the ticket's comments for an agent reply as polling does.
+func (zc *ZendeskClient) agePreviewFires(alert models.TagAlert, ticket zendesk.Ticket, dates TicketDates) bool {
+	since, ok := ageRuleFires(alert, ticket, dates, time.Now())
+	if !ok {
+		return false
+	}
+	if alert.AlertType == AlertTypeNoAgentReply {
+		repliedAt, err := zc.LatestPublicAgentReply(ticket.ID)
+		if err != nil {
+			log.Printf("Failed to retrieve comments for Ticket #%d: %v", ticket.ID, err)
+			return false
+		}
+		return !repliedAt.After(since)
+	}
+	return true
+}
+
+// ruleCriteria describes which tickets a rule matches, such as
+// "tagged billing from Acme from VIP customers mentioning "outage"".
+func ruleCriteria(alert models.TagAlert) string {
+	var parts []string
+	if alert.Tag != "" {
+		parts = append(parts, "tagged "+alert.Tag)
+	}
+	if organization := strings.TrimSpace(alert.Organization); organization != "" {
+		parts = append(parts, "from "+organization)
+	}
+	if alert.VIPOnly {
+		parts = append(parts, "from VIP customers")
+	}
+	if keywords := alert.KeywordList(); len(keywords) > 0 {
+		quoted := make([]string, len(keywords))
+		for i, keyword := range keywords {
+			quoted[i] = fmt.Sprintf("%q", keyword)
+		}
+		parts = append(parts, "mentioning "+strings.Join(quoted, " or "))
+	}
+	if len(parts) == 0 {
+		return "any ticket"
+	}
+	return strings.Join(parts, " ")
+}
+
+// recordTicketActivity stores every event in the polled tickets that would
+// fire a rule of some alert type, so that previews can replay new rules
+// against recent history. Events older than the preview window are pruned.
+func recordTicketActivity(ctx context.Context, db db.Database, tickets []zendesk.Ticket, slaData map[int64]SLAInfo, changes map[int64]TicketChange) {
+	for _, ticket := range tickets {
+		recordTicketTransitions(ctx, db, ticket, changes[ticket.ID])
+
+		for _, alertType := range []string{AlertTypeNewTicket, AlertTypeTicketUpdate, AlertTypeSLABreach} {
+			evaluation := evaluateAlertType(alertType, ticket, slaData)
+			if !evaluation.Fires {
+				continue
+			}
+
+			activity := models.TicketActivity{
+				TicketID:       ticket.ID,
+				Tags:           strings.Join(ticket.Tags, " "),
+				AlertType:      alertType,
+				OrganizationID: ticket.OrganizationID,
+				RequesterID:    ticket.RequesterID,
+			}
+			switch alertType {
+			case AlertTypeNewTicket:
+				activity.EventAt = *ticket.CreatedAt
+			case AlertTypeTicketUpdate:
+				activity.EventAt = *ticket.UpdatedAt
+			case AlertTypeSLABreach:
+				// SLA alerts are sent once per breach time, matching the SLA alert cache
+				activity.EventAt = slaData[ticket.ID].PolicyMetrics[0].BreachAt
+				activity.SLALabel = sql.NullString{String: evaluation.SLALabel, Valid: true}
+			}
+
+			if err := models.RecordTicketActivity(ctx, db, activity); err != nil {
+				log.Printf("Failed to record activity for Ticket #%d: %v", ticket.ID, err)
+			}
+		}
+	}
+
+	if err := models.PruneTicketActivity(ctx, db, time.Now().Add(-previewHistoryWindow)); err != nil {
+		log.Println(err)
+	}
+}
+
+// recordTicketTransitions stores the ticket's changes since the previous poll.
+// Tag added events record only the added tags, so replayed rules match the
+// tag that was added rather than every tag on the ticket. Group moves are
+// replayed regardless of the rule's group.
+func recordTicketTransitions(ctx context.Context, db db.Database, ticket zendesk.Ticket, change TicketChange) {
+	if ticket.UpdatedAt == nil {
+		return
+	}
+	for _, alertType := range transitionAlertTypes {
+		if !change.Fires(alertType) {
+			continue
+		}
+
+		activity := models.TicketActivity{
+			TicketID:       ticket.ID,
+			Tags:           strings.Join(ticket.Tags, " "),
+			AlertType:      alertType,
+			OrganizationID: ticket.OrganizationID,
+			RequesterID:    ticket.RequesterID,
+			EventAt:        *ticket.UpdatedAt,
+		}
+		if alertType == AlertTypeTagAdded {
+			activity.Tags = strings.Join(change.AddedTags, " ")
+		}
+		if err := models.RecordTicketActivity(ctx, db, activity); err != nil {
+			log.Printf("Failed to record activity for Ticket #%d: %v", ticket.ID, err)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/nukosuke/go-zendesk/zendesk"
+)
+
+// applyQuietHours decides what happens to a personal notification when the
+// recipient is in quiet hours. It returns the notification to deliver, which
+// may be addressed to the recipient's backup, and false when the notification
+// was held or dropped instead. Summaries are always delivered because their
+// time is chosen by the recipient.
+func (s *NotificationService) applyQuietHours(ctx context.Context, notifier Notifier, n Notification) (Notification, bool, error) {
+	if !notifier.Personal() || n.Ticket == nil || n.Recipient.ID == 0 {
+		return n, true, nil
+	}
+
+	quiet, err := models.GetQuietHours(s.DB, n.Recipient.ID)
+	if err != nil {
+		return n, false, err
+	}
+	now := time.Now()
+	if !quiet.Active(now) {
+		return n, true, nil
+	}
+	if quiet.AllowBreaches && n.AlertType == AlertTypeSLABreach {
+		return n, true, nil
+	}
+
+	switch quiet.Action {
+	case models.QuietHoursDrop:
+		return n, false, nil
+	case models.QuietHoursReroute:
+		if quiet.BackupUserID.Valid {
+			backup, err := models.GetUserByID(s.DB, int(quiet.BackupUserID.Int64))
+			if err != nil {
+				return n, false, fmt.Errorf("failed to load backup user %d: %w", quiet.BackupUserID.Int64, err)
+			}
+			backupQuiet, err := models.GetQuietHours(s.DB, backup.ID)
+			if err != nil {
+				return n, false, err
+			}
+			// A backup who is also in quiet hours is not paged; the alert is
+			// held for the original recipient instead.
+			if !backupQuiet.Active(now) {
+				n.Recipient = backup
+				return n, true, nil
+			}
+		}
+	}
+	return n, false, s.holdNotification(ctx, notifier, n)
+}
+
+// holdNotification stores the notification until the recipient's quiet hours end.
+func (s *NotificationService) holdNotification(ctx context.Context, notifier Notifier, n Notification) error {
+	tag := ""
+	if n.Rule != nil {
+		tag = n.Rule.Tag
+	}
+	return models.HoldNotification(ctx, s.DB, models.HeldNotification{
+		UserID:         n.Recipient.ID,
+		Channel:        notifier.Channel(),
+		AlertType:      n.AlertType,
+		TicketID:       n.Ticket.ID,
+		Subject:        n.Ticket.Subject,
+		Status:         n.Ticket.Status,
+		Priority:       n.Ticket.Priority,
+		RequesterID:    n.Ticket.RequesterID,
+		OrganizationID: n.Ticket.OrganizationID,
+		Tag:            tag,
+		SLALabel:       n.SLALabel,
+	})
+}
+
+// ReleaseHeldNotifications delivers held notifications whose recipients are
+// no longer in quiet hours.
+func (s *NotificationService) ReleaseHeldNotifications(ctx context.Context) error {
+	held, err := models.GetHeldNotifications(s.DB)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	shadow := ShadowModeEnabled(s.DB)
+	quietByUser := make(map[int]bool)
+	var errs []error
+	for _, h := range held {
+		quiet, ok := quietByUser[h.UserID]
+		if !ok {
+			hours, err := models.GetQuietHours(s.DB, h.UserID)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			quiet = hours.Active(now)
+			quietByUser[h.UserID] = quiet
+		}
+		if quiet {
+			continue
+		}
+
+		if err := s.releaseHeldNotification(ctx, h, shadow); err != nil {
+			errs = append(errs, fmt.Errorf("held notification %d: %w", h.ID, err))
+			continue
+		}
+		if err := models.DeleteHeldNotification(ctx, s.DB, h.ID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *NotificationService) releaseHeldNotification(ctx context.Context, h models.HeldNotification, shadow bool) error {
+	recipient, err := models.GetUserByID(s.DB, h.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to load recipient %d: %w", h.UserID, err)
+	}
+
+	n := Notification{
+		AlertType: h.AlertType,
+		Recipient: recipient,
+		Rule:      &models.TagAlert{Tag: h.Tag},
+		Ticket: &zendesk.Ticket{
+			ID:             h.TicketID,
+			Subject:        h.Subject,
+			Status:         h.Status,
+			Priority:       h.Priority,
+			RequesterID:    h.RequesterID,
+			OrganizationID: h.OrganizationID,
+		},
+		SLALabel: h.SLALabel,
+	}
+
+	for _, notifier := range s.registry.Notifiers() {
+		if notifier.Channel() == h.Channel {
+			log.Printf("Releasing alert for ticket #%d held during quiet hours for user %d", h.TicketID, h.UserID)
+			return s.deliver(ctx, notifier, n, shadow)
+		}
+	}
+	return fmt.Errorf("no notifier is registered for the channel")
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/middlewares"
+	"github.com/TylerConlee/TicketPulse/models"
+)
+
+// defaultRateLimitWindow is used when rate_limit_window is not configured.
+const defaultRateLimitWindow = 10 * time.Minute
+
+// TextNotifier is implemented by shared notifiers that can post a plain text
+// message, which is used for rate limit rollups.
+type TextNotifier interface {
+	NotifyText(ctx context.Context, destination, text string) error
+}
+
+// RateLimiter counts deliveries per key in fixed windows.
+type RateLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*rateWindow
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{windows: make(map[string]*rateWindow)}
+}
+
+// RateLimit caps the deliveries counted under a key. A limit of zero or less
+// never limits.
+type RateLimit struct {
+	Key   string
+	Limit int
+}
+
+// Allow records a delivery for the key and reports whether it is within the
+// limit for the current window. Rejected deliveries are not counted.
+func (l *RateLimiter) Allow(key string, limit int, window time.Duration, now time.Time) bool {
+	return l.AllowAll([]RateLimit{{Key: key, Limit: limit}}, window, now)
+}
+
+// AllowAll reports whether a delivery is within every limit for the current
+// window, and only then records it against each of them, so a delivery one
+// limit rejects does not use up the others.
+func (l *RateLimiter) AllowAll(limits []RateLimit, window time.Duration, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var windows []*rateWindow
+	for _, limit := range limits {
+		if limit.Limit <= 0 {
+			continue
+		}
+		w, ok := l.windows[limit.Key]
+		if !ok || now.Sub(w.start) >= window {
+			w = &rateWindow{start: now}
+			l.windows[limit.Key] = w
+		}
+		if w.count >= limit.Limit {
+			return false
+		}
+		windows = append(windows, w)
+	}
+	for _, w := range windows {
+		w.count++
+	}
+	return true
+}
+
+// rollup counts alerts for a rule that were suppressed by a rate limit.
+type rollup struct {
+	channel     string
+	destination string
+	rule        string
+	count       int
+	started     time.Time
+	due         time.Time
+}
+
+// channelRateLimit returns the configured per-channel limit and the window shared by every limit.
+func (s *NotificationService) channelRateLimit() (int, time.Duration) {
+	limit := 0
+	if value, err := models.GetConfiguration(s.DB, "rate_limit_channel"); err == nil && value != "" {
+		limit, _ = strconv.Atoi(value)
+	}
+	window := defaultRateLimitWindow
+	if value, err := models.GetConfiguration(s.DB, "rate_limit_window"); err == nil && value != "" {
+		if minutes, err := strconv.Atoi(value); err == nil && minutes > 0 {
+			window = time.Duration(minutes) * time.Minute
+		}
+	}
+	return limit, window
+}
+
+// ruleLabel describes a rule in rollup messages.
+func ruleLabel(rule *models.TagAlert) string {
+	return fmt.Sprintf("%s (%s)", rule.Tag, rule.AlertType)
+}
+
+// allowDelivery applies the per-rule and per-channel rate limits to a post to
+// a shared channel. A rule's limit applies to each channel it posts to
+// separately. Suppressed alerts are counted towards a rollup message.
+func (s *NotificationService) allowDelivery(notifier Notifier, n Notification) bool {
+	if notifier.Personal() || n.Rule == nil {
+		return true
+	}
+	if _, ok := notifier.(TextNotifier); !ok {
+		return true
+	}
+
+	destination := notifier.Destination(n)
+	channelLimit, window := s.channelRateLimit()
+	now := time.Now()
+
+	limits := []RateLimit{
+		{Key: fmt.Sprintf("rule:%d:%s:%s", n.Rule.ID, notifier.Channel(), destination), Limit: n.Rule.RateLimit},
+		{Key: fmt.Sprintf("channel:%s:%s", notifier.Channel(), destination), Limit: channelLimit},
+	}
+	if s.limiter.AllowAll(limits, window, now) {
+		return true
+	}
+
+	s.rollupsMu.Lock()
+	defer s.rollupsMu.Unlock()
+
+	key := fmt.Sprintf("%s:%s:%d", notifier.Channel(), destination, n.Rule.ID)
+	r, ok := s.rollups[key]
+	if !ok {
+		r = &rollup{channel: notifier.Channel(), destination: destination, rule: ruleLabel(n.Rule), started: now, due: now.Add(window)}
+		s.rollups[key] = r
+		middlewares.AddAdminNotification(s.sseServer, "Alert rate limit reached",
+			fmt.Sprintf("Alerts for %s to %s are being rolled up", r.rule, destination), "warning")
+	}
+	r.count++
+	return false
+}
+
+// FlushRollups posts a rollup message for every rate limited rule whose window has ended.
+func (s *NotificationService) FlushRollups(ctx context.Context) error {
+	now := time.Now()
+
+	s.rollupsMu.Lock()
+	var due []*rollup
+	for key, r := range s.rollups {
+		if now.Before(r.due) {
+			continue
+		}
+		due = append(due, r)
+		delete(s.rollups, key)
+	}
+	s.rollupsMu.Unlock()
+
+	shadow := ShadowModeEnabled(s.DB)
+	var errs []error
+	for _, r := range due {
+		text := fmt.Sprintf("%d more tickets matched %s in the last %d minutes", r.count, r.rule, int(r.due.Sub(r.started).Minutes()))
+		if err := s.sendText(ctx, "rollup", r.channel, r.destination, text, shadow); err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", r.channel, r.destination, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sendText posts a plain text message over a channel, or logs it instead in
+// shadow mode.
+func (s *NotificationService) sendText(ctx context.Context, alertType, channel, destination, text string, shadow bool) error {
+	if shadow {
+		return models.CreateShadowAlertLog(ctx, s.DB, models.ShadowAlertLog{
+			AlertType:   alertType,
+			Channel:     channel,
+			Destination: destination,
+			Subject:     text,
+		})
+	}
+
+	for _, notifier := range s.registry.Notifiers() {
+		if notifier.Channel() != channel {
+			continue
+		}
+		textNotifier, ok := notifier.(TextNotifier)
+		if !ok {
+			return fmt.Errorf("notifier does not support text messages")
+		}
+		return textNotifier.NotifyText(ctx, destination, text)
+	}
+	return fmt.Errorf("no notifier is registered for the channel")
+}
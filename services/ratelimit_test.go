@@ -0,0 +1,47 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	start := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		limit   int
+		times   []time.Duration // Offsets from start of each delivery
+		allowed []bool
+	}{
+		{"no limit", 0, []time.Duration{0, 0, 0}, []bool{true, true, true}},
+		{"within limit", 2, []time.Duration{0, time.Minute}, []bool{true, true}},
+		{"over limit", 2, []time.Duration{0, time.Minute, 2 * time.Minute}, []bool{true, true, false}},
+		{"new window", 1, []time.Duration{0, time.Minute, 10 * time.Minute}, []bool{true, false, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewRateLimiter()
+			for i, offset := range tt.times {
+				assert.Equal(t, tt.allowed[i], limiter.Allow("rule:1", tt.limit, 10*time.Minute, start.Add(offset)), "delivery %d", i)
+			}
+		})
+	}
+}
+
+func TestRateLimiterAllowAll_RejectedDeliveriesAreNotCounted(t *testing.T) {
+	limiter := NewRateLimiter()
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	window := 10 * time.Minute
+
+	// The channel allows one post per window, so the rule's second alert is
+	// rejected by the channel and must not use up the rule's own limit of two
+	assert.True(t, limiter.AllowAll([]RateLimit{{"rule:1:slack_channel:C1", 2}, {"channel:slack_channel:C1", 1}}, window, now))
+	assert.False(t, limiter.AllowAll([]RateLimit{{"rule:1:slack_channel:C1", 2}, {"channel:slack_channel:C1", 1}}, window, now))
+	assert.True(t, limiter.Allow("rule:1:slack_channel:C1", 2, window, now), "Expected the rule to have one delivery left")
+	assert.False(t, limiter.Allow("rule:1:slack_channel:C1", 2, window, now))
+
+	// Another destination has its own rule budget
+	assert.True(t, limiter.AllowAll([]RateLimit{{"rule:1:slack_channel:C2", 2}, {"channel:slack_channel:C2", 1}}, window, now))
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"context"
+
+	"github.com/TylerConlee/TicketPulse/db"
+	"github.com/TylerConlee/TicketPulse/models"
+)
+
+// ChannelPagerDuty identifies PagerDuty incidents in the shadow log.
+const ChannelPagerDuty = "pagerduty"
+
+// ShadowModeEnabled reports whether the instance is in shadow mode. In shadow
+// mode tickets are polled and rules evaluated as usual, but notifications are
+// written to the shadow log instead of being sent.
+func ShadowModeEnabled(db db.Database) bool {
+	value, err := models.GetConfiguration(db, "shadow_mode")
+	return err == nil && value == "on"
+}
+
+// recordShadowAlert writes a notification that would have been delivered over the channel to the shadow log.
+func recordShadowAlert(ctx context.Context, db db.Database, channel, destination string, n Notification) error {
+	logEntry := models.ShadowAlertLog{
+		AlertType:   n.AlertType,
+		Channel:     channel,
+		Destination: destination,
+		UserID:      int64(n.Recipient.ID),
+		SLALabel:    n.SLALabel,
+		Subject:     notificationSubject(n),
+	}
+	if n.Rule != nil {
+		logEntry.RuleID = int64(n.Rule.ID)
+		logEntry.TeamID = n.Rule.TeamID.Int64
+		logEntry.Tag = alertLogTag(*n.Rule, n.Ticket)
+	}
+	if n.Ticket != nil {
+		logEntry.TicketID = n.Ticket.ID
+	}
+	return models.CreateShadowAlertLog(ctx, db, logEntry)
+}
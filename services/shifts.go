@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/nukosuke/go-zendesk/zendesk"
+)
+
+// AlertTypeShiftHandoff is the alert type used when posting shift handoff reports.
+const AlertTypeShiftHandoff = "shift_handoff"
+
+// shiftHandoffGrace is how long after a shift ends its handoff report is
+// still posted, for example when TicketPulse was down as the shift ended.
+const shiftHandoffGrace = time.Hour
+
+// maxHandoffTickets is how many tickets each section of a handoff report
+// lists before summarizing the rest.
+const maxHandoffTickets = 15
+
+// BreachingTicket is a ticket with an SLA metric due before the next shift ends.
+type BreachingTicket struct {
+	Ticket zendesk.Ticket
+	Metric SLAPolicyMetric
+}
+
+// ShiftHandoff is what a team's shift hands over to the shift after it.
+type ShiftHandoff struct {
+	Shift          models.Shift
+	Next           models.Shift
+	StartedAt      time.Time
+	EndedAt        time.Time
+	NextEndsAt     time.Time
+	Breaching      []BreachingTicket
+	Unacknowledged []models.AlertLog
+	OnHold         []zendesk.Ticket // Waiting on an internal reply
+	Reopened       []models.TicketActivity
+	Subjects       map[int64]string // Subjects of the tickets above, where known
+}
+
+// processShiftHandoffs posts a handoff report to the Slack channel of every
+// shift that has ended since its last report.
+func processShiftHandoffs(ctx context.Context, db db.Database, zc *ZendeskClient, notificationService *NotificationService) {
+	shifts, err := models.GetAllShifts(db)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	now := time.Now()
+	teamShifts := make(map[int][]models.Shift)
+	type endedShift struct {
+		shift      models.Shift
+		start, end time.Time
+	}
+	var ended []endedShift
+	for _, shift := range shifts {
+		teamShifts[shift.TeamID] = append(teamShifts[shift.TeamID], shift)
+		start, end, ok := shift.LastEnded(now)
+		if !ok || end.Before(shift.CreatedAt) {
+			continue
+		}
+		if shift.LastReportAt.Valid && !shift.LastReportAt.Time.Before(end) {
+			continue
+		}
+		ended = append(ended, endedShift{shift, start, end})
+	}
+	if len(ended) == 0 {
+		return
+	}
+
+	slaTickets, slaData, err := zc.SearchTicketsWithActiveSLA()
+	if err != nil {
+		log.Println("Error searching SLA tickets for shift handoffs:", err)
+		return
+	}
+	onHold, _, err := zc.searchTicketsWithSLA("type:ticket status:hold")
+	if err != nil {
+		log.Println("Error searching on-hold tickets for shift handoffs:", err)
+		return
+	}
+	shadowMode := ShadowModeEnabled(db)
+	customers := NewCustomerResolver(db, zc)
+
+	for _, e := range ended {
+		if now.Sub(e.end) > shiftHandoffGrace {
+			log.Printf("Shift %s ended at %s, too long ago to post its handoff report", e.shift.Name, e.end.Format("Jan 2 15:04"))
+		} else {
+			handoff, err := buildShiftHandoff(db, e.shift, e.start, e.end, teamShifts[e.shift.TeamID], slaTickets, slaData, onHold, customers)
+			if err != nil {
+				log.Printf("Failed to build handoff report for shift %s: %v", e.shift.Name, err)
+				continue
+			}
+			if err := notificationService.sendText(ctx, AlertTypeShiftHandoff, ChannelSlackChannel, e.shift.SlackChannelID, handoff.Text(zc.Subdomain), shadowMode); err != nil {
+				log.Printf("Failed to post handoff report for shift %s: %v", e.shift.Name, err)
+				continue
+			}
+		}
+		if err := models.MarkShiftReported(ctx, db, e.shift.ID, e.end); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// buildShiftHandoff gathers the report for a shift that ran from start to
+// end. Tickets are limited to those matching the team's rules, or every
+// ticket when the team has none.
+func buildShiftHandoff(db db.Database, shift models.Shift, start, end time.Time, teamShifts []models.Shift, slaTickets []zendesk.Ticket, slaData map[int64]SLAInfo, onHold []zendesk.Ticket, customers *CustomerResolver) (*ShiftHandoff, error) {
+	handoff := &ShiftHandoff{Shift: shift, StartedAt: start, EndedAt: end, Subjects: make(map[int64]string)}
+
+	// The next shift is whichever of the team's shifts ends first after this one
+	for _, candidate := range teamShifts {
+		_, nextEnd, ok := candidate.NextEnding(end)
+		if ok && (handoff.NextEndsAt.IsZero() || nextEnd.Before(handoff.NextEndsAt)) {
+			handoff.Next = candidate
+			handoff.NextEndsAt = nextEnd
+		}
+	}
+
+	rules, err := models.GetTagAlertsByTeam(db, shift.TeamID)
+	if err != nil {
+		return nil, err
+	}
+	concernsTeam := func(tags []string, organizationID, requesterID int64) bool {
+		if len(rules) == 0 {
+			return true
+		}
+		for _, rule := range rules {
+			if ruleMatches(rule, tags, organizationID, requesterID, customers) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, ticket := range slaTickets {
+		handoff.Subjects[ticket.ID] = ticket.Subject
+		if !concernsTeam(ticket.Tags, ticket.OrganizationID, ticket.RequesterID) {
+			continue
+		}
+		if metric, ok := nextBreach(slaData[ticket.ID]); ok && metric.BreachAt.Before(handoff.NextEndsAt) {
+			handoff.Breaching = append(handoff.Breaching, BreachingTicket{Ticket: ticket, Metric: metric})
+		}
+	}
+	sort.Slice(handoff.Breaching, func(i, j int) bool {
+		return handoff.Breaching[i].Metric.BreachAt.Before(handoff.Breaching[j].Metric.BreachAt)
+	})
+
+	for _, ticket := range onHold {
+		handoff.Subjects[ticket.ID] = ticket.Subject
+		if concernsTeam(ticket.Tags, ticket.OrganizationID, ticket.RequesterID) {
+			handoff.OnHold = append(handoff.OnHold, ticket)
+		}
+	}
+
+	alerts, err := models.GetUnacknowledgedTeamAlerts(db, shift.TeamID, start)
+	if err != nil {
+		return nil, err
+	}
+	alerted := make(map[int64]bool)
+	for _, alert := range alerts {
+		if !alerted[alert.TicketID] {
+			alerted[alert.TicketID] = true
+			handoff.Unacknowledged = append(handoff.Unacknowledged, alert)
+		}
+	}
+
+	activity, err := models.GetTicketActivitySince(db, start)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ticket activity: %w", err)
+	}
+	reopened := make(map[int64]bool)
+	for _, event := range activity {
+		if event.AlertType != AlertTypeReopened || reopened[event.TicketID] || event.ObservedAt.After(end) {
+			continue
+		}
+		if concernsTeam(event.TagList(), event.OrganizationID, event.RequesterID) {
+			reopened[event.TicketID] = true
+			handoff.Reopened = append(handoff.Reopened, event)
+		}
+	}
+	return handoff, nil
+}
+
+// nextBreach returns the ticket's active SLA metric that breaches soonest.
+func nextBreach(slaInfo SLAInfo) (SLAPolicyMetric, bool) {
+	var next SLAPolicyMetric
+	found := false
+	for _, metric := range slaInfo.PolicyMetrics {
+		if metric.Stage != "active" || metric.BreachAt.IsZero() {
+			continue
+		}
+		if !found || metric.BreachAt.Before(next.BreachAt) {
+			next = metric
+			found = true
+		}
+	}
+	return next, found
+}
+
+// Text formats the handoff report as a Slack message.
+func (h ShiftHandoff) Text(zendeskSubdomain string) string {
+	ticketLink := func(ticketID int64) string {
+		link := fmt.Sprintf("<https://%s.zendesk.com/agent/tickets/%d|#%d>", zendeskSubdomain, ticketID, ticketID)
+		if subject := h.Subjects[ticketID]; subject != "" {
+			link += " " + subject
+		}
+		return link
+	}
+
+	var sb strings.Builder
+	if h.Next.ID != 0 && h.Next.ID != h.Shift.ID {
+		fmt.Fprintf(&sb, "*Shift handoff: %s to %s*\n", h.Shift.Name, h.Next.Name)
+	} else {
+		fmt.Fprintf(&sb, "*Shift handoff: %s*\n", h.Shift.Name)
+	}
+	fmt.Fprintf(&sb, "%s to %s\n", h.StartedAt.Format("Mon Jan 2 15:04"), h.EndedAt.Format("Mon Jan 2 15:04"))
+
+	var lines []string
+	for _, b := range h.Breaching {
+		due := "breaches " + b.Metric.BreachAt.Format("Mon 15:04")
+		if !b.Metric.BreachAt.After(h.EndedAt) {
+			due = "already breached"
+		}
+		lines = append(lines, fmt.Sprintf("%s (%s %s)", ticketLink(b.Ticket.ID), strings.ReplaceAll(b.Metric.Metric, "_", " "), due))
+	}
+	writeHandoffSection(&sb, "SLAs breaching before the next shift ends", lines)
+
+	lines = nil
+	for _, alert := range h.Unacknowledged {
+		lines = append(lines, fmt.Sprintf("%s (%s)", ticketLink(alert.TicketID), strings.ReplaceAll(alert.AlertType, "_", " ")))
+	}
+	writeHandoffSection(&sb, "Unacknowledged alerts", lines)
+
+	lines = nil
+	for _, ticket := range h.OnHold {
+		lines = append(lines, ticketLink(ticket.ID))
+	}
+	writeHandoffSection(&sb, "Waiting on an internal reply", lines)
+
+	lines = nil
+	for _, event := range h.Reopened {
+		lines = append(lines, ticketLink(event.TicketID))
+	}
+	writeHandoffSection(&sb, "Reopened this shift", lines)
+
+	return sb.String()
+}
+
+// writeHandoffSection adds a titled list of tickets to a handoff report.
+func writeHandoffSection(sb *strings.Builder, title string, lines []string) {
+	fmt.Fprintf(sb, "\n*%s* (%d)\n", title, len(lines))
+	if len(lines) == 0 {
+		sb.WriteString("None.\n")
+		return
+	}
+	for i, line := range lines {
+		if i == maxHandoffTickets {
+			fmt.Fprintf(sb, "…and %d more\n", len(lines)-maxHandoffTickets)
+			break
+		}
+		fmt.Fprintf(sb, "• %s\n", line)
+	}
+}
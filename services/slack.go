@@ -1,10 +1,14 @@
 package services
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/TylerConlee/TicketPulse/db"
@@ -150,12 +154,24 @@ func (s *SlackService) StartSocketMode() {
 
 				if callback.Type == slack.InteractionTypeBlockActions {
 					action := callback.ActionCallback.BlockActions[0]
-					if action.ActionID == "acknowledge" {
+					switch action.ActionID {
+					case "acknowledge":
 						s.HandleAcknowledge(callback)
+					case "snooze":
+						s.HandleSnooze(callback, action.SelectedOption.Value)
+					case "watch":
+						s.HandleWatch(callback, action.Value)
 					}
 				}
 
 				s.socketMode.Ack(*evt.Request)
+			case socketmode.EventTypeSlashCommand:
+				cmd, ok := evt.Data.(slack.SlashCommand)
+				if !ok {
+					continue
+				}
+				reply := pulseCommand(context.Background(), s.DB, NewIncidentService(s.DB, s), cmd.UserID, cmd.ChannelID, cmd.Text)
+				s.socketMode.Ack(*evt.Request, map[string]interface{}{"response_type": "ephemeral", "text": reply})
 			}
 		}
 	}()
@@ -163,7 +179,82 @@ func (s *SlackService) StartSocketMode() {
 
 }
 
+// HandleSnooze mutes the ticket picked from an alert's snooze menu. Snoozing
+// in a channel mutes the ticket for the channel's rules; snoozing in a direct
+// message mutes it for the rules the TicketPulse user owns.
+func (s *SlackService) HandleSnooze(callback slack.InteractionCallback, value string) {
+	option, ticketIDText, _ := strings.Cut(value, "|")
+	ticketID, err := strconv.ParseInt(ticketIDText, 10, 64)
+	if err != nil {
+		log.Printf("Invalid snooze selection %q: %v", value, err)
+		return
+	}
+	mute, err := NewTicketMute(ticketID, option, callback.User.Name)
+	if err != nil {
+		log.Printf("Invalid snooze selection %q: %v", value, err)
+		return
+	}
+	RecordMuteSLAStage(s.DB, &mute)
+
+	channelID := callback.Channel.ID
+	if strings.HasPrefix(channelID, "D") {
+		user, err := models.GetUserBySlackID(s.DB, callback.User.ID)
+		if err != nil || user.ID == 0 {
+			if err != nil {
+				log.Printf("Failed to look up Slack user %s: %v", callback.User.ID, err)
+			}
+			s.client.PostEphemeral(channelID, callback.User.ID, slack.MsgOptionText("Your Slack account isn't linked to a TicketPulse user, so this ticket couldn't be snoozed.", false))
+			return
+		}
+		mute.UserID = sql.NullInt64{Int64: int64(user.ID), Valid: true}
+	} else {
+		mute.ChannelID = channelID
+	}
+
+	if err := models.CreateTicketMute(context.Background(), s.DB, mute); err != nil {
+		log.Printf("Failed to snooze Ticket #%d: %v", ticketID, err)
+		return
+	}
+
+	// Replace any earlier snooze note, keeping the alert and its actions
+	var newBlocks []slack.Block
+	for _, block := range callback.Message.Blocks.BlockSet {
+		if footer, ok := block.(*slack.ContextBlock); ok && footer.BlockID == "snoozed-footer" {
+			continue
+		}
+		newBlocks = append(newBlocks, block)
+	}
+	newBlocks = append(newBlocks, slack.NewContextBlock(
+		"snoozed-footer",
+		slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("Ticket snoozed by <@%s> %s", callback.User.ID, mute.Description()), false, false),
+	))
+
+	_, _, _, err = s.client.UpdateMessage(channelID, callback.Message.Timestamp, slack.MsgOptionBlocks(newBlocks...))
+	if err != nil {
+		log.Printf("Failed to update message in channel %s at %s: %v", channelID, callback.Message.Timestamp, err)
+	}
+}
+
+// HandleWatch adds the alert's ticket to the watchlist of the TicketPulse
+// user linked to whoever pressed Watch, and tells them privately.
+func (s *SlackService) HandleWatch(callback slack.InteractionCallback, value string) {
+	reply := pulseCommand(context.Background(), s.DB, NewIncidentService(s.DB, s), callback.User.ID, callback.Channel.ID, "watch "+value)
+	if _, err := s.client.PostEphemeral(callback.Channel.ID, callback.User.ID, slack.MsgOptionText(reply, false)); err != nil {
+		log.Printf("Failed to reply to Slack user %s: %v", callback.User.ID, err)
+	}
+}
+
+// HandleAcknowledge marks an alert acknowledged in Slack and records the
+// acknowledgement against the ticket's logged alerts, so shift handoff
+// reports leave it out.
 func (s *SlackService) HandleAcknowledge(callback slack.InteractionCallback) {
+	action := callback.ActionCallback.BlockActions[0]
+	if ticketID, err := strconv.ParseInt(strings.TrimPrefix(action.Value, "acknowledge_"), 10, 64); err == nil {
+		if err := models.AcknowledgeTicketAlerts(context.Background(), s.DB, ticketID, callback.User.Name); err != nil {
+			log.Println(err)
+		}
+	}
+
 	// Create a new footer block with the acknowledgment text
 	acknowledgmentBlock := slack.NewContextBlock(
 		"acknowledged-footer",
@@ -193,7 +284,7 @@ func (s *SlackService) HandleAcknowledge(callback slack.InteractionCallback) {
 	}
 }
 
-func (s *SlackService) SendSlackMessage(channelID, alertType, slaLabel string, ticket zendesk.Ticket, slaInfo *SLAInfo, alertTag string) error {
+func (s *SlackService) SendSlackMessage(channelID, alertType, slaLabel string, ticket zendesk.Ticket, slaInfo *SLAInfo, alertTag string, matches []KeywordMatch, duplicates []DuplicateTicket, risk *SLARisk) error {
 	// Fetch Zendesk subdomain for ticket URL
 	zendeskSubdomain, err := models.GetConfiguration(s.DB, "zendesk_subdomain")
 	if err != nil || zendeskSubdomain == "" {
@@ -207,24 +298,16 @@ func (s *SlackService) SendSlackMessage(channelID, alertType, slaLabel string, t
 		return fmt.Errorf("failed to create Zendesk client: %v", err)
 	}
 
-	// Get requester information
+	// Get requester and organization information
+	customers := NewCustomerResolver(s.DB, zc)
+	customer := customers.Lookup(ticket.OrganizationID, ticket.RequesterID)
 	requesterName := "Unknown Requester"
-	requester, err := zc.GetRequesterByID(ticket.RequesterID)
-	if err != nil {
-		log.Printf("Failed to retrieve requester name for Ticket #%d: %v", ticket.ID, err)
-	} else {
-		requesterName = requester.Name
+	if customer.RequesterName != "" {
+		requesterName = customer.RequesterName
 	}
-
-	// Get organization information
 	organizationName := "Unknown Organization"
-	if ticket.OrganizationID > 0 {
-		org, err := zc.GetOrganizationByID(ticket.OrganizationID)
-		if err != nil {
-			log.Printf("Failed to retrieve organization name for Ticket #%d: %v", ticket.ID, err)
-		} else {
-			organizationName = org.Name
-		}
+	if customer.OrganizationName != "" {
+		organizationName = customer.OrganizationName
 	}
 
 	// Determine the SLA expiration time if present
@@ -245,24 +328,100 @@ func (s *SlackService) SendSlackMessage(channelID, alertType, slaLabel string, t
 	case "sla_deadline":
 		alertHeader = "*SLA Breach Warning*"
 		alertDescription = fmt.Sprintf("%s for SLA on the ticket: %d", slaLabel, ticket.ID)
+	case AlertTypeSLARisk:
+		alertHeader = "*SLA Breach Risk*"
+		alertDescription = fmt.Sprintf("The ticket is %s: *%s*", slaLabel, ticket.Subject)
+	case AlertTypePriorityRaised:
+		alertHeader = "*Priority Raised Alert*"
+		alertDescription = fmt.Sprintf("The ticket's priority was raised to *%s*: *%s*", ticket.Priority, ticket.Subject)
+	case AlertTypeReassigned:
+		alertHeader = "*Ticket Reassigned Alert*"
+		alertDescription = fmt.Sprintf("The ticket was assigned to a different agent: *%s*", ticket.Subject)
+	case AlertTypeReopened:
+		alertHeader = "*Ticket Reopened Alert*"
+		alertDescription = fmt.Sprintf("A solved ticket was reopened: *%s*", ticket.Subject)
+	case AlertTypeGroupChanged:
+		alertHeader = "*Group Change Alert*"
+		alertDescription = fmt.Sprintf("The ticket was moved to a new group: *%s*", ticket.Subject)
+	case AlertTypeTagAdded:
+		alertHeader = "*Tag Added Alert*"
+		alertDescription = fmt.Sprintf("A tag was added to the ticket: *%s*", ticket.Subject)
+		if alertTag != "" {
+			alertDescription = fmt.Sprintf("The *%s* tag was added to the ticket: *%s*", alertTag, ticket.Subject)
+		}
+	case AlertTypeUnassigned:
+		alertHeader = "*Unassigned Ticket Alert*"
+		alertDescription = fmt.Sprintf("%s: *%s*", slaLabel, ticket.Subject)
+	case AlertTypeNoAgentReply:
+		alertHeader = "*Awaiting Agent Reply*"
+		alertDescription = fmt.Sprintf("%s: *%s*", slaLabel, ticket.Subject)
+	case AlertTypePendingTooLong:
+		alertHeader = "*Pending Too Long*"
+		alertDescription = fmt.Sprintf("%s: *%s*", slaLabel, ticket.Subject)
+	case AlertTypePossibleDuplicate:
+		alertHeader = "*Possible Duplicate Ticket*"
+		alertDescription = fmt.Sprintf("A new ticket looks like one the customer already has open: *%s*", ticket.Subject)
+	case AlertTypeCSATNegative:
+		alertHeader = "*Negative Satisfaction Rating*"
+		alertDescription = fmt.Sprintf("The customer rated the ticket *%s*: *%s*", satisfactionScore(ticket), ticket.Subject)
 	default:
 		alertHeader = "*Ticket Alert*"
 		alertDescription = fmt.Sprintf("Action required for ticket: *%s*", ticket.Subject)
 	}
 
+	// VIP customers are called out above the alert so they stand out in busy channels
+	if customer.VIP {
+		alertHeader = ":star: *VIP Customer* :star:\n" + alertHeader
+		organizationName += " :star:"
+	}
+
+	fields := []*slack.TextBlockObject{
+		slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*Ticket ID:*\n<%s|#%d>", ticketURL, ticket.ID), false, false),
+		slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*Subject:*\n%s", ticket.Subject), false, false),
+		slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*Requester:*\n%s", requesterName), false, false),
+		slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*Organization:*\n%s", organizationName), false, false),
+		slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*Tag:*\n%s", alertTag), false, false),
+		slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*SLA Expiration:*\n%s", slaExpiration), false, false),
+	}
+
+	if risk != nil {
+		fields = append(fields, slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*Breach Risk:*\n%d%% (%d similar tickets)", risk.Score, risk.Samples), false, false))
+	}
+
+	// Leads following up on a bad rating need to know who handled the ticket and what the customer said
+	var ratingComment string
+	if alertType == AlertTypeCSATNegative {
+		assigneeName := customers.UserName(ticket.AssigneeID)
+		if assigneeName == "" {
+			assigneeName = "Unassigned"
+		}
+		fields = append(fields, slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*Assignee:*\n%s", assigneeName), false, false))
+
+		ratingComment = "_No comment_"
+		if ticket.SatisfactionRating != nil && ticket.SatisfactionRating.Comment != "" {
+			ratingComment = ">" + truncateDescription(ticket.SatisfactionRating.Comment, 100)
+		}
+	}
+
 	// Construct the message blocks using Slack Block Kit
 	blocks := []slack.Block{
 		slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("%s\n%s", alertHeader, alertDescription), false, false), nil, nil),
-		slack.NewSectionBlock(nil, []*slack.TextBlockObject{
-			slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*Ticket ID:*\n<%s|#%d>", ticketURL, ticket.ID), false, false),
-			slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*Subject:*\n%s", ticket.Subject), false, false),
-			slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*Requester:*\n%s", requesterName), false, false),
-			slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*Organization:*\n%s", organizationName), false, false),
-			slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*Tag:*\n%s", alertTag), false, false),
-			slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*SLA Expiration:*\n%s", slaExpiration), false, false),
-		}, nil),
-		slack.NewActionBlock("", slack.NewButtonBlockElement("acknowledge", fmt.Sprintf("acknowledge_%d", ticket.ID), slack.NewTextBlockObject("plain_text", "Acknowledge", false, false)).WithStyle(slack.StylePrimary)),
+		slack.NewSectionBlock(nil, fields, nil),
+	}
+	if ratingComment != "" {
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", "*Rating Comment:*\n"+ratingComment, false, false), nil, nil))
+	}
+	if len(matches) > 0 {
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", "*Matched:*\n"+keywordMatchText(matches), false, false), nil, nil))
 	}
+	if len(duplicates) > 0 {
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", "*Possible Duplicates:*\n"+s.duplicateText(duplicates), false, false), nil, nil))
+	}
+	blocks = append(blocks, slack.NewActionBlock("",
+		slack.NewButtonBlockElement("acknowledge", fmt.Sprintf("acknowledge_%d", ticket.ID), slack.NewTextBlockObject("plain_text", "Acknowledge", false, false)).WithStyle(slack.StylePrimary),
+		slack.NewButtonBlockElement("watch", strconv.FormatInt(ticket.ID, 10), slack.NewTextBlockObject("plain_text", "Watch", false, false)),
+		snoozeMenu(ticket.ID),
+	))
 
 	// Create and send the message using the Slack client
 	channelID, timestamp, err := s.client.PostMessage(channelID, slack.MsgOptionBlocks(blocks...))
@@ -274,6 +433,292 @@ func (s *SlackService) SendSlackMessage(channelID, alertType, slaLabel string, t
 	return nil
 }
 
+// keywordMatchText lists keyword matches with each phrase highlighted in its snippet.
+func keywordMatchText(matches []KeywordMatch) string {
+	var sb strings.Builder
+	for _, match := range matches {
+		sb.WriteString(fmt.Sprintf(">%s*%s*%s\n", match.Before, match.Phrase, match.After))
+	}
+	return sb.String()
+}
+
+// duplicateText lists likely duplicate tickets with how similar each one is.
+func (s *SlackService) duplicateText(duplicates []DuplicateTicket) string {
+	var sb strings.Builder
+	for _, duplicate := range duplicates {
+		sb.WriteString(fmt.Sprintf("• %s %s _(%d%% similar, %s)_\n", slackTicketLink(s.DB, duplicate.ID), duplicate.Subject, duplicate.Percent(), duplicate.Status))
+	}
+	return sb.String()
+}
+
+// snoozeMenu offers the MuteOptions for a ticket. Each option's value carries
+// the ticket ID so HandleSnooze knows which ticket to mute.
+func snoozeMenu(ticketID int64) *slack.SelectBlockElement {
+	options := make([]*slack.OptionBlockObject, 0, len(MuteOptions))
+	for _, option := range MuteOptions {
+		options = append(options, slack.NewOptionBlockObject(
+			fmt.Sprintf("%s|%d", option.Value, ticketID),
+			slack.NewTextBlockObject("plain_text", option.Label, false, false),
+			nil,
+		))
+	}
+	return slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, slack.NewTextBlockObject("plain_text", "Snooze", false, false), "snooze", options...)
+}
+
+// Slack limits a message to 50 blocks and a section to 3000 characters.
+const (
+	slackMaxBlocks      = 50
+	slackMaxSectionText = 2900
+	slackMaxFieldText   = 1900 // Section fields are limited to 2000 characters
+)
+
+// SendVolumeSpikeMessage posts a volume spike alert listing the most recent tickets in the spike.
+func (s *SlackService) SendVolumeSpikeMessage(channelID string, spike VolumeSpike) error {
+	var tickets strings.Builder
+	for _, ticketID := range spike.TicketIDs {
+		tickets.WriteString("• " + slackTicketLink(s.DB, ticketID) + "\n")
+	}
+
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", "*Ticket Volume Spike*\n"+spike.Label(), false, false), nil, nil),
+	}
+	if tickets.Len() > 0 {
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", "*Latest Tickets:*\n"+tickets.String(), false, false), nil, nil))
+	}
+
+	if _, _, err := s.client.PostMessage(channelID, slack.MsgOptionBlocks(blocks...)); err != nil {
+		return fmt.Errorf("failed to send Slack message: %v", err)
+	}
+	return nil
+}
+
+// PostIncident starts an incident's thread in a channel and returns the
+// thread's timestamp.
+func (s *SlackService) PostIncident(channelID string, summary IncidentSummary) (string, error) {
+	_, timestamp, err := s.client.PostMessage(channelID, slack.MsgOptionBlocks(incidentBlocks(summary)...), slack.MsgOptionText("Incident: "+summary.Name, false))
+	if err != nil {
+		return "", fmt.Errorf("failed to post incident to Slack: %v", err)
+	}
+	return timestamp, nil
+}
+
+// UpdateIncident refreshes the ticket count and affected organizations at the
+// top of an incident's thread.
+func (s *SlackService) UpdateIncident(channelID, threadTS string, summary IncidentSummary) error {
+	_, _, _, err := s.client.UpdateMessage(channelID, threadTS, slack.MsgOptionBlocks(incidentBlocks(summary)...), slack.MsgOptionText("Incident: "+summary.Name, false))
+	if err != nil {
+		return fmt.Errorf("failed to update incident in Slack: %v", err)
+	}
+	return nil
+}
+
+// PostIncidentReply replies in an incident's thread. Broadcast replies are
+// also shown in the channel, for status updates everyone should see.
+func (s *SlackService) PostIncidentReply(channelID, threadTS, text string, broadcast bool) error {
+	options := []slack.MsgOption{slack.MsgOptionText(text, false), slack.MsgOptionTS(threadTS)}
+	if broadcast {
+		options = append(options, slack.MsgOptionBroadcast())
+	}
+	if _, _, err := s.client.PostMessage(channelID, options...); err != nil {
+		return fmt.Errorf("failed to reply to incident thread: %v", err)
+	}
+	return nil
+}
+
+// incidentBlocks renders the message at the top of an incident's thread.
+func incidentBlocks(summary IncidentSummary) []slack.Block {
+	header := fmt.Sprintf("*:rotating_light: Incident: %s*", summary.Name)
+	if summary.ResolvedAt.Valid {
+		header = fmt.Sprintf("*:white_check_mark: Resolved Incident: %s*", summary.Name)
+	}
+
+	organizations := "None yet"
+	if len(summary.Organizations) > 0 {
+		organizations = strings.Join(summary.Organizations, ", ")
+		if len(organizations) > slackMaxFieldText {
+			organizations = organizations[:slackMaxFieldText] + "…"
+		}
+	}
+	fields := []*slack.TextBlockObject{
+		slack.NewTextBlockObject("mrkdwn", "*Matching:*\n"+summary.Criteria(), false, false),
+		slack.NewTextBlockObject("mrkdwn", "*Started:*\n"+summary.StartedAt.Local().Format("Jan 2 15:04 MST"), false, false),
+		slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*Tickets:*\n%d", len(summary.Tickets)), false, false),
+		slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*Affected Organizations (%d):*\n%s", len(summary.Organizations), organizations), false, false),
+	}
+
+	note := "Matching tickets are grouped in this thread instead of alerting individually."
+	if summary.ResolvedAt.Valid {
+		note = "Resolved " + summary.ResolvedAt.Time.Local().Format("Jan 2 15:04 MST") + ". Matching tickets alert individually again."
+	}
+	return []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", header, false, false), fields, nil),
+		slack.NewContextBlock("", slack.NewTextBlockObject("mrkdwn", note, false, false)),
+	}
+}
+
+// SendDigestMessage posts one message listing the digest's tickets grouped by alert type.
+func (s *SlackService) SendDigestMessage(channelID string, groups []DigestGroup) error {
+	total := 0
+	for _, group := range groups {
+		total += len(group.Entries)
+	}
+
+	// Split the digest into sections that fit Slack's limits, tracking how many tickets each holds
+	type section struct {
+		text    string
+		tickets int
+	}
+	var sections []section
+	for _, group := range groups {
+		current := section{text: fmt.Sprintf("*%s* (%d)\n", group.Label, len(group.Entries))}
+		for _, entry := range group.Entries {
+			line := fmt.Sprintf("• %s %s", slackTicketLink(s.DB, entry.TicketID), entry.Subject)
+			if entry.SLALabel != "" {
+				line += fmt.Sprintf(" _%s_", entry.SLALabel)
+			}
+			if entry.Occurrences > 1 {
+				line += fmt.Sprintf(" (x%d)", entry.Occurrences)
+			}
+			if len(current.text)+len(line) >= slackMaxSectionText {
+				sections = append(sections, current)
+				current = section{}
+			}
+			current.text += line + "\n"
+			current.tickets++
+		}
+		sections = append(sections, current)
+	}
+
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*Ticket Digest*\n%d tickets matched since the last digest", total), false, false), nil, nil),
+	}
+	omitted := 0
+	for _, sec := range sections {
+		// Leave room for the header and the omitted tickets note
+		if len(blocks) >= slackMaxBlocks-1 {
+			omitted += sec.tickets
+			continue
+		}
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", sec.text, false, false), nil, nil))
+	}
+	if omitted > 0 {
+		blocks = append(blocks, slack.NewContextBlock("", slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("…and %d more tickets not shown", omitted), false, false)))
+	}
+
+	_, timestamp, err := s.client.PostMessage(channelID, slack.MsgOptionBlocks(blocks...), slack.MsgOptionText(fmt.Sprintf("Ticket digest: %d tickets", total), false))
+	if err != nil {
+		return fmt.Errorf("failed to send Slack digest: %v", err)
+	}
+
+	log.Printf("Digest of %d tickets sent to channel %s at %s", total, channelID, timestamp)
+	return nil
+}
+
+// slackTicketLink formats a ticket number as a Slack link to the ticket when Zendesk is configured.
+func slackTicketLink(db db.Database, ticketID int64) string {
+	if url := ticketURL(db, ticketID); url != "" {
+		return fmt.Sprintf("<%s|#%d>", url, ticketID)
+	}
+	return fmt.Sprintf("#%d", ticketID)
+}
+
+// SlackChannelNotifier posts rule alerts to the Slack channel configured on the rule.
+type SlackChannelNotifier struct {
+	slackService *SlackService
+}
+
+func NewSlackChannelNotifier(slackService *SlackService) *SlackChannelNotifier {
+	return &SlackChannelNotifier{slackService: slackService}
+}
+
+func (c *SlackChannelNotifier) Channel() string { return ChannelSlackChannel }
+
+func (c *SlackChannelNotifier) Label() string { return "Slack Channel" }
+
+func (c *SlackChannelNotifier) Personal() bool { return false }
+
+func (c *SlackChannelNotifier) Destination(n Notification) string {
+	if n.Rule == nil {
+		return ""
+	}
+	return n.Rule.SlackChannelID
+}
+
+// Supports excludes summaries, which have no channel to post to.
+func (c *SlackChannelNotifier) Supports(alertType string) bool {
+	return alertType != AlertTypeDailySummary
+}
+
+func (c *SlackChannelNotifier) Notify(ctx context.Context, n Notification) error {
+	if n.Rule != nil && n.Spike != nil {
+		return c.slackService.SendVolumeSpikeMessage(n.Rule.SlackChannelID, *n.Spike)
+	}
+	if n.Rule == nil || n.Ticket == nil {
+		return fmt.Errorf("slack channel notifications require a rule and ticket")
+	}
+	return c.slackService.SendSlackMessage(n.Rule.SlackChannelID, n.AlertType, n.SLALabel, *n.Ticket, n.SLA, n.Rule.Tag, n.Matches, n.Duplicates, n.Risk)
+}
+
+// NotifyDigest posts a single message listing every ticket in the digest.
+func (c *SlackChannelNotifier) NotifyDigest(ctx context.Context, destination string, groups []DigestGroup) error {
+	return c.slackService.SendDigestMessage(destination, groups)
+}
+
+// NotifyText posts a plain text message to the channel.
+func (c *SlackChannelNotifier) NotifyText(ctx context.Context, destination, text string) error {
+	if _, _, err := c.slackService.client.PostMessage(destination, slack.MsgOptionText(text, false)); err != nil {
+		return fmt.Errorf("failed to send Slack message: %v", err)
+	}
+	return nil
+}
+
+// SlackDMNotifier sends alerts and summaries to the recipient as a Slack direct message.
+type SlackDMNotifier struct {
+	slackService *SlackService
+}
+
+func NewSlackDMNotifier(slackService *SlackService) *SlackDMNotifier {
+	return &SlackDMNotifier{slackService: slackService}
+}
+
+func (d *SlackDMNotifier) Channel() string { return ChannelSlackDM }
+
+func (d *SlackDMNotifier) Label() string { return "Slack DM" }
+
+func (d *SlackDMNotifier) Personal() bool { return true }
+
+func (d *SlackDMNotifier) Destination(n Notification) string {
+	if n.Recipient.SlackUserID.Valid && n.Recipient.SlackUserID.String != "" {
+		return n.Recipient.SlackUserID.String
+	}
+	return n.Recipient.Email
+}
+
+func (d *SlackDMNotifier) Supports(alertType string) bool { return true }
+
+func (d *SlackDMNotifier) Notify(ctx context.Context, n Notification) error {
+	if !n.Recipient.SlackUserID.Valid || n.Recipient.SlackUserID.String == "" {
+		return fmt.Errorf("slack user ID is not set for user: %s", n.Recipient.Email)
+	}
+	slackUserID := n.Recipient.SlackUserID.String
+
+	if n.Summary != nil {
+		return sendSlackDM(d.slackService, slackUserID, n.Summary.UnreadTickets, n.Summary.OpenTicketsWithSLA, n.Summary.CSATRatings, n.Summary.SLAData)
+	}
+	if n.Spike != nil {
+		return d.slackService.SendVolumeSpikeMessage(slackUserID, *n.Spike)
+	}
+	if n.Ticket == nil {
+		return fmt.Errorf("slack DM notifications require a ticket or summary")
+	}
+
+	tag := ""
+	if n.Rule != nil {
+		tag = n.Rule.Tag
+	}
+	return d.slackService.SendSlackMessage(slackUserID, n.AlertType, n.SLALabel, *n.Ticket, n.SLA, tag, n.Matches, n.Duplicates, n.Risk)
+}
+
 func (s *SlackService) GetUserIDByEmail(email string) (string, error) {
 	user, err := s.client.GetUserByEmail(email)
 	if err != nil {
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/nukosuke/go-zendesk/zendesk"
+)
+
+// SLA risk prediction settings.
+const (
+	// SLARiskThreshold is the risk score, in percent, at which a ticket is
+	// considered likely to breach.
+	SLARiskThreshold        = 70
+	minRiskSamples          = 10 // Fewer similar tickets than this are not enough to predict from
+	ticketDurationRetention = 180 * 24 * time.Hour
+)
+
+// SLARisk is the predicted chance that a ticket breaches an SLA metric.
+type SLARisk struct {
+	Metric  SLAPolicyMetric
+	Score   int // Percent chance of a breach
+	Samples int // How many similar solved tickets the score is based on
+}
+
+// Label describes the risk, such as "82% likely to breach first reply time".
+func (r SLARisk) Label() string {
+	return fmt.Sprintf("%d%% likely to breach %s", r.Score, strings.ReplaceAll(r.Metric.Metric, "_", " "))
+}
+
+// historicalMinutes returns how long a solved ticket took to meet the kind of
+// target an SLA metric measures. Metrics that measure replies after the first
+// have no per-ticket history and cannot be predicted.
+func historicalMinutes(duration models.TicketDuration, metric string) (int64, bool) {
+	switch metric {
+	case "first_reply_time":
+		return duration.FirstReplyMinutes.Int64, duration.FirstReplyMinutes.Valid
+	case "requester_wait_time", "agent_work_time":
+		return duration.SolveMinutes.Int64, duration.SolveMinutes.Valid
+	}
+	return 0, false
+}
+
+// SLARiskPredictor predicts SLA breaches from how long similar solved tickets took.
+type SLARiskPredictor struct {
+	history []models.TicketDuration
+}
+
+// NewSLARiskPredictor loads the recorded ticket durations. Without history no
+// ticket can be predicted, so load errors are logged rather than returned.
+func NewSLARiskPredictor(db db.Database) *SLARiskPredictor {
+	history, err := models.GetTicketDurationsSince(db, time.Now().Add(-ticketDurationRetention))
+	if err != nil {
+		log.Println(err)
+	}
+	return &SLARiskPredictor{history: history}
+}
+
+// Predict returns the riskiest of the ticket's active, unbreached SLA metrics.
+func (p *SLARiskPredictor) Predict(ticket zendesk.Ticket, slaInfo SLAInfo) (SLARisk, bool) {
+	var riskiest SLARisk
+	found := false
+	for _, metric := range slaInfo.PolicyMetrics {
+		if risk, ok := p.PredictMetric(ticket, metric); ok && (!found || risk.Score > riskiest.Score) {
+			riskiest, found = risk, true
+		}
+	}
+	return riskiest, found
+}
+
+// PredictMetric estimates the chance the ticket breaches the metric. Among
+// similar solved tickets that took at least as long as this ticket has been
+// open, it is the share that took longer than the metric allows. Tickets with
+// the same priority, group and a shared tag are preferred, falling back to
+// broader matches until there are enough to predict from.
+func (p *SLARiskPredictor) PredictMetric(ticket zendesk.Ticket, metric SLAPolicyMetric) (SLARisk, bool) {
+	if p == nil || metric.Stage != "active" || ticket.CreatedAt == nil || !metric.BreachAt.After(time.Now()) {
+		return SLARisk{}, false
+	}
+	elapsed := time.Since(*ticket.CreatedAt).Minutes()
+	target := metric.BreachAt.Sub(*ticket.CreatedAt).Minutes()
+	groupID := ticketGroupID(ticket)
+
+	similar := []func(models.TicketDuration) bool{
+		func(d models.TicketDuration) bool {
+			return d.Priority == ticket.Priority && d.GroupID == groupID && sharesTag(d.TagList(), ticket.Tags)
+		},
+		func(d models.TicketDuration) bool { return d.Priority == ticket.Priority && d.GroupID == groupID },
+		func(d models.TicketDuration) bool { return d.Priority == ticket.Priority },
+		func(d models.TicketDuration) bool { return true },
+	}
+	for _, matches := range similar {
+		samples, breaches := 0, 0
+		for _, duration := range p.history {
+			minutes, ok := historicalMinutes(duration, metric.Metric)
+			if !ok || float64(minutes) < elapsed || !matches(duration) {
+				continue
+			}
+			samples++
+			if float64(minutes) > target {
+				breaches++
+			}
+		}
+		if samples >= minRiskSamples {
+			score := int(math.Round(100 * float64(breaches) / float64(samples)))
+			return SLARisk{Metric: metric, Score: score, Samples: samples}, true
+		}
+	}
+	return SLARisk{}, false
+}
+
+// sharesTag reports whether the two tag lists have a logical tag in common.
+func sharesTag(a, b []string) bool {
+	for _, tagA := range a {
+		for _, tagB := range b {
+			if canonicalTag(tagA) == canonicalTag(tagB) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// evaluateSLARisk fires for tickets likely to breach an SLA metric that is
+// not yet close enough to alert on as a breach.
+func evaluateSLARisk(ticket zendesk.Ticket, slaInfo SLAInfo, risks *SLARiskPredictor) RuleEvaluation {
+	if _, breaching := slaConditionMatches(slaInfo.PolicyMetrics); breaching {
+		return RuleEvaluation{}
+	}
+	risk, ok := risks.Predict(ticket, slaInfo)
+	if !ok || risk.Score < SLARiskThreshold {
+		return RuleEvaluation{}
+	}
+	return RuleEvaluation{Fires: true, SLALabel: risk.Label(), SLAMetric: risk.Metric, Risk: &risk}
+}
+
+// recordSLARisks stores the polled tickets' risk scores for the dashboard.
+func recordSLARisks(ctx context.Context, db db.Database, risks *SLARiskPredictor, tickets []zendesk.Ticket, slaData map[int64]SLAInfo) {
+	var scores []models.SLARiskScore
+	seen := make(map[int64]bool)
+	for _, ticket := range tickets {
+		if seen[ticket.ID] {
+			continue
+		}
+		seen[ticket.ID] = true
+		for _, metric := range slaData[ticket.ID].PolicyMetrics {
+			risk, ok := risks.PredictMetric(ticket, metric)
+			if !ok {
+				continue
+			}
+			scores = append(scores, models.SLARiskScore{
+				TicketID: ticket.ID,
+				Subject:  ticket.Subject,
+				Metric:   metric.Metric,
+				BreachAt: metric.BreachAt,
+				Score:    risk.Score,
+				Samples:  risk.Samples,
+			})
+		}
+	}
+	if err := models.ReplaceSLARiskScores(ctx, db, scores); err != nil {
+		log.Println(err)
+	}
+}
+
+// recordTicketDurations stores how long newly solved tickets took, so future
+// tickets can be predicted from them.
+func recordTicketDurations(ctx context.Context, db db.Database, zc *ZendeskClient, tickets []zendesk.Ticket) {
+	for _, ticket := range tickets {
+		if ticket.Status != "solved" && ticket.Status != "closed" {
+			continue
+		}
+		recorded, err := models.TicketDurationRecorded(db, ticket.ID)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		if recorded {
+			continue
+		}
+
+		firstReply, solve, err := zc.GetTicketMetrics(ticket.ID)
+		if err != nil {
+			log.Printf("Failed to retrieve metrics for Ticket #%d: %v", ticket.ID, err)
+			continue
+		}
+		duration := models.TicketDuration{
+			TicketID:          ticket.ID,
+			Priority:          ticket.Priority,
+			GroupID:           ticketGroupID(ticket),
+			Tags:              strings.Join(ticket.Tags, " "),
+			FirstReplyMinutes: firstReply,
+			SolveMinutes:      solve,
+		}
+		if err := models.RecordTicketDuration(ctx, db, duration); err != nil {
+			log.Println(err)
+		}
+	}
+	if err := models.PruneTicketDurations(ctx, db, time.Now().Add(-ticketDurationRetention)); err != nil {
+		log.Println(err)
+	}
+}
+
+// GetTicketMetrics retrieves a ticket's calendar minutes to first reply and
+// to full resolution. Either is null when it has not happened.
+func (zc *ZendeskClient) GetTicketMetrics(ticketID int64) (sql.NullInt64, sql.NullInt64, error) {
+	url := fmt.Sprintf("https://%s.zendesk.com/api/v2/tickets/%d/metrics.json", zc.Subdomain, ticketID)
+	req, err := http.NewRequestWithContext(context.Background(), "GET", url, nil)
+	if err != nil {
+		return sql.NullInt64{}, sql.NullInt64{}, err
+	}
+	req.SetBasicAuth(zc.Email+"/token", zc.APIToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return sql.NullInt64{}, sql.NullInt64{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return sql.NullInt64{}, sql.NullInt64{}, fmt.Errorf("zendesk returned status %s", resp.Status)
+	}
+
+	type minutes struct {
+		Calendar *int64 `json:"calendar"`
+	}
+	var result struct {
+		TicketMetric struct {
+			ReplyTime      minutes `json:"reply_time_in_minutes"`
+			FullResolution minutes `json:"full_resolution_time_in_minutes"`
+		} `json:"ticket_metric"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return sql.NullInt64{}, sql.NullInt64{}, err
+	}
+
+	toNull := func(m minutes) sql.NullInt64 {
+		if m.Calendar == nil {
+			return sql.NullInt64{}
+		}
+		return sql.NullInt64{Int64: *m.Calendar, Valid: true}
+	}
+	return toNull(result.TicketMetric.ReplyTime), toNull(result.TicketMetric.FullResolution), nil
+}
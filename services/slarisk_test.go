@@ -0,0 +1,136 @@
+package services
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/nukosuke/go-zendesk/zendesk"
+	"github.com/stretchr/testify/assert"
+)
+
+// durations returns count solved tickets that took the given minutes to first reply.
+func durations(count int, priority string, groupID int64, tags string, firstReplyMinutes int64) []models.TicketDuration {
+	var history []models.TicketDuration
+	for i := 0; i < count; i++ {
+		history = append(history, models.TicketDuration{
+			Priority:          priority,
+			GroupID:           groupID,
+			Tags:              tags,
+			FirstReplyMinutes: sql.NullInt64{Int64: firstReplyMinutes, Valid: true},
+		})
+	}
+	return history
+}
+
+func TestPredictMetric(t *testing.T) {
+	created := time.Now().Add(-time.Hour)
+	ticket := zendesk.Ticket{ID: 42, Priority: "high", GroupID: "10", Tags: []string{"billing"}, CreatedAt: &created}
+	// The ticket has been open an hour of its two hour first reply target
+	metric := SLAPolicyMetric{Metric: "first_reply_time", Stage: "active", BreachAt: created.Add(2 * time.Hour)}
+
+	join := func(parts ...[]models.TicketDuration) []models.TicketDuration {
+		var history []models.TicketDuration
+		for _, part := range parts {
+			history = append(history, part...)
+		}
+		return history
+	}
+
+	tests := []struct {
+		name    string
+		history []models.TicketDuration
+		metric  SLAPolicyMetric
+		score   int
+		samples int
+		ok      bool
+	}{
+		{
+			name: "closest matches",
+			history: join(
+				durations(8, "high", 10, "billing", 180),
+				durations(2, "high", 10, "billing other", 90),
+				durations(20, "high", 10, "billing", 30), // Answered sooner than this ticket has been open
+				durations(20, "low", 20, "", 90),
+			),
+			metric: metric, score: 80, samples: 10, ok: true,
+		},
+		{
+			name: "falls back to the same priority",
+			history: join(
+				durations(5, "high", 10, "billing", 180),
+				durations(6, "high", 20, "", 90),
+				durations(20, "low", 20, "", 180),
+			),
+			metric: metric, score: 45, samples: 11, ok: true,
+		},
+		{
+			name: "falls back to every ticket",
+			history: join(
+				durations(3, "high", 10, "billing", 180),
+				durations(9, "low", 20, "", 90),
+			),
+			metric: metric, score: 25, samples: 12, ok: true,
+		},
+		{
+			name:    "too few similar tickets",
+			history: durations(minRiskSamples-1, "high", 10, "billing", 180),
+			metric:  metric,
+		},
+		{
+			name:    "already breached",
+			history: durations(10, "high", 10, "billing", 180),
+			metric:  SLAPolicyMetric{Metric: "first_reply_time", Stage: "active", BreachAt: time.Now().Add(-time.Minute)},
+		},
+		{
+			name:    "achieved",
+			history: durations(10, "high", 10, "billing", 180),
+			metric:  SLAPolicyMetric{Metric: "first_reply_time", Stage: "achieved", BreachAt: metric.BreachAt},
+		},
+		{
+			name:    "metric without history",
+			history: durations(10, "high", 10, "billing", 180),
+			metric:  SLAPolicyMetric{Metric: "next_reply_time", Stage: "active", BreachAt: metric.BreachAt},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			risk, ok := (&SLARiskPredictor{history: tt.history}).PredictMetric(ticket, tt.metric)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.score, risk.Score)
+			assert.Equal(t, tt.samples, risk.Samples)
+		})
+	}
+
+	var predictor *SLARiskPredictor
+	_, ok := predictor.PredictMetric(ticket, metric)
+	assert.False(t, ok, "Expected a nil predictor to predict nothing")
+}
+
+func TestEvaluateSLARisk(t *testing.T) {
+	created := time.Now().Add(-time.Hour)
+	ticket := zendesk.Ticket{ID: 42, Priority: "high", CreatedAt: &created}
+	metric := func(target time.Duration) SLAInfo {
+		return SLAInfo{PolicyMetrics: []SLAPolicyMetric{{Metric: "first_reply_time", Stage: "active", BreachAt: created.Add(target)}}}
+	}
+	tests := []struct {
+		name    string
+		history []models.TicketDuration
+		slaInfo SLAInfo
+		label   string
+		fires   bool
+	}{
+		{"similar tickets answered in time", durations(10, "high", 10, "", 180), metric(5 * time.Hour), "", false},
+		{"similar tickets answered late", durations(10, "high", 10, "", 600), metric(5 * time.Hour), "100% likely to breach first reply time", true},
+		{"close enough for an SLA breach rule", durations(10, "high", 10, "", 600), metric(2 * time.Hour), "", false},
+		{"no history", nil, metric(5 * time.Hour), "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluation := evaluateSLARisk(ticket, tt.slaInfo, &SLARiskPredictor{history: tt.history})
+			assert.Equal(t, tt.fires, evaluation.Fires)
+			assert.Equal(t, tt.label, evaluation.SLALabel)
+		})
+	}
+}
@@ -16,10 +16,39 @@ import (
 
 // SearchTicketsWithActiveSLA retrieves tickets with active SLA metrics.
 func (zc *ZendeskClient) SearchTicketsWithActiveSLA() ([]zendesk.Ticket, map[int64]SLAInfo, error) {
+	return zc.searchTicketsWithSLA("type:ticket status<pending")
+}
+
+// SearchOpenTicketsWithTag retrieves unsolved tickets carrying the tag, along with their SLA metrics.
+func (zc *ZendeskClient) SearchOpenTicketsWithTag(tag string) ([]zendesk.Ticket, map[int64]SLAInfo, error) {
+	return zc.searchTicketsWithSLA(fmt.Sprintf("type:ticket status<solved tags:%s", tag))
+}
+
+// SearchOpenTicketsForRule retrieves the unsolved tickets a rule could match,
+// narrowed by the rule's tag and organization when it has them. Tag patterns
+// and aliased tags cannot be searched for, so callers filter those locally.
+func (zc *ZendeskClient) SearchOpenTicketsForRule(alert models.TagAlert) ([]zendesk.Ticket, map[int64]SLAInfo, error) {
+	return zc.searchTicketsWithSLA("type:ticket status<solved" + ruleSearchFilters(alert))
+}
+
+// ruleSearchFilters returns the search terms that narrow a ticket search to
+// the rule's tag and organization, starting with a space when there are any.
+func ruleSearchFilters(alert models.TagAlert) string {
+	var filters string
+	if alert.Tag != "" && !IsTagPattern(alert.Tag) && !hasTagAliases(alert.Tag) {
+		filters += " tags:" + alert.Tag
+	}
+	if organization := strings.TrimSpace(alert.Organization); organization != "" {
+		filters += fmt.Sprintf(" organization:%q", organization)
+	}
+	return filters
+}
+
+// searchTicketsWithSLA runs a ticket search, sideloading each ticket's SLA metrics.
+func (zc *ZendeskClient) searchTicketsWithSLA(query string) ([]zendesk.Ticket, map[int64]SLAInfo, error) {
 	var allTickets []zendesk.Ticket
 	slaData := make(map[int64]SLAInfo)
 
-	query := "type:ticket status<pending"
 	params := url.Values{}
 	params.Set("query", query)
 	params.Set("include", "tickets(slas)")
@@ -239,8 +268,17 @@ func (zc *ZendeskClient) GetCSATRatingsForUser(userID int64, since time.Time) ([
 	return result.Results, nil
 }
 
-// GenerateDailySummary generates a daily summary and sends it to the user via Slack.
-func (zc *ZendeskClient) GenerateDailySummary(userEmail string, slackService *SlackService) (string, error) {
+// DailySummary holds everything included in a user's daily summary.
+type DailySummary struct {
+	Message            string
+	UnreadTickets      []zendesk.Ticket
+	OpenTicketsWithSLA []zendesk.Ticket
+	CSATRatings        []SatisfactionRating
+	SLAData            map[int64]SLAInfo
+}
+
+// GenerateDailySummary generates a daily summary and delivers it over the user's preferred channels.
+func (zc *ZendeskClient) GenerateDailySummary(userEmail string, notificationService *NotificationService) (string, error) {
 	// Define the time range for the summary (e.g., last 24 hours)
 	now := time.Now()
 	since := now.Add(-24 * time.Hour)
@@ -278,20 +316,29 @@ func (zc *ZendeskClient) GenerateDailySummary(userEmail string, slackService *Sl
 	// Step 7: Compile the summary message
 	summaryMessage := compileSummaryMessage(user.Name, unreadTickets, openTicketsWithSLA, csatRatings)
 
-	// Step 8: Fetch the Slack user ID (assume you have a function for this)
-	slackUserID, err := models.GetUserByEmail(zc.DB, userEmail)
+	// Step 8: Look up the local user to deliver the summary to
+	recipient, err := models.GetUserByEmail(zc.DB, userEmail)
 	if err != nil {
-		return summaryMessage, fmt.Errorf("failed to get Slack user ID: %v", err)
+		return summaryMessage, fmt.Errorf("failed to get user: %v", err)
 	}
-	if !slackUserID.SlackUserID.Valid {
-		return summaryMessage, fmt.Errorf("slack user ID is not set for user: %s", userEmail)
+	if recipient.ID == 0 {
+		return summaryMessage, fmt.Errorf("no user found with email: %s", userEmail)
 	}
-	sUID := slackUserID.SlackUserID.String
 
-	// Step 9: Send the Slack message as a DM using block formatting
-	err = sendSlackDM(slackService, sUID, unreadTickets, openTicketsWithSLA, csatRatings, slaData)
+	// Step 9: Deliver the summary over the user's preferred channels
+	err = notificationService.Dispatch(context.Background(), Notification{
+		AlertType: AlertTypeDailySummary,
+		Recipient: recipient,
+		Summary: &DailySummary{
+			Message:            summaryMessage,
+			UnreadTickets:      unreadTickets,
+			OpenTicketsWithSLA: openTicketsWithSLA,
+			CSATRatings:        csatRatings,
+			SLAData:            slaData,
+		},
+	})
 	if err != nil {
-		log.Printf("failed to send Slack DM: %v", err)
+		log.Printf("failed to deliver daily summary: %v", err)
 	}
 
 	return summaryMessage, nil
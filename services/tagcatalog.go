@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/nukosuke/go-zendesk/zendesk"
+)
+
+// Tag catalog timing.
+const (
+	tagCatalogSyncInterval = 24 * time.Hour
+	staleTagWindow         = 30 * 24 * time.Hour // Rules whose tag no polled ticket has had for this long are flagged
+)
+
+// syncTagCatalog refreshes the tag catalog from Zendesk's tag list once a day.
+func syncTagCatalog(ctx context.Context, db db.Database, zc *ZendeskClient) {
+	lastSync, err := models.LastTagCatalogSync(db)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if time.Since(lastSync) < tagCatalogSyncInterval {
+		return
+	}
+	if err := zc.SyncTagCatalog(ctx); err != nil {
+		log.Println("Error syncing tag catalog:", err)
+	}
+}
+
+// SyncTagCatalog copies every tag in Zendesk, with its usage count, into the
+// local tag catalog.
+func (zc *ZendeskClient) SyncTagCatalog(ctx context.Context) error {
+	endpoint := fmt.Sprintf("https://%s.zendesk.com/api/v2/tags.json?per_page=100", zc.Subdomain)
+	synced := 0
+
+	for endpoint != "" {
+		req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		req.SetBasicAuth(zc.Email+"/token", zc.APIToken)
+		req.Header.Set("Content-Type", "application/json")
+
+		client := &http.Client{}
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to perform request: %w", err)
+		}
+
+		var result struct {
+			Tags []struct {
+				Name  string `json:"name"`
+				Count int64  `json:"count"`
+			} `json:"tags"`
+			NextPage string `json:"next_page"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return fmt.Errorf("failed to list tags: received status %s", resp.Status)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to parse tag list: %w", err)
+		}
+
+		for _, tag := range result.Tags {
+			if err := models.SaveCatalogTag(ctx, zc.DB, tag.Name, tag.Count); err != nil {
+				return err
+			}
+		}
+		synced += len(result.Tags)
+		endpoint = result.NextPage
+	}
+
+	log.Println("Synced", synced, "tags into the tag catalog")
+	return nil
+}
+
+// recordSeenTags notes the tags on the polled tickets in the catalog, so
+// rules can be flagged when their tag stops appearing.
+func recordSeenTags(ctx context.Context, db db.Database, tickets []zendesk.Ticket) {
+	seen := make(map[string]time.Time)
+	for _, ticket := range tickets {
+		seenAt := time.Now()
+		if ticket.UpdatedAt != nil {
+			seenAt = *ticket.UpdatedAt
+		}
+		for _, tag := range ticket.Tags {
+			if seenAt.After(seen[tag]) {
+				seen[tag] = seenAt
+			}
+		}
+	}
+	for tag, seenAt := range seen {
+		if err := models.RecordTagSeen(ctx, db, tag, seenAt); err != nil {
+			log.Println(err)
+			return
+		}
+	}
+}
+
+// StaleRuleTags checks each rule's tag against the tag catalog and returns a
+// warning for each rule whose tag matches no tag in Zendesk, or has not been
+// on a polled ticket in the last 30 days, keyed by rule ID. Nothing is
+// flagged before the catalog has been synced, and rules are not flagged as
+// unused until the catalog has been tracking tags for 30 days.
+func StaleRuleTags(db db.Database, rules []models.TagAlert) (map[int]string, error) {
+	stale := make(map[int]string)
+	lastSync, err := models.LastTagCatalogSync(db)
+	if err != nil || lastSync.IsZero() {
+		return stale, err
+	}
+	catalog, err := models.GetTagCatalog(db)
+	if err != nil {
+		return stale, err
+	}
+	if err := LoadTagAliases(db); err != nil {
+		log.Println("Error loading tag aliases:", err)
+	}
+
+	cutoff := time.Now().Add(-staleTagWindow)
+	tracking := false
+	for _, tag := range catalog {
+		if tag.CreatedAt.Before(cutoff) {
+			tracking = true
+			break
+		}
+	}
+
+	for _, rule := range rules {
+		if rule.Tag == "" {
+			continue
+		}
+		found, recent := false, false
+		for _, tag := range catalog {
+			if !tagPatternMatches(rule.Tag, tag.Name) {
+				continue
+			}
+			found = true
+			if tag.LastSeenAt.Valid && tag.LastSeenAt.Time.After(cutoff) {
+				recent = true
+				break
+			}
+		}
+		switch {
+		case !found:
+			stale[rule.ID] = "Tag not found in Zendesk"
+		case !recent && tracking:
+			stale[rule.ID] = "No tickets in 30 days"
+		}
+	}
+	return stale, nil
+}
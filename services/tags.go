@@ -0,0 +1,154 @@
+package services
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/TylerConlee/TicketPulse/db"
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/nukosuke/go-zendesk/zendesk"
+)
+
+// tagAliases maps lower-cased tag aliases onto their canonical tags. It is
+// reloaded every poll and whenever an admin changes the aliases.
+var tagAliases = struct {
+	sync.RWMutex
+	canonical map[string]string
+}{canonical: make(map[string]string)}
+
+// tagRegexps caches compiled regular expression tag patterns by their source.
+var tagRegexps sync.Map
+
+// LoadTagAliases refreshes the tag aliases used when matching rules.
+func LoadTagAliases(db db.Database) error {
+	aliases, err := models.GetTagAliases(db)
+	if err != nil {
+		return err
+	}
+	canonical := make(map[string]string, len(aliases))
+	for _, alias := range aliases {
+		canonical[alias.Alias] = alias.Canonical
+	}
+
+	tagAliases.Lock()
+	tagAliases.canonical = canonical
+	tagAliases.Unlock()
+	return nil
+}
+
+// canonicalTag returns the logical tag a Zendesk tag stands for: its alias
+// target if it has one, otherwise the tag itself in lower case.
+func canonicalTag(tag string) string {
+	tag = strings.ToLower(tag)
+	tagAliases.RLock()
+	defer tagAliases.RUnlock()
+	if canonical, ok := tagAliases.canonical[tag]; ok {
+		return canonical
+	}
+	return tag
+}
+
+// hasTagAliases reports whether other tags count as the same logical tag.
+func hasTagAliases(tag string) bool {
+	canonical := canonicalTag(tag)
+	if canonical != strings.ToLower(tag) {
+		return true
+	}
+	tagAliases.RLock()
+	defer tagAliases.RUnlock()
+	for _, target := range tagAliases.canonical {
+		if target == canonical {
+			return true
+		}
+	}
+	return false
+}
+
+// isTagRegexp reports whether a rule tag is a regular expression, which is
+// anchored with ^ or $ such as "^tier[12]$".
+func isTagRegexp(tag string) bool {
+	return strings.HasPrefix(tag, "^") || strings.HasSuffix(tag, "$")
+}
+
+// IsTagPattern reports whether a rule tag is a glob such as "product_*" or a
+// regular expression rather than a single tag.
+func IsTagPattern(tag string) bool {
+	return isTagRegexp(tag) || strings.ContainsAny(tag, "*?[")
+}
+
+// ValidateTagPattern checks that a rule tag is a valid glob or regular expression.
+func ValidateTagPattern(tag string) error {
+	if isTagRegexp(tag) {
+		if _, err := tagRegexp(tag); err != nil {
+			return err
+		}
+		return nil
+	}
+	if _, err := path.Match(strings.ToLower(tag), ""); err != nil {
+		return fmt.Errorf("invalid tag pattern: %w", err)
+	}
+	return nil
+}
+
+// tagRegexp compiles a regular expression tag pattern, ignoring case.
+func tagRegexp(tag string) (*regexp.Regexp, error) {
+	if pattern, ok := tagRegexps.Load(tag); ok {
+		return pattern.(*regexp.Regexp), nil
+	}
+	pattern, err := regexp.Compile("(?i)" + tag)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag pattern: %w", err)
+	}
+	tagRegexps.Store(tag, pattern)
+	return pattern, nil
+}
+
+// tagPatternMatches reports whether a single Zendesk tag matches a rule tag.
+// Tags are compared without regard to case and through their aliases, and
+// patterns are tried against both the tag and its canonical name.
+func tagPatternMatches(alertTag, tag string) bool {
+	canonical := canonicalTag(tag)
+	switch {
+	case isTagRegexp(alertTag):
+		pattern, err := tagRegexp(alertTag)
+		if err != nil {
+			return false
+		}
+		return pattern.MatchString(tag) || pattern.MatchString(canonical)
+	case IsTagPattern(alertTag):
+		glob := strings.ToLower(alertTag)
+		if ok, _ := path.Match(glob, strings.ToLower(tag)); ok {
+			return true
+		}
+		ok, _ := path.Match(glob, canonical)
+		return ok
+	}
+	return canonical == canonicalTag(alertTag)
+}
+
+// matchingTag returns the canonical name of the first ticket tag that matches
+// the rule tag.
+func matchingTag(alertTag string, ticketTags []string) (string, bool) {
+	for _, tag := range ticketTags {
+		if tagPatternMatches(alertTag, tag) {
+			return canonicalTag(tag), true
+		}
+	}
+	return "", false
+}
+
+// alertLogTag is the tag an alert is logged under: the canonical name of the
+// ticket tag the rule matched, so dashboard stats group aliases and patterns
+// by logical tag.
+func alertLogTag(alert models.TagAlert, ticket *zendesk.Ticket) string {
+	if alert.Tag == "" || ticket == nil {
+		return alert.Tag
+	}
+	if tag, ok := matchingTag(alert.Tag, ticket.Tags); ok {
+		return tag
+	}
+	return alert.Tag
+}
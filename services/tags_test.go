@@ -0,0 +1,83 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/TylerConlee/TicketPulse/db"
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// loadTestTagAliases loads aliases for a test and clears them once it ends.
+func loadTestTagAliases(t *testing.T, aliases map[string]string) {
+	database := db.InitDB(":memory:")
+	defer database.Close()
+	for alias, canonical := range aliases {
+		assert.NoError(t, models.AddTagAlias(database, models.TagAlias{Alias: alias, Canonical: canonical}))
+	}
+	assert.NoError(t, LoadTagAliases(database))
+
+	t.Cleanup(func() {
+		tagAliases.Lock()
+		tagAliases.canonical = make(map[string]string)
+		tagAliases.Unlock()
+	})
+}
+
+func TestTagPatternMatches(t *testing.T) {
+	loadTestTagAliases(t, map[string]string{"prio_high": "urgent", "P1": "urgent"})
+
+	tests := []struct {
+		alertTag string
+		tag      string
+		matches  bool
+	}{
+		{"billing", "billing", true},
+		{"billing", "Billing", true},
+		{"billing", "billing_refund", false},
+		{"urgent", "prio_high", true},
+		{"urgent", "p1", true},
+		{"prio_high", "urgent", true},
+		{"prio_high", "p1", true},
+		{"product_*", "product_api", true},
+		{"product_*", "Product_API", true},
+		{"product_*", "products", false},
+		{"tier[12]", "tier2", true},
+		{"tier[12]", "tier3", false},
+		{"urg*", "prio_high", true},
+		{"^tier[12]$", "TIER1", true},
+		{"^tier[12]$", "tier12", false},
+		{"^urgent$", "p1", true},
+		{"^tier[$", "tier", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.matches, tagPatternMatches(tt.alertTag, tt.tag), "tagPatternMatches(%q, %q)", tt.alertTag, tt.tag)
+	}
+}
+
+func TestHasTagAliases(t *testing.T) {
+	loadTestTagAliases(t, map[string]string{"prio_high": "urgent"})
+
+	assert.True(t, hasTagAliases("urgent"))
+	assert.True(t, hasTagAliases("Prio_High"))
+	assert.False(t, hasTagAliases("billing"))
+}
+
+func TestMatchingTag(t *testing.T) {
+	loadTestTagAliases(t, map[string]string{"prio_high": "urgent"})
+
+	tag, ok := matchingTag("urg*", []string{"billing", "prio_high"})
+	assert.True(t, ok)
+	assert.Equal(t, "urgent", tag, "Expected the canonical name of the matched tag")
+
+	_, ok = matchingTag("product_*", []string{"billing"})
+	assert.False(t, ok)
+}
+
+func TestValidateTagPattern(t *testing.T) {
+	assert.NoError(t, ValidateTagPattern("billing"))
+	assert.NoError(t, ValidateTagPattern("product_*"))
+	assert.NoError(t, ValidateTagPattern("^tier[12]$"))
+	assert.Error(t, ValidateTagPattern("tier[12"))
+	assert.Error(t, ValidateTagPattern("^tier(1$"))
+}
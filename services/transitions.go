@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/nukosuke/go-zendesk/zendesk"
+)
+
+// ticketSnapshotRetention is how long a ticket's snapshot is kept after it was
+// last polled. Solved tickets close long before then, and closed tickets
+// cannot be reopened.
+const ticketSnapshotRetention = 30 * 24 * time.Hour
+
+// csatLowScore is the highest numeric satisfaction score that counts as a
+// negative rating, for accounts that rate on a numeric scale.
+const csatLowScore = 2
+
+// transitionAlertTypes are the alert types that fire on changes between polls.
+var transitionAlertTypes = []string{AlertTypePriorityRaised, AlertTypeReassigned, AlertTypeReopened, AlertTypeGroupChanged, AlertTypeTagAdded, AlertTypeCSATNegative}
+
+// priorityRanks orders Zendesk priorities from lowest to highest. Tickets
+// without a priority rank below low.
+var priorityRanks = map[string]int{"low": 1, "normal": 2, "high": 3, "urgent": 4}
+
+// TicketChange describes how a ticket changed since the previous poll.
+type TicketChange struct {
+	PriorityRaised bool
+	Reassigned     bool // Assigned to a different agent, including a first assignment
+	Reopened       bool // Moved from solved or closed back to an unsolved status
+	GroupChanged   bool // Moved to a different group
+	AddedTags      []string
+	NegativeRating bool // Received a bad satisfaction rating or a low score
+}
+
+// detectTicketChange compares a ticket against its previous snapshot.
+func detectTicketChange(previous models.TicketSnapshot, ticket zendesk.Ticket) TicketChange {
+	var change TicketChange
+	change.PriorityRaised = priorityRanks[ticket.Priority] > priorityRanks[previous.Priority]
+	change.Reassigned = ticket.AssigneeID != 0 && ticket.AssigneeID != previous.AssigneeID
+	change.Reopened = (previous.Status == "solved" || previous.Status == "closed") && !ticketSolved(ticket)
+	if groupID := ticketGroupID(ticket); groupID != 0 && groupID != previous.GroupID {
+		change.GroupChanged = true
+	}
+
+	previousTags := make(map[string]bool)
+	for _, tag := range previous.TagList() {
+		previousTags[tag] = true
+	}
+	for _, tag := range ticket.Tags {
+		if !previousTags[tag] {
+			change.AddedTags = append(change.AddedTags, tag)
+		}
+	}
+
+	change.NegativeRating = negativeRating(ticket) && satisfactionScore(ticket) != previous.SatisfactionScore
+	return change
+}
+
+// satisfactionScore returns the ticket's satisfaction rating score, or an
+// empty string when it has not been offered a survey.
+func satisfactionScore(ticket zendesk.Ticket) string {
+	if ticket.SatisfactionRating == nil {
+		return ""
+	}
+	return ticket.SatisfactionRating.Score
+}
+
+// negativeRating reports whether the ticket was rated bad or given a low
+// numeric score.
+func negativeRating(ticket zendesk.Ticket) bool {
+	score := satisfactionScore(ticket)
+	if strings.HasPrefix(score, "bad") {
+		return true
+	}
+	value, err := strconv.Atoi(score)
+	return err == nil && value > 0 && value <= csatLowScore
+}
+
+// Fires reports whether the change fires rules of a transition alert type.
+// Tag and group rules fire for any added tag or group move.
+func (c TicketChange) Fires(alertType string) bool {
+	switch alertType {
+	case AlertTypePriorityRaised:
+		return c.PriorityRaised
+	case AlertTypeReassigned:
+		return c.Reassigned
+	case AlertTypeReopened:
+		return c.Reopened
+	case AlertTypeGroupChanged:
+		return c.GroupChanged
+	case AlertTypeTagAdded:
+		return len(c.AddedTags) > 0
+	case AlertTypeCSATNegative:
+		return c.NegativeRating
+	}
+	return false
+}
+
+// firesRule reports whether the change fires a transition rule. Tag added
+// rules only fire when the rule's own tag was added, and group rules only when
+// the ticket moved to the rule's group.
+func (c TicketChange) firesRule(alert models.TagAlert, ticket zendesk.Ticket) bool {
+	switch alert.AlertType {
+	case AlertTypeTagAdded:
+		if alert.Tag == "" {
+			return len(c.AddedTags) > 0
+		}
+		return tagMatches(alert.Tag, c.AddedTags)
+	case AlertTypeGroupChanged:
+		return c.GroupChanged && (alert.GroupID == 0 || alert.GroupID == ticketGroupID(ticket))
+	}
+	return c.Fires(alert.AlertType)
+}
+
+// isTransitionAlertType reports whether the alert type fires on changes between polls.
+func isTransitionAlertType(alertType string) bool {
+	for _, transition := range transitionAlertTypes {
+		if alertType == transition {
+			return true
+		}
+	}
+	return false
+}
+
+// ticketGroupID returns the ticket's group, or zero when it has none.
+func ticketGroupID(ticket zendesk.Ticket) int64 {
+	groupID, _ := ticket.GroupID.Int64()
+	return groupID
+}
+
+// detectTicketChanges compares the polled tickets against their snapshots
+// from the previous poll. Tickets that have not been polled before have
+// nothing to compare against, so the only change reported for them is a
+// negative rating that arrived since the last poll; ratings update the ticket
+// and are often left on tickets that were solved long ago.
+func detectTicketChanges(db db.Database, tickets []zendesk.Ticket) map[int64]TicketChange {
+	changes := make(map[int64]TicketChange)
+
+	ticketIDs := make([]int64, 0, len(tickets))
+	for _, ticket := range tickets {
+		ticketIDs = append(ticketIDs, ticket.ID)
+	}
+	snapshots, err := models.GetTicketSnapshots(db, ticketIDs)
+	if err != nil {
+		log.Println("Error fetching ticket snapshots:", err)
+		return changes
+	}
+
+	for _, ticket := range tickets {
+		if previous, ok := snapshots[ticket.ID]; ok {
+			changes[ticket.ID] = detectTicketChange(previous, ticket)
+		} else if negativeRating(ticket) && isUpdatedTicket(ticket) {
+			changes[ticket.ID] = TicketChange{NegativeRating: true}
+		}
+	}
+	return changes
+}
+
+// saveTicketSnapshots records the polled tickets' state for the next poll to
+// compare against. Snapshots of tickets that have not been polled for a while
+// are pruned.
+func saveTicketSnapshots(ctx context.Context, db db.Database, tickets []zendesk.Ticket) {
+	for _, ticket := range tickets {
+		snapshot := models.TicketSnapshot{
+			TicketID:   ticket.ID,
+			Status:     ticket.Status,
+			Priority:   ticket.Priority,
+			AssigneeID: ticket.AssigneeID,
+			GroupID:    ticketGroupID(ticket),
+			Tags:       strings.Join(ticket.Tags, " "),
+
+			SatisfactionScore: satisfactionScore(ticket),
+		}
+		if ticket.UpdatedAt != nil {
+			snapshot.UpdatedAt = sql.NullTime{Time: *ticket.UpdatedAt, Valid: true}
+		}
+		if err := models.SaveTicketSnapshot(ctx, db, snapshot); err != nil {
+			log.Printf("Failed to save snapshot of Ticket #%d: %v", ticket.ID, err)
+		}
+	}
+
+	if err := models.PruneTicketSnapshots(ctx, db, time.Now().Add(-ticketSnapshotRetention)); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/nukosuke/go-zendesk/zendesk"
+)
+
+// Volume spike settings.
+const (
+	defaultSpikeWindow = time.Hour
+	spikeBaselineWeeks = 4 // Weeks of the same hour-of-week averaged into the baseline
+	minSpikeTickets    = 3 // Fewer arrivals than this are never a spike, however quiet it usually is
+	maxSpikeTickets    = 10
+	// ticketArrivalRetention keeps enough arrivals for a full baseline of the longest window.
+	ticketArrivalRetention = (spikeBaselineWeeks + 1) * 7 * 24 * time.Hour
+)
+
+// VolumeSpike is a rise in new tickets matching a volume_spike rule.
+type VolumeSpike struct {
+	Criteria  string // Describes the tickets counted, such as "tagged billing"
+	Count     int
+	Baseline  float64 // Average arrivals in the same window of past weeks
+	Window    time.Duration
+	TicketIDs []int64 // The most recent tickets in the spike, newest first
+}
+
+// Label describes the spike, such as "12 new tickets tagged billing in the
+// last hour, usually 3".
+func (s VolumeSpike) Label() string {
+	tickets := "new tickets"
+	if s.Criteria != "" {
+		tickets += " " + s.Criteria
+	}
+	window := "the last " + models.DurationLabel(s.Window)
+	if s.Window == time.Hour {
+		window = "the last hour"
+	}
+	return fmt.Sprintf("%d %s in %s, usually %s", s.Count, tickets, window, formatBaseline(s.Baseline))
+}
+
+// formatBaseline rounds a baseline to one decimal place, dropping a trailing ".0".
+func formatBaseline(baseline float64) string {
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", baseline), ".0")
+}
+
+// spikeWindow returns how far back a volume_spike rule counts arrivals.
+func spikeWindow(rule models.TagAlert) time.Duration {
+	if rule.ThresholdMinutes > 0 {
+		return time.Duration(rule.ThresholdMinutes) * time.Minute
+	}
+	return defaultSpikeWindow
+}
+
+// recordTicketArrivals stores the polled tickets that were created recently
+// enough to count towards volume baselines. since is when the poll's search
+// for new tickets started from; arrivals are complete from the first poll on,
+// though tickets created before it are stored too when they are still open.
+func recordTicketArrivals(ctx context.Context, db db.Database, tickets []zendesk.Ticket, since time.Time) {
+	if err := models.StartRecordingTicketArrivals(db, since); err != nil {
+		log.Println(err)
+		return
+	}
+	cutoff := time.Now().Add(-ticketArrivalRetention)
+	for _, ticket := range tickets {
+		if ticket.CreatedAt == nil || ticket.CreatedAt.Before(cutoff) {
+			continue
+		}
+		arrival := models.TicketArrival{
+			TicketID:       ticket.ID,
+			Tags:           strings.Join(ticket.Tags, " "),
+			OrganizationID: ticket.OrganizationID,
+			RequesterID:    ticket.RequesterID,
+			CreatedAt:      *ticket.CreatedAt,
+		}
+		if err := models.RecordTicketArrival(ctx, db, arrival); err != nil {
+			log.Println(err)
+			return
+		}
+	}
+	if err := models.PruneTicketArrivals(ctx, db, cutoff); err != nil {
+		log.Println(err)
+	}
+}
+
+// processVolumeSpikes compares each volume_spike rule's recent arrivals with
+// the same window in each of the previous weeks, and alerts when arrivals
+// reach the rule's factor of that baseline. A rule fires at most once per
+// window.
+func processVolumeSpikes(ctx context.Context, db db.Database, notificationService *NotificationService) {
+	rules, err := models.GetAllTagAlerts(db)
+	if err != nil {
+		fmt.Println("Error fetching user alerts:", err)
+		return
+	}
+	var spikeRules []models.TagAlert
+	for _, rule := range rules {
+		if rule.AlertType == AlertTypeVolumeSpike && rule.SpikeFactor > 0 {
+			spikeRules = append(spikeRules, rule)
+		}
+	}
+	if len(spikeRules) == 0 {
+		return
+	}
+
+	now := time.Now()
+	recordedSince, err := models.TicketArrivalsRecordedSince(db)
+	if err != nil || recordedSince.IsZero() {
+		if err != nil {
+			log.Println(err)
+		}
+		return
+	}
+	arrivals, err := models.GetTicketArrivalsSince(db, now.Add(-ticketArrivalRetention))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	customers := NewCustomerResolver(db, nil)
+
+	for _, rule := range spikeRules {
+		var matching []models.TicketArrival
+		for _, arrival := range arrivals {
+			if ruleMatches(rule, arrival.TagList(), arrival.OrganizationID, arrival.RequesterID, customers) {
+				matching = append(matching, arrival)
+			}
+		}
+
+		spike, ok := detectVolumeSpike(rule, matching, recordedSince, now)
+		if !ok {
+			continue
+		}
+
+		last, err := models.LastVolumeSpike(db, rule.ID)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if last != nil && now.Sub(last.CreatedAt) < spike.Window {
+			continue
+		}
+		if err := models.RecordVolumeSpike(ctx, db, models.VolumeSpikeRecord{RuleID: rule.ID, TicketCount: spike.Count, Baseline: spike.Baseline}); err != nil {
+			log.Println(err)
+		}
+		sendVolumeSpikeAlert(ctx, db, rule, spike, notificationService)
+	}
+
+	if err := models.PruneVolumeSpikes(ctx, db, now.Add(-ticketArrivalRetention)); err != nil {
+		log.Println(err)
+	}
+}
+
+// detectVolumeSpike reports whether the rule's matching arrivals in the
+// window ending at now reach its factor of the baseline. Only weeks whose
+// window falls after arrivals started being recorded count towards the
+// baseline, so tickets stored because they were still open when recording
+// began do not pass for a quiet week. A rule with no such week yet is still
+// learning and does not fire.
+func detectVolumeSpike(rule models.TagAlert, matching []models.TicketArrival, recordedSince, now time.Time) (VolumeSpike, bool) {
+	window := spikeWindow(rule)
+	current := arrivalsBetween(matching, now.Add(-window), now)
+	weeks, total := 0, 0
+	for week := 1; week <= spikeBaselineWeeks; week++ {
+		end := now.Add(-time.Duration(week) * 7 * 24 * time.Hour)
+		start := end.Add(-window)
+		if start.Before(recordedSince) {
+			break
+		}
+		total += len(arrivalsBetween(matching, start, end))
+		weeks++
+	}
+	if weeks == 0 || len(current) < minSpikeTickets {
+		return VolumeSpike{}, false
+	}
+	baseline := float64(total) / float64(weeks)
+	if float64(len(current)) < rule.SpikeFactor*math.Max(baseline, 1) {
+		return VolumeSpike{}, false
+	}
+
+	spike := VolumeSpike{Criteria: ruleCriteria(rule), Count: len(current), Baseline: baseline, Window: window}
+	for i := len(current) - 1; i >= 0 && len(spike.TicketIDs) < maxSpikeTickets; i-- {
+		spike.TicketIDs = append(spike.TicketIDs, current[i].TicketID)
+	}
+	return spike, true
+}
+
+// arrivalsBetween returns the arrivals created in [start, end), oldest first.
+func arrivalsBetween(arrivals []models.TicketArrival, start, end time.Time) []models.TicketArrival {
+	var between []models.TicketArrival
+	for _, arrival := range arrivals {
+		if !arrival.CreatedAt.Before(start) && arrival.CreatedAt.Before(end) {
+			between = append(between, arrival)
+		}
+	}
+	return between
+}
+
+// sendVolumeSpikeAlert delivers a volume spike alert, routing on-call rules
+// to whoever is on call.
+func sendVolumeSpikeAlert(ctx context.Context, db db.Database, rule models.TagAlert, spike VolumeSpike, notificationService *NotificationService) {
+	log.Printf("Volume spike for rule %d: %s", rule.ID, spike.Label())
+	notification := Notification{
+		AlertType: AlertTypeVolumeSpike,
+		Recipient: rule.User,
+		Rule:      &rule,
+		Spike:     &spike,
+	}
+	if found, err := resolveOnCall(db, &notification); err != nil || !found {
+		if err != nil {
+			fmt.Printf("Failed to resolve on-call for %s: %v\n", rule.OnCallScheduleName, err)
+		}
+		fallback := rule
+		fallback.OnCallScheduleID = sql.NullInt64{}
+		notification.Rule = &fallback
+	}
+	if err := notificationService.Dispatch(ctx, notification); err != nil {
+		fmt.Printf("Failed to deliver volume spike alert for rule %d: %v\n", rule.ID, err)
+	}
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArrivalsBetween(t *testing.T) {
+	start := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	arrivals := []models.TicketArrival{
+		{TicketID: 1, CreatedAt: start.Add(-time.Second)},
+		{TicketID: 2, CreatedAt: start},
+		{TicketID: 3, CreatedAt: start.Add(59 * time.Minute)},
+		{TicketID: 4, CreatedAt: start.Add(time.Hour)},
+	}
+
+	var ids []int64
+	for _, arrival := range arrivalsBetween(arrivals, start, start.Add(time.Hour)) {
+		ids = append(ids, arrival.TicketID)
+	}
+	assert.Equal(t, []int64{2, 3}, ids, "Expected the window to include its start and exclude its end")
+}
+
+func TestDetectVolumeSpike(t *testing.T) {
+	now := time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC)
+	week := 7 * 24 * time.Hour
+	rule := models.TagAlert{ID: 1, AlertType: AlertTypeVolumeSpike, SpikeFactor: 3}
+
+	// arrivalsAt returns count arrivals within the hour window ending weeksAgo weeks before now
+	arrivalsAt := func(weeksAgo, count int) []models.TicketArrival {
+		var arrivals []models.TicketArrival
+		end := now.Add(-time.Duration(weeksAgo) * week)
+		for i := 0; i < count; i++ {
+			arrivals = append(arrivals, models.TicketArrival{TicketID: int64(weeksAgo*100 + i), CreatedAt: end.Add(-time.Duration(i+1) * time.Minute)})
+		}
+		return arrivals
+	}
+	join := func(parts ...[]models.TicketArrival) []models.TicketArrival {
+		var arrivals []models.TicketArrival
+		for _, part := range parts {
+			arrivals = append(arrivals, part...)
+		}
+		return arrivals
+	}
+
+	tests := []struct {
+		name          string
+		arrivals      []models.TicketArrival
+		recordedSince time.Time
+		fires         bool
+		baseline      float64
+	}{
+		{
+			name:          "still learning",
+			arrivals:      arrivalsAt(0, 10),
+			recordedSince: now.Add(-3 * 24 * time.Hour),
+		},
+		{
+			name:          "weeks before recording started are left out",
+			arrivals:      join(arrivalsAt(0, 4), arrivalsAt(1, 4), arrivalsAt(3, 1)),
+			recordedSince: now.Add(-8 * 24 * time.Hour),
+		},
+		{
+			name:          "spike against a partial baseline",
+			arrivals:      join(arrivalsAt(0, 6), arrivalsAt(1, 2), arrivalsAt(2, 2)),
+			recordedSince: now.Add(-15 * 24 * time.Hour),
+			fires:         true,
+			baseline:      2,
+		},
+		{
+			name:          "spike against a full baseline",
+			arrivals:      join(arrivalsAt(0, 6), arrivalsAt(1, 2), arrivalsAt(2, 1), arrivalsAt(3, 3), arrivalsAt(4, 2)),
+			recordedSince: now.Add(-60 * 24 * time.Hour),
+			fires:         true,
+			baseline:      2,
+		},
+		{
+			name:          "usual volume",
+			arrivals:      join(arrivalsAt(0, 5), arrivalsAt(1, 2), arrivalsAt(2, 2), arrivalsAt(3, 2), arrivalsAt(4, 2)),
+			recordedSince: now.Add(-60 * 24 * time.Hour),
+		},
+		{
+			name:          "too few tickets to be a spike",
+			arrivals:      arrivalsAt(0, minSpikeTickets-1),
+			recordedSince: now.Add(-60 * 24 * time.Hour),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spike, fires := detectVolumeSpike(rule, tt.arrivals, tt.recordedSince, now)
+			assert.Equal(t, tt.fires, fires)
+			if tt.fires {
+				assert.Equal(t, tt.baseline, spike.Baseline)
+				assert.Equal(t, time.Hour, spike.Window)
+			}
+		})
+	}
+}
+
+func TestRecordTicketArrivals_KeepsRecordingStart(t *testing.T) {
+	database := db.InitDB(":memory:")
+	defer database.Close()
+
+	first := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	recordTicketArrivals(context.Background(), database, nil, first)
+	recordTicketArrivals(context.Background(), database, nil, first.Add(5*time.Minute))
+
+	since, err := models.TicketArrivalsRecordedSince(database)
+	assert.NoError(t, err)
+	assert.True(t, first.Equal(since))
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/TylerConlee/TicketPulse/db"
+	"github.com/TylerConlee/TicketPulse/models"
+	"github.com/nukosuke/go-zendesk/zendesk"
+)
+
+// watchAlertKey identifies an alert a user received about a ticket during one poll.
+type watchAlertKey struct {
+	userID    int
+	ticketID  int64
+	alertType string
+}
+
+// watchedAlertTypes are the alerts sent for watched tickets. Comments update
+// the ticket, so they arrive as update alerts.
+var watchedAlertTypes = []string{AlertTypeTicketUpdate, AlertTypeSLABreach}
+
+// processWatchedTickets alerts the users watching each polled ticket. Solved
+// and closed tickets are removed from every watchlist instead. Users who were
+// already alerted about the ticket by their own rules, or who muted it, are
+// skipped.
+func processWatchedTickets(ctx context.Context, db db.Database, tickets []zendesk.Ticket, slaData map[int64]SLAInfo, mutes map[int64][]models.TicketMute, alerted map[watchAlertKey]bool, notificationService *NotificationService) {
+	watchers, err := models.GetTicketWatchers(db)
+	if err != nil || len(watchers) == 0 {
+		if err != nil {
+			log.Println("Error fetching ticket watchers:", err)
+		}
+		return
+	}
+
+	seen := make(map[int64]bool)
+	for _, ticket := range tickets {
+		userIDs := watchers[ticket.ID]
+		if len(userIDs) == 0 || seen[ticket.ID] {
+			continue
+		}
+		seen[ticket.ID] = true
+
+		if ticketSolved(ticket) {
+			log.Printf("Ticket #%d is %s, removing it from %d watchlists", ticket.ID, ticket.Status, len(userIDs))
+			if err := models.DeleteTicketWatches(ctx, db, ticket.ID); err != nil {
+				log.Println("Error removing solved ticket from watchlists:", err)
+			}
+			continue
+		}
+
+		for _, alertType := range watchedAlertTypes {
+			evaluation := evaluateAlertType(alertType, ticket, slaData)
+			if !evaluation.Fires {
+				continue
+			}
+			for _, userID := range userIDs {
+				key := watchAlertKey{userID, ticket.ID, alertType}
+				if alerted[key] || ticketMuted(mutes[ticket.ID], models.TagAlert{UserID: userID}) {
+					continue
+				}
+				if alertType == AlertTypeSLABreach && slaAlertSent(ctx, db, userID, 0, ticket, slaData[ticket.ID], alertType) {
+					continue
+				}
+				alerted[key] = true
+
+				log.Printf("ALERT: [%s] Ticket #%d (Title: '%s') triggered an alert for watcher %d\n", alertType, ticket.ID, ticket.Subject, userID)
+				ticket := ticket
+				slaInfo := slaData[ticket.ID]
+				notification := Notification{
+					AlertType: alertType,
+					Recipient: models.User{ID: userID},
+					Ticket:    &ticket,
+					SLA:       &slaInfo,
+					SLALabel:  evaluation.SLALabel,
+				}
+				if err := notificationService.dispatchDirect(ctx, notification); err != nil {
+					fmt.Printf("Failed to deliver watch alert for Ticket #%d: %v\n", ticket.ID, err)
+				}
+			}
+		}
+	}
+}
+
+// ticketSolved reports whether the ticket no longer needs watching.
+func ticketSolved(ticket zendesk.Ticket) bool {
+	return ticket.Status == "solved" || ticket.Status == "closed"
+}
+
+// pulseCommand runs a /pulse slash command for the TicketPulse user linked to
+// the Slack user and returns the reply. The channel is where the command was
+// run, for commands that post there.
+func pulseCommand(ctx context.Context, db db.Database, incidents *IncidentService, slackUserID, channelID, text string) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return pulseUsage
+	}
+
+	user, err := models.GetUserBySlackID(db, slackUserID)
+	if err != nil {
+		log.Printf("Failed to look up Slack user %s: %v", slackUserID, err)
+		return "Something went wrong looking up your TicketPulse account."
+	}
+	if user.ID == 0 {
+		return "Your Slack account isn't linked to a TicketPulse user."
+	}
+
+	switch strings.ToLower(fields[0]) {
+	case "watch", "unwatch":
+		if len(fields) != 2 {
+			return pulseUsage
+		}
+		ticketID, err := strconv.ParseInt(strings.TrimPrefix(fields[1], "#"), 10, 64)
+		if err != nil || ticketID <= 0 {
+			return fmt.Sprintf("%q isn't a ticket ID.", fields[1])
+		}
+		if strings.EqualFold(fields[0], "unwatch") {
+			if err := models.UnwatchTicket(ctx, db, user.ID, ticketID); err != nil {
+				log.Println("Error unwatching ticket:", err)
+				return "Something went wrong removing the ticket from your watchlist."
+			}
+			return fmt.Sprintf("You're no longer watching ticket #%d.", ticketID)
+		}
+		if err := models.WatchTicket(ctx, db, user.ID, ticketID); err != nil {
+			log.Println("Error watching ticket:", err)
+			return "Something went wrong adding the ticket to your watchlist."
+		}
+		return fmt.Sprintf("You're now watching ticket #%d. You'll get its update and SLA alerts until it's solved.", ticketID)
+	case "watching", "watchlist":
+		watches, err := models.GetWatchedTickets(db, user.ID)
+		if err != nil {
+			log.Println("Error fetching watched tickets:", err)
+			return "Something went wrong fetching your watchlist."
+		}
+		if len(watches) == 0 {
+			return "You aren't watching any tickets."
+		}
+		var sb strings.Builder
+		sb.WriteString("You're watching:\n")
+		for _, watch := range watches {
+			sb.WriteString(fmt.Sprintf("• %s\n", slackTicketLink(db, watch.TicketID)))
+		}
+		return sb.String()
+	case "incident", "incidents":
+		return incidentCommand(ctx, incidents, user, channelID, text)
+	}
+	return pulseUsage
+}
+
+const pulseUsage = "Usage: `/pulse watch <ticket ID>`, `/pulse unwatch <ticket ID>`, `/pulse watching` or `/pulse incidents`"
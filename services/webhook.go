@@ -0,0 +1,139 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/TylerConlee/TicketPulse/db"
+)
+
+// WebhookNotifier POSTs a JSON payload to the user's configured webhook URL.
+type WebhookNotifier struct {
+	DB     db.Database
+	client *http.Client
+}
+
+// WebhookPayload is the JSON body sent to webhook endpoints.
+type WebhookPayload struct {
+	AlertType string `json:"alert_type"`
+	Subject   string `json:"subject"`
+	Text      string `json:"text"`
+	TicketID  int64  `json:"ticket_id,omitempty"`
+	TicketURL string `json:"ticket_url,omitempty"`
+	Tag       string `json:"tag,omitempty"`
+	SLALabel  string `json:"sla_label,omitempty"`
+	Timestamp string `json:"timestamp"`
+}
+
+func NewWebhookNotifier(db db.Database) *WebhookNotifier {
+	// Webhooks are user supplied, so the server refuses to connect to its own
+	// loopback and link-local addresses, such as cloud metadata endpoints, even
+	// when a hostname resolves to one or a webhook redirects there
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: func(network, address string, c syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		if blockedWebhookIP(net.ParseIP(host)) {
+			return fmt.Errorf("webhook address %s is not allowed", host)
+		}
+		return nil
+	}}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	return &WebhookNotifier{
+		DB:     db,
+		client: &http.Client{Timeout: 10 * time.Second, Transport: transport},
+	}
+}
+
+// ValidateWebhookURL checks that a webhook URL is an absolute http or https
+// URL that does not point at the server itself.
+func ValidateWebhookURL(webhookURL string) error {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return errors.New("the webhook URL is not a valid URL")
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return errors.New("the webhook URL must start with https:// or http://")
+	}
+	host := u.Hostname()
+	if host == "" {
+		return errors.New("the webhook URL must include a host")
+	}
+	if strings.EqualFold(host, "localhost") || blockedWebhookIP(net.ParseIP(host)) {
+		return errors.New("the webhook URL cannot point at the TicketPulse server")
+	}
+	return nil
+}
+
+// blockedWebhookIP reports whether webhooks may not be sent to the address.
+func blockedWebhookIP(ip net.IP) bool {
+	return ip != nil && (ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified())
+}
+
+func (w *WebhookNotifier) Channel() string { return ChannelWebhook }
+
+func (w *WebhookNotifier) Label() string { return "Webhook" }
+
+func (w *WebhookNotifier) Personal() bool { return true }
+
+func (w *WebhookNotifier) Destination(n Notification) string { return n.Recipient.WebhookURL.String }
+
+func (w *WebhookNotifier) Supports(alertType string) bool { return true }
+
+func (w *WebhookNotifier) Notify(ctx context.Context, n Notification) error {
+	if !n.Recipient.WebhookURL.Valid || n.Recipient.WebhookURL.String == "" {
+		return fmt.Errorf("user %d has no webhook URL", n.Recipient.ID)
+	}
+	// URLs saved before they were validated are checked again here
+	if err := ValidateWebhookURL(n.Recipient.WebhookURL.String); err != nil {
+		return err
+	}
+
+	payload := WebhookPayload{
+		AlertType: n.AlertType,
+		Subject:   notificationSubject(n),
+		Text:      notificationBody(w.DB, n),
+		SLALabel:  n.SLALabel,
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+	if n.Ticket != nil {
+		payload.TicketID = n.Ticket.ID
+		payload.TicketURL = ticketURL(w.DB, n.Ticket.ID)
+	}
+	if n.Rule != nil {
+		payload.Tag = n.Rule.Tag
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", n.Recipient.WebhookURL.String, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %s", resp.Status)
+	}
+	return nil
+}
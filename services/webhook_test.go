@@ -0,0 +1,35 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateWebhookURL(t *testing.T) {
+	tests := []struct {
+		url   string
+		valid bool
+	}{
+		{"https://example.com/hooks/ticketpulse", true},
+		{"http://hooks.internal.example.com:8080/alerts", true},
+		{"file:///etc/passwd", false},
+		{"gopher://example.com", false},
+		{"example.com/hooks", false},
+		{"https://", false},
+		{"http://localhost:8080/", false},
+		{"http://127.0.0.1/", false},
+		{"http://[::1]/", false},
+		{"http://169.254.169.254/latest/meta-data/", false},
+		{"http://0.0.0.0/", false},
+		{"://bad", false},
+	}
+	for _, tt := range tests {
+		err := ValidateWebhookURL(tt.url)
+		if tt.valid {
+			assert.NoError(t, err, tt.url)
+		} else {
+			assert.Error(t, err, tt.url)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -18,6 +19,27 @@ const (
 	AlertTypeNewTicket    = "new_ticket"
 	AlertTypeTicketUpdate = "ticket_update"
 	AlertTypeSLABreach    = "sla_breach"
+	// SLA risk alerts fire for tickets predicted to breach before the breach thresholds are reached
+	AlertTypeSLARisk = "sla_risk"
+
+	// Transition alerts fire when a ticket's fields change between polls
+	AlertTypePriorityRaised = "priority_raised"
+	AlertTypeReassigned     = "reassigned"
+	AlertTypeReopened       = "reopened"
+	AlertTypeGroupChanged   = "group_changed"
+	AlertTypeTagAdded       = "tag_added"
+	AlertTypeCSATNegative   = "csat_negative"
+
+	// Age alerts fire when a ticket has been in a state for longer than the rule's threshold
+	AlertTypeUnassigned     = "unassigned"
+	AlertTypeNoAgentReply   = "no_agent_reply"
+	AlertTypePendingTooLong = "pending_too_long"
+
+	// Volume spike alerts fire when more tickets matching the rule arrive than usual
+	AlertTypeVolumeSpike = "volume_spike"
+
+	// Possible duplicate alerts fire when a new ticket resembles a recent open ticket from the same customer
+	AlertTypePossibleDuplicate = "possible_duplicate"
 )
 
 type ZendeskClient struct {
@@ -54,8 +76,9 @@ type SatisfactionRating struct {
 
 // User represents a Zendesk user.
 type User struct {
-	ID   int64  `json:"id"`
-	Name string `json:"name"`
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
 }
 
 // Organization represents a Zendesk organization.
@@ -96,9 +119,10 @@ func NewZendeskClient(db db.Database) (*ZendeskClient, error) {
 }
 
 // StartZendeskPolling handles periodic polling of tickets from Zendesk.
-func StartZendeskPolling(ctx context.Context, db db.Database, sseServer *middlewares.SSEServer, slackService *SlackService) {
+func StartZendeskPolling(ctx context.Context, db db.Database, sseServer *middlewares.SSEServer, notificationService *NotificationService, incidentService *IncidentService) {
 	var lastPollTime = time.Now().Add(-5 * time.Minute) // Start 5 minutes before now
 	broadcastStatusUpdates(sseServer, "zendesk", "connected", "")
+	pagerDutyService := NewPagerDutyService(db)
 
 	for {
 		zendeskClient, err := NewZendeskClient(db)
@@ -109,6 +133,9 @@ func StartZendeskPolling(ctx context.Context, db db.Database, sseServer *middlew
 			continue
 		}
 
+		// The next poll searches from when this one started, so nothing that
+		// changes while this poll is processing is missed
+		pollStartedAt := time.Now()
 		middlewares.AddGlobalNotification(sseServer, "Refreshing Zendesk tickets", "Requesting tickets from Zendesk", "info")
 		log.Println("Requesting tickets from Zendesk...")
 		slaTickets, slaData, err := zendeskClient.SearchTicketsWithActiveSLA()
@@ -129,19 +156,57 @@ func StartZendeskPolling(ctx context.Context, db db.Database, sseServer *middlew
 		}
 		log.Println("Fetched", len(newUpdatedTickets), "new/updated tickets")
 
+		if err := LoadTagAliases(db); err != nil {
+			log.Println("Error loading tag aliases:", err)
+		}
+
 		allTickets := append(slaTickets, newUpdatedTickets...)
 		if len(allTickets) == 0 {
 			log.Println("No tickets to process")
 		} else {
-			processTickets(ctx, db, allTickets, slaData, sseServer, slackService)
+			changes := detectTicketChanges(db, allTickets)
+			releaseTicketMutes(ctx, db, zendeskClient, allTickets, slaData)
+			risks := NewSLARiskPredictor(db)
+			processTickets(ctx, db, allTickets, slaData, changes, lastPollTime, risks, incidentService, sseServer, notificationService, pagerDutyService)
+			recordTicketActivity(ctx, db, allTickets, slaData, changes)
+			pagerDutyService.ResolveFinishedIncidents(ctx, zendeskClient, allTickets, slaData)
+			saveTicketSnapshots(ctx, db, allTickets)
+			recordSeenTags(ctx, db, allTickets)
+			recordTicketArrivals(ctx, db, allTickets, lastPollTime)
+			recordTicketDurations(ctx, db, zendeskClient, allTickets)
+			pruneTicketAssignments(ctx, db)
+			recordSLARisks(ctx, db, risks, slaTickets, slaData)
 		}
+		processVolumeSpikes(ctx, db, notificationService)
+		syncTagCatalog(ctx, db, zendeskClient)
+		processAgeRules(ctx, db, zendeskClient, notificationService)
+		processShiftHandoffs(ctx, db, zendeskClient, notificationService)
 
-		lastPollTime = time.Now()
+		lastPollTime = pollStartedAt
 		time.Sleep(5 * time.Minute)
 	}
 }
 
-func processTickets(ctx context.Context, db db.Database, tickets []zendesk.Ticket, slaData map[int64]SLAInfo, sseServer *middlewares.SSEServer, slackService *SlackService) {
+// processTickets sends the alerts each ticket fires and assigns new tickets
+// for rules that assign them. Keyword rules also search the public comments
+// added since lastPollTime. Tickets that belong to an active incident are
+// grouped into its Slack threads instead of alerting, but are still assigned
+// and paged.
+func processTickets(ctx context.Context, db db.Database, tickets []zendesk.Ticket, slaData map[int64]SLAInfo, changes map[int64]TicketChange, lastPollTime time.Time, risks *SLARiskPredictor, incidentService *IncidentService, sseServer *middlewares.SSEServer, notificationService *NotificationService, pagerDutyService *PagerDutyService) {
+	// Alert logs feed the dashboard's sent alert statistics, so they are not written in shadow mode
+	shadowMode := ShadowModeEnabled(db)
+
+	mutes, err := models.GetActiveTicketMutes(db)
+	if err != nil {
+		fmt.Println("Error fetching ticket mutes:", err)
+	}
+	// Users already alerted by their own rules are not alerted again for tickets they watch
+	alerted := make(map[watchAlertKey]bool)
+	customers := NewCustomerResolver(db, nil)
+	comments := NewCommentResolver(db, nil, lastPollTime)
+	duplicates := NewDuplicateResolver(db, nil)
+	grouped := incidentService.GroupTickets(ctx, tickets, customers)
+	assigner := NewTicketAssigner(db, nil)
 
 	for _, ticket := range tickets {
 		userAlerts, err := models.GetAllTagAlerts(db)
@@ -151,99 +216,238 @@ func processTickets(ctx context.Context, db db.Database, tickets []zendesk.Ticke
 		}
 
 		for _, alert := range userAlerts {
-			if tagMatches(alert.Tag, ticket.Tags) {
-				var sendAlert bool
-				var slaLabel string
-
-				switch alert.AlertType {
-				case AlertTypeNewTicket:
-					sendAlert = isNewTicket(ticket)
-				case AlertTypeTicketUpdate:
-					sendAlert = isUpdatedTicket(ticket)
-				case AlertTypeSLABreach:
-					if slaInfo, ok := slaData[ticket.ID]; ok {
-						if label, matches := slaConditionMatches(slaInfo.PolicyMetrics); matches {
-							// Correct the argument types and pass *sql.DB
-							existingAlert, err := models.GetSLAAlertCache(ctx, db, int(alert.User.ID), int(ticket.ID), alert.AlertType)
-							if err == nil && existingAlert.BreachAt != slaInfo.PolicyMetrics[0].BreachAt {
-								models.ClearSLAAlertCache(ctx, db, existingAlert.ID)
-							} else if err == nil {
-								continue
-							}
-
-							sendAlert = true
-							slaLabel = label
-
-							// Log the SLA alert
-							logEntry := models.SLAAlertCache{
-								UserID:    int64(alert.User.ID), // Use int type
-								TicketID:  int64(ticket.ID),     // Use int type
-								AlertType: alert.AlertType,
-								BreachAt:  slaInfo.PolicyMetrics[0].BreachAt,
-							}
-							if err := models.CreateSLAAlertCache(ctx, db, logEntry); err != nil {
-								fmt.Printf("Failed to log SLA alert for Ticket #%d: %v\n", ticket.ID, err)
-							}
-						}
-					}
+			evaluation := evaluateRule(alert, ticket, slaData, changes[ticket.ID], customers, comments, duplicates, risks)
+			if !evaluation.Fires {
+				continue
+			}
+			// Assigning is the rule's action rather than an alert, so mutes do not stop it
+			if alert.AssignTeamID.Valid && !shadowMode {
+				if assigneeID, ok := assigner.Assign(ctx, alert, ticket); ok {
+					ticket.AssigneeID = assigneeID
 				}
+			}
+			if ticketMuted(mutes[ticket.ID], alert) {
+				log.Printf("Ticket #%d is muted for rule %d, skipping alert", ticket.ID, alert.ID)
+				continue
+			}
 
-				if sendAlert {
-					logAlert(alert, ticket, alert.AlertType)
-					timestamp := time.Now().Format("2006-01-02 15:04:05")
-					alertLog := models.AlertLog{
-						UserID:    int64(alert.User.ID), // Use int type
-						TicketID:  int64(ticket.ID),     // Use int type
-						Tag:       alert.Tag,
-						AlertType: alert.AlertType,
-						Timestamp: timestamp,
-					}
-					models.CreateAlertLog(ctx, db, alertLog)
-					slaInfo := slaData[ticket.ID]
-					err := slackService.SendSlackMessage(alert.SlackChannelID, alert.AlertType, slaLabel, ticket, &slaInfo, alert.Tag)
-					if err != nil {
-						fmt.Printf("Failed to send Slack message for Ticket #%d: %v\n", ticket.ID, err)
-					}
-				}
+			// Paging is deduplicated on the PagerDuty incident rather than the
+			// alert cache, so a ticket alerted on hours before its breach is
+			// still paged once it reaches a paging stage
+			if err := pagerDutyService.pageSLABreach(ctx, alert, ticket, evaluation); err != nil {
+				fmt.Printf("Failed to trigger PagerDuty incident for Ticket #%d: %v\n", ticket.ID, err)
+			}
+
+			// Grouped tickets are tracked in the incident's threads instead of alerting one by one
+			if grouped[ticket.ID] {
+				continue
+			}
+
+			if (alert.AlertType == AlertTypeSLABreach || alert.AlertType == AlertTypeSLARisk) && slaAlertSent(ctx, db, alert.UserID, int(alert.TeamID.Int64), ticket, slaData[ticket.ID], alert.AlertType) {
+				continue
+			}
+
+			recipient := sendRuleAlert(ctx, db, alert, ticket, slaData[ticket.ID], evaluation, shadowMode, notificationService)
+			if !alert.TeamID.Valid {
+				alerted[watchAlertKey{recipient.ID, ticket.ID, alert.AlertType}] = true
 			}
 		}
 	}
+	processWatchedTickets(ctx, db, tickets, slaData, mutes, alerted, notificationService)
 	middlewares.AddGlobalNotification(sseServer, "Ticket processing complete", fmt.Sprintf("Processed %v tickets...", len(tickets)), "success")
 }
 
+// sendRuleAlert logs a rule's alert about a ticket and delivers it, routing
+// on-call rules to whoever is on call. It returns the user the alert was sent to.
+func sendRuleAlert(ctx context.Context, db db.Database, alert models.TagAlert, ticket zendesk.Ticket, slaInfo SLAInfo, evaluation RuleEvaluation, shadowMode bool, notificationService *NotificationService) models.User {
+	logAlert(alert, ticket, alert.AlertType)
+	if !shadowMode {
+		timestamp := time.Now().Format("2006-01-02 15:04:05")
+		alertLog := models.AlertLog{
+			UserID:    int64(alert.UserID),
+			TeamID:    alert.TeamID.Int64,
+			TicketID:  int64(ticket.ID),
+			Tag:       alertLogTag(alert, &ticket),
+			AlertType: alert.AlertType,
+			Timestamp: timestamp,
+		}
+		models.CreateAlertLog(ctx, db, alertLog)
+	}
+	notification := Notification{
+		AlertType:  alert.AlertType,
+		Recipient:  alert.User,
+		Rule:       &alert,
+		Ticket:     &ticket,
+		SLA:        &slaInfo,
+		SLALabel:   evaluation.SLALabel,
+		Matches:    evaluation.Matches,
+		Duplicates: evaluation.Duplicates,
+		Risk:       evaluation.Risk,
+	}
+
+	// On-call rules go to whoever is on call now, falling back to the
+	// rule's owner and channel when nobody is
+	if found, err := resolveOnCall(db, &notification); err != nil || !found {
+		if err != nil {
+			fmt.Printf("Failed to resolve on-call for %s: %v\n", alert.OnCallScheduleName, err)
+		}
+		fmt.Printf("Nobody is on call for %s, alerting the rule's owner for Ticket #%d\n", alert.OnCallScheduleName, ticket.ID)
+		rule := alert
+		rule.OnCallScheduleID = sql.NullInt64{}
+		notification.Rule = &rule
+	}
+
+	if err := notificationService.Dispatch(ctx, notification); err != nil {
+		fmt.Printf("Failed to deliver alert for Ticket #%d: %v\n", ticket.ID, err)
+	}
+	return notification.Recipient
+}
+
+// slaAlertSent reports whether the rule's owner has already been alerted about
+// the ticket's current SLA breach time. When they have not, the alert is
+// recorded so later polls do not repeat it.
+func slaAlertSent(ctx context.Context, db db.Database, userID, teamID int, ticket zendesk.Ticket, slaInfo SLAInfo, alertType string) bool {
+	existingAlert, err := models.GetSLAAlertCache(ctx, db, userID, teamID, int(ticket.ID), alertType)
+	if err == nil && existingAlert.BreachAt != slaInfo.PolicyMetrics[0].BreachAt {
+		models.ClearSLAAlertCache(ctx, db, existingAlert.ID)
+	} else if err == nil {
+		return true
+	}
+
+	// Log the SLA alert
+	logEntry := models.SLAAlertCache{
+		UserID:    int64(userID),
+		TeamID:    int64(teamID),
+		TicketID:  int64(ticket.ID),
+		AlertType: alertType,
+		BreachAt:  slaInfo.PolicyMetrics[0].BreachAt,
+	}
+	if err := models.CreateSLAAlertCache(ctx, db, logEntry); err != nil {
+		fmt.Printf("Failed to log SLA alert for Ticket #%d: %v\n", ticket.ID, err)
+	}
+	return false
+}
+
+// RuleEvaluation is the outcome of evaluating an alert rule against a ticket.
+type RuleEvaluation struct {
+	Fires     bool
+	SLALabel  string
+	SLAMetric SLAPolicyMetric
+	Matches   []KeywordMatch // Phrases matched by the rule's keywords
+	// Duplicates are recent open tickets a new ticket is likely a duplicate of
+	Duplicates []DuplicateTicket
+	Risk       *SLARisk // Predicted chance of breaching the SLA metric, when known
+}
+
+// evaluateRule reports whether the rule would fire for the ticket right now,
+// given how the ticket changed since the previous poll. Keywords are also
+// matched against new public comments when a comment resolver is given, and
+// new tickets are checked for duplicates when a duplicate resolver is given.
+// SLA alerts carry a breach risk when a predictor is given.
+func evaluateRule(alert models.TagAlert, ticket zendesk.Ticket, slaData map[int64]SLAInfo, change TicketChange, customers *CustomerResolver, comments *CommentResolver, duplicates *DuplicateResolver, risks *SLARiskPredictor) RuleEvaluation {
+	if !ruleMatches(alert, ticket.Tags, ticket.OrganizationID, ticket.RequesterID, customers) {
+		return RuleEvaluation{}
+	}
+
+	var evaluation RuleEvaluation
+	switch {
+	case isTransitionAlertType(alert.AlertType):
+		evaluation = RuleEvaluation{Fires: change.firesRule(alert, ticket)}
+	case alert.AlertType == AlertTypeSLARisk:
+		if risks != nil {
+			evaluation = evaluateSLARisk(ticket, slaData[ticket.ID], risks)
+		}
+	default:
+		evaluation = evaluateAlertType(alert.AlertType, ticket, slaData)
+	}
+	if !evaluation.Fires {
+		return evaluation
+	}
+
+	// Keywords are checked last since they may need the ticket's comments
+	matches, ok := keywordMatches(alert, ticket, comments)
+	if !ok {
+		return RuleEvaluation{}
+	}
+	evaluation.Matches = matches
+
+	// Duplicates are looked up after keywords since they need a ticket search
+	if duplicates != nil && isNewTicket(ticket) {
+		evaluation.Duplicates = duplicates.For(ticket)
+	}
+	if alert.AlertType == AlertTypePossibleDuplicate && len(evaluation.Duplicates) == 0 {
+		return RuleEvaluation{}
+	}
+	if alert.AlertType == AlertTypeSLABreach && risks != nil {
+		if risk, ok := risks.PredictMetric(ticket, evaluation.SLAMetric); ok {
+			evaluation.Risk = &risk
+		}
+	}
+	return evaluation
+}
+
+// evaluateAlertType reports whether an alert type's condition holds for the ticket, ignoring tags.
+func evaluateAlertType(alertType string, ticket zendesk.Ticket, slaData map[int64]SLAInfo) RuleEvaluation {
+	switch alertType {
+	case AlertTypeNewTicket, AlertTypePossibleDuplicate:
+		return RuleEvaluation{Fires: isNewTicket(ticket)}
+	case AlertTypeTicketUpdate:
+		return RuleEvaluation{Fires: isUpdatedTicket(ticket)}
+	case AlertTypeSLABreach:
+		if slaInfo, ok := slaData[ticket.ID]; ok {
+			if metric, label, matches := matchingSLAMetric(slaInfo.PolicyMetrics); matches {
+				return RuleEvaluation{Fires: true, SLALabel: label, SLAMetric: metric}
+			}
+		}
+	}
+	return RuleEvaluation{}
+}
+
 // slaConditionMatches checks if the SLA condition matches the threshold for sending alerts.
 func slaConditionMatches(slaMetrics []SLAPolicyMetric) (string, bool) {
+	_, label, matches := matchingSLAMetric(slaMetrics)
+	return label, matches
+}
+
+// matchingSLAMetric returns the first active SLA metric within an alert threshold, along with its label.
+func matchingSLAMetric(slaMetrics []SLAPolicyMetric) (SLAPolicyMetric, string, bool) {
 	for _, metric := range slaMetrics {
 		if metric.Stage == "active" {
 			timeRemaining := time.Until(metric.BreachAt)
 			if timeRemaining < 0 {
-				return "SLA Breached", true
+				return metric, slaLabelBreached, true
 			}
 			switch {
 			case timeRemaining <= 3*time.Hour && timeRemaining > 2*time.Hour:
-				return "Less than 3 hours remaining", true
+				return metric, "Less than 3 hours remaining", true
 			case timeRemaining <= 2*time.Hour && timeRemaining > 1*time.Hour:
-				return "Less than 2 hours remaining", true
+				return metric, "Less than 2 hours remaining", true
 			case timeRemaining <= 1*time.Hour && timeRemaining > 30*time.Minute:
-				return "Less than 1 hour remaining", true
+				return metric, "Less than 1 hour remaining", true
 			case timeRemaining <= 30*time.Minute && timeRemaining > 15*time.Minute:
-				return "Less than 30 minutes remaining", true
+				return metric, "Less than 30 minutes remaining", true
 			case timeRemaining <= 15*time.Minute:
-				return "Less than 15 minutes remaining", true
+				return metric, slaLabelFifteenMinutes, true
 			}
 		}
 	}
-	return "", false
+	return SLAPolicyMetric{}, "", false
 }
 
-// Helper function to check if a tag matches.
-func tagMatches(alertTag string, ticketTags []string) bool {
-	for _, tag := range ticketTags {
-		if tag == alertTag {
-			return true
-		}
+// ruleMatches reports whether a ticket with the given tags, organization and
+// requester is one the rule is about. Rules without a tag match every tag.
+func ruleMatches(alert models.TagAlert, tags []string, organizationID, requesterID int64, customers *CustomerResolver) bool {
+	if alert.Tag != "" && !tagMatches(alert.Tag, tags) {
+		return false
 	}
-	return false
+	return customerMatches(alert, organizationID, requesterID, customers)
+}
+
+// tagMatches reports whether any of the ticket's tags matches the rule tag,
+// which may be a glob, a regular expression, or an aliased tag.
+func tagMatches(alertTag string, ticketTags []string) bool {
+	_, ok := matchingTag(alertTag, ticketTags)
+	return ok
 }
 
 // Helper function to determine if a ticket is new.
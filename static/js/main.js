@@ -37,6 +37,84 @@ if (getSummaryNowBtn) {  // Check if the element exists
     });
 }
 
+const previewTagBtn = document.getElementById("previewTagBtn");
+
+if (previewTagBtn) {
+    previewTagBtn.addEventListener("click", function() {
+        const form = previewTagBtn.closest("form");
+        const tagPreview = document.getElementById("tagPreview");
+        if (!form || !tagPreview) {
+            return;
+        }
+        tagPreview.innerHTML = "Loading...";
+
+        fetch("/profile/preview-tag", {
+            method: "POST",
+            body: new URLSearchParams(new FormData(form)),
+            headers: {
+                "Accept": "application/json",
+            }
+        })
+        .then(response => {
+            if (!response.ok) {
+                return response.text().then(text => { throw new Error(text); });
+            }
+            return response.json();
+        })
+        .then(data => {
+            tagPreview.innerHTML = renderTagPreview(data);
+        })
+        .catch(error => {
+            console.error("Error previewing tag alert:", error);
+            tagPreview.textContent = "An error occurred while previewing the rule: " + error.message;
+        });
+    });
+}
+
+function escapeHTML(value) {
+    const div = document.createElement("div");
+    div.textContent = value == null ? "" : String(value);
+    return div.innerHTML;
+}
+
+// Renders the JSON returned by /profile/preview-tag
+function renderTagPreview(data) {
+    const history = Object.entries(data.history_by_type || {})
+        .map(([type, count]) => `${escapeHTML(type)}: ${count}`)
+        .join(", ") || "none";
+
+    const past = data.history_unavailable
+        ? `Past <code>${escapeHTML(data.alert_type)}</code> alerts are not recorded, so this rule's history cannot be replayed`
+        : `This rule would have sent <strong>${data.history_count}</strong> alerts over the last ${data.history_days} days`;
+    let html = `
+        <p><strong>${data.tickets.length}</strong> open tickets <code>${escapeHTML(data.criteria)}</code>;
+        <strong>${data.firing_now}</strong> would alert for <code>${escapeHTML(data.alert_type)}</code> right now.</p>
+        <p>${past}
+        (all alert types for these tickets: ${history}).</p>
+    `;
+    if (data.tickets.length === 0) {
+        return html;
+    }
+
+    html += `<div class="table-responsive"><table class="table table-sm"><thead><tr>
+        <th>Ticket</th><th>Status</th><th>Priority</th><th>Would Fire</th><th>SLA</th>
+    </tr></thead><tbody>`;
+    for (const ticket of data.tickets) {
+        const link = ticket.url
+            ? `<a href="${escapeHTML(ticket.url)}" target="_blank">#${ticket.id}</a>`
+            : `#${ticket.id}`;
+        html += `<tr>
+            <td>${link} ${escapeHTML(ticket.subject)}</td>
+            <td>${escapeHTML(ticket.status)}</td>
+            <td>${escapeHTML(ticket.priority)}</td>
+            <td>${ticket.firing.map(escapeHTML).join(", ") || "&mdash;"}</td>
+            <td>${escapeHTML(ticket.sla_label) || "&mdash;"}</td>
+        </tr>`;
+    }
+    html += "</tbody></table></div>";
+    return html;
+}
+
 
 // Handle incoming SSE events
 const eventSource = new EventSource("/events");